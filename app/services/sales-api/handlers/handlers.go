@@ -4,14 +4,19 @@ package handlers
 import (
 	"context"
 	"github.com/halilylm/micro/app/services/sales-api/handlers/v1"
+	"github.com/halilylm/micro/app/services/sales-api/handlers/v2"
+	"github.com/halilylm/micro/business/core/product/repository/productcache"
+	"github.com/halilylm/micro/business/core/user/repository/usercache"
 	"github.com/halilylm/micro/business/web/auth"
 	"github.com/halilylm/micro/business/web/v1/mid"
 	"github.com/halilylm/micro/foundation/web"
+	"github.com/halilylm/micro/foundation/worker"
 	"github.com/jmoiron/sqlx"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"net/http"
 	"os"
+	"time"
 )
 
 // Options represent optional parameters.
@@ -28,11 +33,18 @@ func WithCORS(origin string) func(opts *Options) {
 
 // APIMuxConfig contains all the mandatory systems required by handlers.
 type APIMuxConfig struct {
-	Shutdown chan os.Signal
-	Log      *zap.SugaredLogger
-	Auth     *auth.Auth
-	DB       *sqlx.DB
-	Tracer   trace.Tracer
+	Shutdown     chan os.Signal
+	Log          *zap.SugaredLogger
+	Auth         *auth.Auth
+	DB           *sqlx.DB
+	Tracer       trace.Tracer
+	Worker       *worker.Worker
+	UserCache    *usercache.Repository
+	ProductCache *productcache.Repository
+	AdminMFA     bool
+	MaxBodySize  int64
+	V1Deprecated time.Time
+	V1Sunset     time.Time
 }
 
 // APIMux constructs a http.Handler with all application routes defined.
@@ -53,6 +65,7 @@ func APIMux(cfg APIMuxConfig, options ...func(opts *Options)) http.Handler {
 			mid.Metrics(),
 			mid.Cors(opts.corsOrigin),
 			mid.Panics(),
+			mid.BodyLimit(cfg.MaxBodySize),
 		)
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 			return nil
@@ -67,13 +80,29 @@ func APIMux(cfg APIMuxConfig, options ...func(opts *Options)) http.Handler {
 			mid.Errors(cfg.Log),
 			mid.Metrics(),
 			mid.Panics(),
+			mid.BodyLimit(cfg.MaxBodySize),
 		)
 	}
 
 	v1.Routes(app, v1.Config{
-		Log:  cfg.Log,
-		Auth: cfg.Auth,
-		DB:   cfg.DB,
+		Log:          cfg.Log,
+		Auth:         cfg.Auth,
+		DB:           cfg.DB,
+		Worker:       cfg.Worker,
+		UserCache:    cfg.UserCache,
+		ProductCache: cfg.ProductCache,
+		AdminMFA:     cfg.AdminMFA,
+		Deprecated:   cfg.V1Deprecated,
+		Sunset:       cfg.V1Sunset,
+	})
+
+	v2.Routes(app, v2.Config{
+		Log:          cfg.Log,
+		Auth:         cfg.Auth,
+		DB:           cfg.DB,
+		UserCache:    cfg.UserCache,
+		ProductCache: cfg.ProductCache,
+		AdminMFA:     cfg.AdminMFA,
 	})
 
 	return app
@@ -0,0 +1,115 @@
+// Package apikeygrp maintains the group of handlers for api key access.
+package apikeygrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/apikey"
+	"github.com/halilylm/micro/business/core/user"
+	"github.com/halilylm/micro/business/web/auth"
+	v1web "github.com/halilylm/micro/business/web/v1"
+	"github.com/halilylm/micro/foundation/web"
+	"net/http"
+)
+
+var ErrInvalidID = errors.New("ID is not in its proper form")
+
+// Handlers manages the set of api key endpoints.
+type Handlers struct {
+	APIKey *apikey.Core
+	Auth   *auth.Auth
+}
+
+// Create issues a new api key. The plain text key is only part of this
+// response and can't be retrieved again.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var nk apikey.NewAPIKey
+	if err := web.Decode(r, &nk); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	if err := auth.CheckScopes(nk.Scopes); err != nil {
+		return v1web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	// A token limited to some scopes can't create a key with more.
+	claims := auth.GetClaims(ctx)
+	if !auth.GrantsScopes(claims.Scopes, nk.Scopes) {
+		return auth.NewAuthError("api key scopes exceed the scopes of the token")
+	}
+
+	var zero uuid.UUID
+	if nk.UserID == zero {
+		userID, err := uuid.Parse(claims.Subject)
+		if err != nil {
+			return auth.NewAuthError("auth failed")
+		}
+		nk.UserID = userID
+	}
+
+	res := auth.Resource{Type: auth.ResourceAPIKey, OwnerID: nk.UserID.String(), Action: auth.ActionCreate}
+	if err := h.Auth.Authorize(ctx, claims, auth.RuleOwnerOrAdmin, res); err != nil {
+		return auth.NewAuthError("auth failed")
+	}
+
+	key, err := h.APIKey.Create(ctx, nk)
+	if err != nil {
+		switch {
+		case errors.Is(err, apikey.ErrInvalidRoles):
+			return v1web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, user.ErrNotFound):
+			return v1web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("creating api key, nk[%+v]: %w", nk, err)
+		}
+	}
+
+	return web.Respond(ctx, w, key, http.StatusCreated)
+}
+
+// Query returns the api keys that belong to the authenticated user.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := uuid.Parse(auth.GetClaims(ctx).Subject)
+	if err != nil {
+		return auth.NewAuthError("auth failed")
+	}
+
+	keys, err := h.APIKey.QueryByUserID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("unable to query for api keys: %w", err)
+	}
+
+	return web.Respond(ctx, w, keys, http.StatusOK)
+}
+
+// Delete revokes an api key.
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	keyID, err := uuid.Parse(web.Param(r, "id"))
+	if err != nil {
+		return v1web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+	}
+
+	key, err := h.APIKey.QueryByID(ctx, keyID)
+	if err != nil {
+		switch {
+		case errors.Is(err, apikey.ErrNotFound):
+			return web.Respond(ctx, w, nil, http.StatusNoContent)
+		default:
+			return fmt.Errorf("ID[%s]: %w", keyID, err)
+		}
+	}
+
+	claims := auth.GetClaims(ctx)
+	res := auth.Resource{Type: auth.ResourceAPIKey, OwnerID: key.UserID.String(), Action: auth.ActionDelete}
+	if err := h.Auth.Authorize(ctx, claims, auth.RuleOwnerOrAdmin, res); err != nil {
+		return auth.NewAuthError("auth failed")
+	}
+
+	if err := h.APIKey.Delete(ctx, key); err != nil {
+		return fmt.Errorf("ID[%s]: %w", keyID, err)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
@@ -0,0 +1,43 @@
+// Package auditgrp maintains the group of handlers for audit log access.
+package auditgrp
+
+import (
+	"context"
+	"fmt"
+	"github.com/halilylm/micro/business/core/audit"
+	v1web "github.com/halilylm/micro/business/web/v1"
+	"github.com/halilylm/micro/foundation/web"
+	"net/http"
+	"strconv"
+)
+
+// Handlers manages the set of audit endpoints.
+type Handlers struct {
+	Audit *audit.Core
+}
+
+// Query returns a list of audit entries with paging, newest first.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	page := web.Param(r, "page")
+	pageNumber, err := strconv.Atoi(page)
+	if err != nil {
+		return v1web.NewRequestError(fmt.Errorf("invalid page format [%s]", page), http.StatusBadRequest)
+	}
+	rows := web.Param(r, "rows")
+	rowsPerPage, err := strconv.Atoi(rows)
+	if err != nil {
+		return v1web.NewRequestError(fmt.Errorf("invalid rows format [%s]", rows), http.StatusBadRequest)
+	}
+
+	filter, err := getFilter(r)
+	if err != nil {
+		return v1web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	entries, err := h.Audit.Query(ctx, filter, pageNumber, rowsPerPage)
+	if err != nil {
+		return fmt.Errorf("unable to query for audit entries: %w", err)
+	}
+
+	return web.Respond(ctx, w, entries, http.StatusOK)
+}
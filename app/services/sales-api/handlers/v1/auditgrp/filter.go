@@ -0,0 +1,38 @@
+package auditgrp
+
+import (
+	"fmt"
+	"github.com/halilylm/micro/business/core/audit"
+	"net/http"
+	"time"
+)
+
+func getFilter(r *http.Request) (audit.QueryFilter, error) {
+	values := r.URL.Query()
+
+	var filter audit.QueryFilter
+	filter.ByActor(values.Get("actor"))
+	filter.ByOnBehalfOf(values.Get("on_behalf_of"))
+	filter.ByAction(values.Get("action"))
+	filter.ByEntity(values.Get("entity"))
+	filter.ByEntityID(values.Get("entity_id"))
+	filter.ByTraceID(values.Get("trace_id"))
+
+	if v := values.Get("start_date"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return audit.QueryFilter{}, fmt.Errorf("invalid start_date format [%s]", v)
+		}
+		filter.ByStartDate(t)
+	}
+
+	if v := values.Get("end_date"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return audit.QueryFilter{}, fmt.Errorf("invalid end_date format [%s]", v)
+		}
+		filter.ByEndDate(t)
+	}
+
+	return filter, nil
+}
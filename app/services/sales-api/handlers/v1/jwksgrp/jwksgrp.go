@@ -0,0 +1,75 @@
+// Package jwksgrp maintains the group of handlers for publishing the public
+// signing keys.
+package jwksgrp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/halilylm/micro/business/web/auth"
+	"github.com/halilylm/micro/foundation/web"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxAge is how long clients may use the key set before asking again. Keys
+// are published ahead of being used for signing so a client that is behind
+// only misses keys it doesn't need yet.
+const maxAge = 5 * time.Minute
+
+// mediaType is the media type of a JWK set from RFC 7517.
+const mediaType = "application/jwk-set+json"
+
+// Handlers manages the set of key endpoints.
+type Handlers struct {
+	Auth *auth.Auth
+}
+
+// JWKS returns the public keys used to verify tokens as a JWK set. The
+// response carries an ETag so clients can revalidate cheaply. The set is
+// always JSON, whatever the client accepts, since that's the only form key
+// set clients understand.
+func (h Handlers) JWKS(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	set, err := h.Auth.JWKS()
+	if err != nil {
+		return fmt.Errorf("jwks: %w", err)
+	}
+
+	data, err := json.Marshal(set)
+	if err != nil {
+		return fmt.Errorf("jwks: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	w.Header().Set("ETag", etag)
+
+	if match(r.Header.Get("If-None-Match"), etag) {
+		return web.Respond(ctx, w, nil, http.StatusNotModified)
+	}
+
+	web.SetStatusCode(ctx, http.StatusOK)
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("jwks: %w", err)
+	}
+
+	return nil
+}
+
+// match reports whether the If-None-Match header lists the etag.
+func match(header string, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
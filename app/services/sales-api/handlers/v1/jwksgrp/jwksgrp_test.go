@@ -0,0 +1,122 @@
+package jwksgrp_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/halilylm/micro/app/services/sales-api/handlers/v1/jwksgrp"
+	"github.com/halilylm/micro/business/web/auth"
+	"github.com/halilylm/micro/business/web/v1/mid"
+	"github.com/halilylm/micro/foundation/keystore"
+	"github.com/halilylm/micro/foundation/web"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+// TestJWKS makes sure the key set is served with its own media type no
+// matter what the client accepts.
+func TestJWKS(t *testing.T) {
+	app := newApp(t)
+
+	tests := []struct {
+		name   string
+		accept string
+	}{
+		{"no accept", ""},
+		{"json", "application/json"},
+		{"jwk set", "application/jwk-set+json"},
+		{"csv", "text/csv"},
+	}
+
+	var etag string
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d, want %d", tt.name, w.Code, http.StatusOK)
+		}
+		if got := w.Header().Get("Content-Type"); got != "application/jwk-set+json" {
+			t.Errorf("%s: got content type %q", tt.name, got)
+		}
+
+		var set struct {
+			Keys []struct {
+				KID string `json:"kid"`
+			} `json:"keys"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &set); err != nil {
+			t.Fatalf("%s: decoding key set: %s", tt.name, err)
+		}
+		if len(set.Keys) != 1 || set.Keys[0].KID != "test" {
+			t.Errorf("%s: got keys %+v", tt.name, set.Keys)
+		}
+
+		etag = w.Header().Get("ETag")
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	r.Header.Set("If-None-Match", etag)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("revalidating: got status %d, want %d", w.Code, http.StatusNotModified)
+	}
+}
+
+// =============================================================================
+
+// newApp returns an app serving the key set of an auth with a single key.
+func newApp(t *testing.T) *web.App {
+	t.Helper()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %s", err)
+	}
+
+	block := pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	}
+
+	fsys := fstest.MapFS{
+		"test.pem": &fstest.MapFile{Data: pem.EncodeToMemory(&block)},
+	}
+
+	ks, err := keystore.NewFS(fsys)
+	if err != nil {
+		t.Fatalf("constructing key store: %s", err)
+	}
+
+	log := zap.NewNop().Sugar()
+
+	a, err := auth.New(auth.Config{
+		Log:       log,
+		KeyLookup: ks,
+	})
+	if err != nil {
+		t.Fatalf("constructing auth: %s", err)
+	}
+
+	app := web.NewApp(make(chan os.Signal, 1), trace.NewNoopTracerProvider().Tracer(""), mid.Errors(log))
+
+	jgh := jwksgrp.Handlers{
+		Auth: a,
+	}
+	app.Handle(http.MethodGet, "", "/.well-known/jwks.json", jgh.JWKS)
+
+	return app
+}
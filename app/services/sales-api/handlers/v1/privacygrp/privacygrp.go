@@ -0,0 +1,167 @@
+// Package privacygrp maintains the group of handlers for exporting and
+// erasing personal data.
+package privacygrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/privacy"
+	"github.com/halilylm/micro/business/core/user"
+	"github.com/halilylm/micro/business/web/auth"
+	v1web "github.com/halilylm/micro/business/web/v1"
+	"github.com/halilylm/micro/foundation/web"
+	"net/http"
+)
+
+var ErrInvalidID = errors.New("ID is not in its proper form")
+
+// Handlers manages the set of privacy endpoints.
+type Handlers struct {
+	Privacy *privacy.Core
+	User    *user.Core
+	Auth    *auth.Auth
+}
+
+// Export starts a job that assembles the data of the user into an archive.
+// The user is the authenticated one unless an admin specifies the user_id
+// query parameter.
+func (h Handlers) Export(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	usr, err := h.targetUser(ctx, r, auth.ActionExport)
+	if err != nil {
+		return err
+	}
+
+	job, err := h.Privacy.StartExport(ctx, usr)
+	if err != nil {
+		return startError(usr, err)
+	}
+
+	return web.Respond(ctx, w, job, http.StatusAccepted)
+}
+
+// Erase starts a job that anonymizes the user. Once the job completes the
+// user can't authenticate anymore, so an erasure requested by the user
+// can only be polled until then.
+func (h Handlers) Erase(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	usr, err := h.targetUser(ctx, r, auth.ActionErase)
+	if err != nil {
+		return err
+	}
+
+	job, err := h.Privacy.StartErasure(ctx, usr)
+	if err != nil {
+		return startError(usr, err)
+	}
+
+	return web.Respond(ctx, w, job, http.StatusAccepted)
+}
+
+// QueryByID returns the status of a job.
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	job, err := h.queryJob(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, job, http.StatusOK)
+}
+
+// QueryArchive returns the archive produced by a completed export job.
+func (h Handlers) QueryArchive(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	job, err := h.queryJob(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	archive, err := h.Privacy.QueryArchive(ctx, job)
+	if err != nil {
+		switch {
+		case errors.Is(err, privacy.ErrNotExport):
+			return v1web.NewRequestError(err, http.StatusNotFound)
+		case errors.Is(err, privacy.ErrNotDone):
+			return v1web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s]: %w", job.ID, err)
+		}
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "export-"+job.UserID.String()+".json"))
+
+	return web.Respond(ctx, w, archive, http.StatusOK)
+}
+
+// =============================================================================
+
+// targetUser returns the user the request is about if the caller may
+// perform the action on them.
+func (h Handlers) targetUser(ctx context.Context, r *http.Request, action string) (user.User, error) {
+	claims := auth.GetClaims(ctx)
+
+	subject := claims.Subject
+	if id := r.URL.Query().Get("user_id"); id != "" {
+		subject = id
+	}
+
+	userID, err := uuid.Parse(subject)
+	if err != nil {
+		return user.User{}, v1web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+	}
+
+	res := auth.Resource{Type: auth.ResourceUser, OwnerID: userID.String(), Action: action}
+	if err := h.Auth.Authorize(ctx, claims, auth.RuleOwnerOrAdmin, res); err != nil {
+		return user.User{}, auth.NewAuthError("auth failed")
+	}
+
+	usr, err := h.User.QueryByID(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNotFound):
+			return user.User{}, v1web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return user.User{}, fmt.Errorf("ID[%s]: %w", userID, err)
+		}
+	}
+
+	return usr, nil
+}
+
+// queryJob returns the job from the request if it belongs to the caller or
+// the caller is an admin.
+func (h Handlers) queryJob(ctx context.Context, r *http.Request) (privacy.Job, error) {
+	jobID, err := uuid.Parse(web.Param(r, "id"))
+	if err != nil {
+		return privacy.Job{}, v1web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+	}
+
+	job, err := h.Privacy.QueryByID(ctx, jobID)
+	if err != nil {
+		switch {
+		case errors.Is(err, privacy.ErrNotFound):
+			return privacy.Job{}, v1web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return privacy.Job{}, fmt.Errorf("ID[%s]: %w", jobID, err)
+		}
+	}
+
+	claims := auth.GetClaims(ctx)
+	res := auth.Resource{Type: auth.ResourcePrivacyJob, OwnerID: job.UserID.String(), Action: auth.ActionRead}
+	if err := h.Auth.Authorize(ctx, claims, auth.RuleOwnerOrAdmin, res); err != nil {
+		return privacy.Job{}, auth.NewAuthError("auth failed")
+	}
+
+	return job, nil
+}
+
+// startError translates the errors from starting a job.
+func startError(usr user.User, err error) error {
+	switch {
+	case errors.Is(err, privacy.ErrJobRunning):
+		return v1web.NewRequestError(err, http.StatusConflict)
+	case errors.Is(err, privacy.ErrJobsBusy):
+		return v1web.NewRequestError(err, http.StatusServiceUnavailable)
+	default:
+		return fmt.Errorf("ID[%s]: %w", usr.ID, err)
+	}
+}
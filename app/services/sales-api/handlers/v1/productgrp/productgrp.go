@@ -61,7 +61,8 @@ func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Req
 		}
 	}
 	claims := auth.GetClaims(ctx)
-	if claims.Subject != prd.UserID.String() || h.Auth.Authorize(ctx, claims, auth.RuleAdminOnly) != nil {
+	res := auth.Resource{Type: auth.ResourceProduct, OwnerID: prd.UserID.String(), Action: auth.ActionUpdate}
+	if err := h.Auth.Authorize(ctx, claims, auth.RuleOwnerOrAdmin, res); err != nil {
 		return auth.NewAuthError("auth failed")
 	}
 
@@ -93,7 +94,8 @@ func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Req
 	}
 
 	claims := auth.GetClaims(ctx)
-	if claims.Subject != prd.UserID.String() || h.Auth.Authorize(ctx, claims, auth.RuleAdminOnly) != nil {
+	res := auth.Resource{Type: auth.ResourceProduct, OwnerID: prd.UserID.String(), Action: auth.ActionDelete}
+	if err := h.Auth.Authorize(ctx, claims, auth.RuleOwnerOrAdmin, res); err != nil {
 		return auth.NewAuthError("auth failed")
 	}
 
@@ -101,6 +103,8 @@ func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Req
 		switch {
 		case errors.Is(err, product.ErrInvalidID):
 			return v1web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, product.ErrInUse):
+			return v1web.NewRequestError(err, http.StatusConflict)
 		default:
 			return fmt.Errorf("ID[%s]: %w", prdID, err)
 		}
@@ -140,6 +144,32 @@ func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Requ
 	return web.Respond(ctx, w, products, http.StatusOK)
 }
 
+// Export streams every matching product as newline delimited JSON.
+func (h Handlers) Export(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	filter, err := getFilter(r)
+	if err != nil {
+		return v1web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	orderBy, err := v1web.GetOrderBy(r, product.DefaultOrderBy)
+	if err != nil {
+		return v1web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	export := func(send func(v any) error) error {
+		f := func(prd product.Product) error {
+			return send(prd)
+		}
+		return h.Product.QueryAll(ctx, filter, orderBy, f)
+	}
+
+	if err := web.StreamNDJSON(ctx, w, export); err != nil {
+		return fmt.Errorf("unable to export products: %w", err)
+	}
+
+	return nil
+}
+
 // QueryByID returns a product by its ID.
 func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	prdID, err := uuid.Parse(web.Param(r, "id"))
@@ -0,0 +1,182 @@
+// Package rolegrp maintains the group of handlers for role and permission
+// access.
+package rolegrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/halilylm/micro/business/core/role"
+	"github.com/halilylm/micro/business/web/auth"
+	v1web "github.com/halilylm/micro/business/web/v1"
+	"github.com/halilylm/micro/foundation/web"
+	"net/http"
+)
+
+// Handlers manages the set of role endpoints.
+type Handlers struct {
+	Role *role.Core
+	Auth *auth.Auth
+}
+
+// Create adds a new role to the system.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var nr role.NewRole
+	if err := web.Decode(r, &nr); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	rol, err := h.Role.Create(ctx, nr)
+	if err != nil {
+		switch {
+		case errors.Is(err, role.ErrUniqueName):
+			return v1web.NewRequestError(err, http.StatusConflict)
+		case errors.Is(err, role.ErrUnknownPermission):
+			return v1web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("role[%+v]: %w", &nr, err)
+		}
+	}
+	h.Auth.InvalidatePolicyData()
+
+	return web.Respond(ctx, w, rol, http.StatusCreated)
+}
+
+// Update updates a role in the system.
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var upd role.UpdateRole
+	if err := web.Decode(r, &upd); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	name := web.Param(r, "name")
+
+	rol, err := h.Role.QueryByName(ctx, name)
+	if err != nil {
+		switch {
+		case errors.Is(err, role.ErrNotFound):
+			return v1web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("name[%s]: %w", name, err)
+		}
+	}
+
+	rol, err = h.Role.Update(ctx, rol, upd)
+	if err != nil {
+		switch {
+		case errors.Is(err, role.ErrUnknownPermission):
+			return v1web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("name[%s] Role[%+v]: %w", name, &upd, err)
+		}
+	}
+	h.Auth.InvalidatePolicyData()
+
+	return web.Respond(ctx, w, rol, http.StatusOK)
+}
+
+// Delete removes a role from the system.
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	name := web.Param(r, "name")
+
+	rol, err := h.Role.QueryByName(ctx, name)
+	if err != nil {
+		switch {
+		case errors.Is(err, role.ErrNotFound):
+			return web.Respond(ctx, w, nil, http.StatusNoContent)
+		default:
+			return fmt.Errorf("name[%s]: %w", name, err)
+		}
+	}
+
+	if err := h.Role.Delete(ctx, rol); err != nil {
+		switch {
+		case errors.Is(err, role.ErrInUse):
+			return v1web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("name[%s]: %w", name, err)
+		}
+	}
+	h.Auth.InvalidatePolicyData()
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Query returns the list of roles.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	roles, err := h.Role.Query(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to query for roles: %w", err)
+	}
+
+	return web.Respond(ctx, w, roles, http.StatusOK)
+}
+
+// QueryByName returns a role by its name.
+func (h Handlers) QueryByName(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	name := web.Param(r, "name")
+
+	rol, err := h.Role.QueryByName(ctx, name)
+	if err != nil {
+		switch {
+		case errors.Is(err, role.ErrNotFound):
+			return v1web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("name[%s]: %w", name, err)
+		}
+	}
+
+	return web.Respond(ctx, w, rol, http.StatusOK)
+}
+
+// CreatePermission adds a new permission to the system.
+func (h Handlers) CreatePermission(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var np role.NewPermission
+	if err := web.Decode(r, &np); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	perm, err := h.Role.CreatePermission(ctx, np)
+	if err != nil {
+		switch {
+		case errors.Is(err, role.ErrUniqueName):
+			return v1web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("permission[%+v]: %w", &np, err)
+		}
+	}
+
+	return web.Respond(ctx, w, perm, http.StatusCreated)
+}
+
+// DeletePermission removes a permission from the system and every role.
+func (h Handlers) DeletePermission(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	name := web.Param(r, "name")
+
+	perm, err := h.Role.QueryPermissionByName(ctx, name)
+	if err != nil {
+		switch {
+		case errors.Is(err, role.ErrPermissionNotFound):
+			return web.Respond(ctx, w, nil, http.StatusNoContent)
+		default:
+			return fmt.Errorf("name[%s]: %w", name, err)
+		}
+	}
+
+	if err := h.Role.DeletePermission(ctx, perm); err != nil {
+		return fmt.Errorf("name[%s]: %w", name, err)
+	}
+	h.Auth.InvalidatePolicyData()
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// QueryPermissions returns the list of permissions.
+func (h Handlers) QueryPermissions(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	perms, err := h.Role.QueryPermissions(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to query for permissions: %w", err)
+	}
+
+	return web.Respond(ctx, w, perms, http.StatusOK)
+}
@@ -0,0 +1,67 @@
+// Package tokengrp maintains the group of handlers for token introspection
+// and revocation.
+package tokengrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/halilylm/micro/business/web/auth"
+	v1web "github.com/halilylm/micro/business/web/v1"
+	"github.com/halilylm/micro/foundation/web"
+	"net/http"
+)
+
+// ErrMissingToken is returned when the request doesn't carry a token.
+var ErrMissingToken = errors.New("invalid_request")
+
+// Handlers manages the set of token endpoints.
+type Handlers struct {
+	Auth *auth.Auth
+}
+
+// Introspect describes the token sent in the form parameter token, following
+// RFC 7662. A token that can't be used is reported as not active.
+func (h Handlers) Introspect(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	token, err := formToken(r)
+	if err != nil {
+		return err
+	}
+
+	intro := h.Auth.Introspect(ctx, token)
+
+	w.Header().Set("Cache-Control", "no-store")
+	return web.Respond(ctx, w, intro, http.StatusOK)
+}
+
+// Revoke revokes the token sent in the form parameter token, following
+// RFC 7009. The response is the same whether the token was valid or not.
+func (h Handlers) Revoke(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	token, err := formToken(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Auth.Revoke(ctx, token); err != nil {
+		if errors.Is(err, auth.ErrUnsupportedTokenType) {
+			return v1web.NewRequestError(err, http.StatusBadRequest)
+		}
+		return fmt.Errorf("revoking token: %w", err)
+	}
+
+	return web.Respond(ctx, w, struct{}{}, http.StatusOK)
+}
+
+// formToken reads the token from the form encoded body.
+func formToken(r *http.Request) (string, error) {
+	if err := r.ParseForm(); err != nil {
+		return "", v1web.NewRequestError(ErrMissingToken, http.StatusBadRequest)
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		return "", v1web.NewRequestError(ErrMissingToken, http.StatusBadRequest)
+	}
+
+	return token, nil
+}
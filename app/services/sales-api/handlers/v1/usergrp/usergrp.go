@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/audit"
 	"github.com/halilylm/micro/business/core/user"
 	"github.com/halilylm/micro/business/web/auth"
 	v1web "github.com/halilylm/micro/business/web/v1"
@@ -23,6 +24,10 @@ var ErrInvalidID = errors.New("ID is not in its proper form")
 type Handlers struct {
 	User *user.Core
 	Auth *auth.Auth
+
+	// AdminRule is the rule a caller must pass to change the roles of a user
+	// or whether it's enabled, which owners can't do for themselves.
+	AdminRule string
 }
 
 // Create adds a new user to the system
@@ -56,10 +61,17 @@ func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Req
 	}
 
 	claims := auth.GetClaims(ctx)
-	if claims.Subject != userID.String() && h.Auth.Authorize(ctx, claims, auth.RuleAdminOnly) != nil {
+	res := auth.Resource{Type: auth.ResourceUser, OwnerID: userID.String(), Action: auth.ActionUpdate}
+	if err := h.Auth.Authorize(ctx, claims, auth.RuleOwnerOrAdmin, res); err != nil {
 		return auth.NewAuthError("auth failed")
 	}
 
+	if upd.Roles != nil || upd.Enabled != nil {
+		if err := h.Auth.Authorize(ctx, claims, h.AdminRule, res); err != nil {
+			return auth.NewAuthError("auth failed")
+		}
+	}
+
 	usr, err := h.User.QueryByID(ctx, userID)
 	if err != nil {
 		switch {
@@ -86,7 +98,8 @@ func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Req
 	}
 
 	claims := auth.GetClaims(ctx)
-	if claims.Subject != userID.String() && h.Auth.Authorize(ctx, claims, auth.RuleAdminOnly) != nil {
+	res := auth.Resource{Type: auth.ResourceUser, OwnerID: userID.String(), Action: auth.ActionDelete}
+	if err := h.Auth.Authorize(ctx, claims, auth.RuleOwnerOrAdmin, res); err != nil {
 		return auth.NewAuthError("auth failed")
 	}
 
@@ -101,7 +114,12 @@ func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Req
 	}
 
 	if err := h.User.Delete(ctx, usr); err != nil {
-		return fmt.Errorf("ID[%s]: %w", userID, err)
+		switch {
+		case errors.Is(err, user.ErrInUse):
+			return v1web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s]: %w", userID, err)
+		}
 	}
 
 	return web.Respond(ctx, w, nil, http.StatusNoContent)
@@ -150,7 +168,8 @@ func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.
 	}
 
 	claims := auth.GetClaims(ctx)
-	if claims.Subject != userID.String() && h.Auth.Authorize(ctx, claims, auth.RuleAdminOnly) != nil {
+	res := auth.Resource{Type: auth.ResourceUser, OwnerID: userID.String(), Action: auth.ActionRead}
+	if err := h.Auth.Authorize(ctx, claims, auth.RuleOwnerOrAdmin, res); err != nil {
 		return auth.NewAuthError("auth failed")
 	}
 
@@ -167,10 +186,14 @@ func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.
 	return web.Respond(ctx, w, usr, http.StatusOK)
 }
 
+// Token authenticates the user with Basic auth and returns a token. When the
+// user has MFA enabled, a short-lived challenge token is returned instead
+// which must be exchanged through TokenMFA. The token can be limited to a
+// space separated list of scopes with the scope query parameter.
 func (h Handlers) Token(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	kid := web.Param(r, "kid")
-	if kid == "" {
-		return v1web.NewRequestError(errors.New("missing kid"), http.StatusBadRequest)
+	scopes, err := auth.ParseScopes(r.URL.Query().Get("scope"))
+	if err != nil {
+		return v1web.NewRequestError(err, http.StatusBadRequest)
 	}
 
 	email, pass, ok := r.BasicAuth()
@@ -195,6 +218,237 @@ func (h Handlers) Token(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		}
 	}
 
+	if usr.MFA.Enabled {
+		claims := auth.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        uuid.NewString(),
+				Subject:   usr.ID.String(),
+				Issuer:    "micro",
+				Audience:  jwt.ClaimStrings{auth.AudienceMFAChallenge},
+				ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(5 * time.Minute)),
+				IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			},
+			AMR:    []string{auth.AMRPassword},
+			Scopes: scopes,
+		}
+
+		var challenge struct {
+			MFARequired bool   `json:"mfa_required"`
+			MFAToken    string `json:"mfa_token"`
+		}
+		challenge.MFARequired = true
+		challenge.MFAToken, err = h.Auth.GenerateToken(claims)
+		if err != nil {
+			return fmt.Errorf("generating mfa challenge: %w", err)
+		}
+
+		return web.Respond(ctx, w, challenge, http.StatusOK)
+	}
+
+	return h.respondToken(ctx, w, usr, []string{auth.AMRPassword}, scopes)
+}
+
+// TokenMFA completes a login for a user with MFA enabled by exchanging the
+// challenge token and a TOTP or recovery code for a token.
+func (h Handlers) TokenMFA(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var req struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	if err := web.Decode(r, &req); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	claims, err := h.Auth.AuthenticateMFAChallenge(ctx, req.MFAToken)
+	if err != nil {
+		return auth.NewAuthError("mfa challenge: %s", err)
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return auth.NewAuthError("mfa challenge: invalid subject")
+	}
+
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return auth.NewAuthError("mfa challenge: missing id")
+	}
+
+	usr, err := h.User.QueryByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("ID[%s]: %w", userID, err)
+	}
+
+	// A used recovery code is removed on behalf of the user logging in.
+	ctx = audit.SetActor(ctx, claims.Subject)
+
+	ch := user.MFAChallenge{
+		ID:          claims.ID,
+		UserID:      userID,
+		DateExpires: claims.ExpiresAt.Time,
+	}
+
+	if _, err := h.User.VerifyMFAChallenge(ctx, usr, ch, req.Code); err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidMFACode), errors.Is(err, user.ErrMFANotEnrolled), errors.Is(err, user.ErrMFAAttempts):
+			return auth.NewAuthError(err.Error())
+		default:
+			return fmt.Errorf("verifying mfa: %w", err)
+		}
+	}
+
+	return h.respondToken(ctx, w, usr, []string{auth.AMRPassword, auth.AMRMFA}, claims.Scopes)
+}
+
+// EnrollMFA starts MFA enrollment for the authenticated user.
+func (h Handlers) EnrollMFA(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	usr, err := h.claimsUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, enrollment, err := h.User.EnrollMFA(ctx, usr)
+	if err != nil {
+		if errors.Is(err, user.ErrMFAEnabled) {
+			return v1web.NewRequestError(err, http.StatusConflict)
+		}
+		return fmt.Errorf("ID[%s]: %w", usr.ID, err)
+	}
+
+	return web.Respond(ctx, w, enrollment, http.StatusOK)
+}
+
+// ConfirmMFA finishes MFA enrollment for the authenticated user and returns
+// the recovery codes.
+func (h Handlers) ConfirmMFA(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := web.Decode(r, &req); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	usr, err := h.claimsUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, codes, err := h.User.ConfirmMFA(ctx, usr, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrMFAEnabled):
+			return v1web.NewRequestError(err, http.StatusConflict)
+		case errors.Is(err, user.ErrMFANotEnrolled), errors.Is(err, user.ErrInvalidMFACode):
+			return v1web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("ID[%s]: %w", usr.ID, err)
+		}
+	}
+
+	resp := struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		RecoveryCodes: codes,
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
+
+// DisableMFA turns MFA off for the authenticated user.
+func (h Handlers) DisableMFA(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := web.Decode(r, &req); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	usr, err := h.claimsUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := h.User.DisableMFA(ctx, usr, req.Code); err != nil {
+		switch {
+		case errors.Is(err, user.ErrMFANotEnrolled), errors.Is(err, user.ErrInvalidMFACode):
+			return v1web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("ID[%s]: %w", usr.ID, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Impersonate issues a short lived token to act as the specified user so
+// the api can be seen as they see it.
+func (h Handlers) Impersonate(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := uuid.Parse(web.Param(r, "id"))
+	if err != nil {
+		return v1web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+	}
+
+	usr, err := h.User.QueryByID(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNotFound):
+			return v1web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", userID, err)
+		}
+	}
+
+	imp, err := h.Auth.Impersonate(ctx, auth.GetClaims(ctx), usr)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrNestedImpersonation), errors.Is(err, auth.ErrImpersonateElevated):
+			return auth.NewAuthError(err.Error())
+		case errors.Is(err, auth.ErrImpersonateSelf), errors.Is(err, auth.ErrImpersonateDisabled):
+			return v1web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("ID[%s]: %w", userID, err)
+		}
+	}
+
+	return web.Respond(ctx, w, imp, http.StatusOK)
+}
+
+// EndImpersonation revokes the impersonation token used for the request.
+func (h Handlers) EndImpersonation(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	if err := h.Auth.EndImpersonation(ctx, auth.GetClaims(ctx)); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrNotImpersonating):
+			return v1web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("ending impersonation: %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// =============================================================================
+
+// claimsUser loads the user identified by the claims in the context.
+func (h Handlers) claimsUser(ctx context.Context) (user.User, error) {
+	userID, err := uuid.Parse(auth.GetClaims(ctx).Subject)
+	if err != nil {
+		return user.User{}, auth.NewAuthError("auth failed")
+	}
+
+	usr, err := h.User.QueryByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return user.User{}, v1web.NewRequestError(err, http.StatusNotFound)
+		}
+		return user.User{}, fmt.Errorf("ID[%s]: %w", userID, err)
+	}
+
+	return usr, nil
+}
+
+// respondToken generates a token for the user recording how they
+// authenticated and the scopes it's limited to, and sends it to the client.
+func (h Handlers) respondToken(ctx context.Context, w http.ResponseWriter, usr user.User, amr []string, scopes []string) error {
 	claims := auth.Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject:   usr.ID.String(),
@@ -202,13 +456,17 @@ func (h Handlers) Token(ctx context.Context, w http.ResponseWriter, r *http.Requ
 			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
 		},
-		Roles: usr.Roles,
+		Roles:  usr.Roles,
+		AMR:    amr,
+		Scopes: scopes,
 	}
 
 	var tkn struct {
 		Token string `json:"token"`
 	}
-	tkn.Token, err = h.Auth.GenerateToken(kid, claims)
+
+	var err error
+	tkn.Token, err = h.Auth.GenerateToken(claims)
 	if err != nil {
 		return fmt.Errorf("generating token: %w", err)
 	}
@@ -3,53 +3,177 @@
 package v1
 
 import (
+	"github.com/halilylm/micro/app/services/sales-api/handlers/v1/apikeygrp"
+	"github.com/halilylm/micro/app/services/sales-api/handlers/v1/auditgrp"
+	"github.com/halilylm/micro/app/services/sales-api/handlers/v1/jwksgrp"
+	"github.com/halilylm/micro/app/services/sales-api/handlers/v1/privacygrp"
 	"github.com/halilylm/micro/app/services/sales-api/handlers/v1/productgrp"
+	"github.com/halilylm/micro/app/services/sales-api/handlers/v1/rolegrp"
+	"github.com/halilylm/micro/app/services/sales-api/handlers/v1/tokengrp"
 	"github.com/halilylm/micro/app/services/sales-api/handlers/v1/usergrp"
+	"github.com/halilylm/micro/business/core/apikey"
+	"github.com/halilylm/micro/business/core/apikey/repository/apikeydb"
+	"github.com/halilylm/micro/business/core/audit"
+	"github.com/halilylm/micro/business/core/audit/repository/auditdb"
+	"github.com/halilylm/micro/business/core/privacy"
+	"github.com/halilylm/micro/business/core/privacy/repository/privacydb"
 	"github.com/halilylm/micro/business/core/product"
-	"github.com/halilylm/micro/business/core/product/repository/productdb"
+	"github.com/halilylm/micro/business/core/product/repository/productcache"
+	"github.com/halilylm/micro/business/core/role"
+	"github.com/halilylm/micro/business/core/role/repository/roledb"
+	"github.com/halilylm/micro/business/core/sale"
+	"github.com/halilylm/micro/business/core/sale/repository/saledb"
 	"github.com/halilylm/micro/business/core/user"
 	"github.com/halilylm/micro/business/core/user/repository/usercache"
-	"github.com/halilylm/micro/business/core/user/repository/userdb"
 	"github.com/halilylm/micro/business/web/auth"
 	"github.com/halilylm/micro/business/web/v1/mid"
 	"github.com/halilylm/micro/foundation/web"
+	"github.com/halilylm/micro/foundation/worker"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 	"net/http"
+	"time"
 )
 
 // Config contains all the mandatory systems required by handlers.
 type Config struct {
-	Log  *zap.SugaredLogger
-	Auth *auth.Auth
-	DB   *sqlx.DB
+	Log          *zap.SugaredLogger
+	Auth         *auth.Auth
+	DB           *sqlx.DB
+	Worker       *worker.Worker
+	UserCache    *usercache.Repository
+	ProductCache *productcache.Repository
+	AdminMFA     bool
+	Deprecated   time.Time
+	Sunset       time.Time
 }
 
-// Routes binds all the version 1 routes.
+// Routes binds all the version 1 routes. The responses of the routes that
+// have a successor in v2 are marked as deprecated, with the date they stop
+// being served when it's set.
 func Routes(app *web.App, cfg Config) {
 	const version = "v1"
 
-	authen := mid.Authenticate(cfg.Auth)
-	admin := mid.Authorize(cfg.Auth, auth.RuleAdminOnly)
+	authen := mid.Authenticate(cfg.Log, cfg.Auth)
+	adminRule := auth.RuleAdminOnly
+	if cfg.AdminMFA {
+		adminRule = auth.RuleAdminOnlyMFA
+	}
+	admin := mid.Authorize(cfg.Auth, adminRule)
 
-	ugh := usergrp.Handlers{
-		User: user.NewCore(usercache.NewRepository(cfg.Log, userdb.NewRepository(cfg.Log, cfg.DB))),
+	// Every authenticated route requires a scope so a token limited to some
+	// scopes can't be used anywhere else.
+	scope := func(scope string) web.Middleware {
+		return mid.RequireScope(cfg.Auth, scope)
+	}
+
+	// Only routes with a v2 successor are deprecated, the link points
+	// clients to it.
+	deprecated := func(successor string) web.Middleware {
+		return mid.Deprecation(cfg.Deprecated, cfg.Sunset, successor)
+	}
+
+	// Only records and lists of them can be asked for in other media types
+	// than JSON, like CSV for spreadsheets or MessagePack.
+	negotiate := web.Negotiate()
+
+	api := app.Group(version)
+	authed := api.Group("", authen)
+	admins := authed.Group("", admin)
+
+	// The key set is published at the well known location so it isn't
+	// versioned with the rest of the api.
+	jgh := jwksgrp.Handlers{
 		Auth: cfg.Auth,
 	}
-	app.Handle(http.MethodGet, version, "/users/token/:kid", ugh.Token)
-	app.Handle(http.MethodGet, version, "/users/:page/:rows", ugh.Query, authen, admin)
-	app.Handle(http.MethodGet, version, "/users/:id", ugh.QueryByID, authen)
-	app.Handle(http.MethodPost, version, "/users", ugh.Create, authen, admin)
-	app.Handle(http.MethodPut, version, "/users/:id", ugh.Update, authen, admin)
-	app.Handle(http.MethodDelete, version, "/users/:id", ugh.Delete, authen, admin)
+	app.Handle(http.MethodGet, "", "/.well-known/jwks.json", jgh.JWKS)
+
+	// Introspection is for services checking the tokens their clients send
+	// and requires the introspect permission. Holding a token is enough to
+	// revoke it.
+	tgh := tokengrp.Handlers{
+		Auth: cfg.Auth,
+	}
+	authed.Handle(http.MethodPost, "/tokens/introspect", tgh.Introspect, scope(auth.ScopeTokensRead), mid.AuthorizePermission(cfg.Auth, auth.PermissionIntrospect))
+	api.Handle(http.MethodPost, "/tokens/revoke", tgh.Revoke)
+
+	audCore := audit.NewCore(auditdb.NewRepository(cfg.Log, cfg.DB))
+	usrCore := user.NewCore(cfg.UserCache, audCore)
+
+	ugh := usergrp.Handlers{
+		User:      usrCore,
+		Auth:      cfg.Auth,
+		AdminRule: adminRule,
+	}
+	api.Handle(http.MethodGet, "/users/token", ugh.Token)
+	api.Handle(http.MethodPost, "/users/token/mfa", ugh.TokenMFA)
+	authed.Handle(http.MethodPost, "/users/mfa/enroll", ugh.EnrollMFA, scope(auth.ScopeAccountWrite))
+	authed.Handle(http.MethodPost, "/users/mfa/confirm", ugh.ConfirmMFA, scope(auth.ScopeAccountWrite))
+	authed.Handle(http.MethodDelete, "/users/mfa", ugh.DisableMFA, scope(auth.ScopeAccountWrite))
+	admins.Handle(http.MethodGet, "/users/:page/:rows", ugh.Query, scope(auth.ScopeUsersRead), deprecated("/v2/users"), negotiate)
+	authed.Handle(http.MethodGet, "/users/:id", ugh.QueryByID, scope(auth.ScopeUsersRead), deprecated("/v2/users"), negotiate)
+	admins.Handle(http.MethodPost, "/users", ugh.Create, scope(auth.ScopeUsersWrite))
+
+	// Users can update and delete themselves, the handlers ask the policy
+	// whether the caller owns the user or is an admin.
+	authed.Handle(http.MethodPut, "/users/:id", ugh.Update, scope(auth.ScopeUsersWrite))
+	authed.Handle(http.MethodDelete, "/users/:id", ugh.Delete, scope(auth.ScopeUsersWrite))
+
+	// An impersonation token acts as the user with the admin named in its act
+	// claim, the policy decides what it can't be used for.
+	authed.Handle(http.MethodPost, "/users/:id/impersonate", ugh.Impersonate, scope(auth.ScopeUsersWrite), mid.AuthorizePermission(cfg.Auth, auth.PermissionImpersonate))
+	authed.Handle(http.MethodDelete, "/users/impersonation", ugh.EndImpersonation, scope(auth.ScopeUsersWrite))
+
+	prdCore := product.NewCore(cfg.ProductCache, audCore)
 
 	pgh := productgrp.Handlers{
-		Product: product.NewCore(productdb.NewRepository(cfg.Log, cfg.DB)),
+		Product: prdCore,
 		Auth:    cfg.Auth,
 	}
-	app.Handle(http.MethodGet, version, "/products/:page/:rows", pgh.Query, authen)
-	app.Handle(http.MethodGet, version, "/products/:id", pgh.QueryByID, authen)
-	app.Handle(http.MethodPost, version, "/products", pgh.Create, authen)
-	app.Handle(http.MethodPut, version, "/products/:id", pgh.Update, authen)
-	app.Handle(http.MethodDelete, version, "/products/:id", pgh.Delete, authen)
+	authed.Handle(http.MethodGet, "/products/:page/:rows", pgh.Query, scope(auth.ScopeProductsRead), deprecated("/v2/products"), negotiate)
+	authed.Handle(http.MethodGet, "/products/export", pgh.Export, scope(auth.ScopeProductsRead))
+	authed.Handle(http.MethodGet, "/products/:id", pgh.QueryByID, scope(auth.ScopeProductsRead), deprecated("/v2/products"), negotiate)
+	authed.Handle(http.MethodPost, "/products", pgh.Create, scope(auth.ScopeProductsWrite), deprecated("/v2/products"))
+	authed.Handle(http.MethodPut, "/products/:id", pgh.Update, scope(auth.ScopeProductsWrite), deprecated("/v2/products"))
+	authed.Handle(http.MethodDelete, "/products/:id", pgh.Delete, scope(auth.ScopeProductsWrite), deprecated("/v2/products"))
+
+	apiKeyCore := apikey.NewCore(usrCore, audCore, apikeydb.NewRepository(cfg.Log, cfg.DB))
+
+	akh := apikeygrp.Handlers{
+		APIKey: apiKeyCore,
+		Auth:   cfg.Auth,
+	}
+	authed.Handle(http.MethodGet, "/apikeys", akh.Query, scope(auth.ScopeAPIKeysRead))
+	authed.Handle(http.MethodPost, "/apikeys", akh.Create, scope(auth.ScopeAPIKeysWrite))
+	authed.Handle(http.MethodDelete, "/apikeys/:id", akh.Delete, scope(auth.ScopeAPIKeysWrite))
+
+	saleCore := sale.NewCore(saledb.NewRepository(cfg.Log, cfg.DB))
+
+	pvh := privacygrp.Handlers{
+		Privacy: privacy.NewCore(cfg.Log, cfg.Worker, usrCore, apiKeyCore, prdCore, saleCore, privacydb.NewRepository(cfg.Log, cfg.DB)),
+		User:    usrCore,
+		Auth:    cfg.Auth,
+	}
+	authed.Handle(http.MethodPost, "/privacy/export", pvh.Export, scope(auth.ScopePrivacyWrite))
+	authed.Handle(http.MethodPost, "/privacy/erasure", pvh.Erase, scope(auth.ScopePrivacyWrite))
+	authed.Handle(http.MethodGet, "/privacy/jobs/:id", pvh.QueryByID, scope(auth.ScopePrivacyRead))
+	authed.Handle(http.MethodGet, "/privacy/jobs/:id/archive", pvh.QueryArchive, scope(auth.ScopePrivacyRead))
+
+	rgh := rolegrp.Handlers{
+		Role: role.NewCore(roledb.NewRepository(cfg.Log, cfg.DB), audCore),
+		Auth: cfg.Auth,
+	}
+	admins.Handle(http.MethodGet, "/roles", rgh.Query, scope(auth.ScopeRolesRead))
+	admins.Handle(http.MethodGet, "/roles/:name", rgh.QueryByName, scope(auth.ScopeRolesRead))
+	admins.Handle(http.MethodPost, "/roles", rgh.Create, scope(auth.ScopeRolesWrite))
+	admins.Handle(http.MethodPut, "/roles/:name", rgh.Update, scope(auth.ScopeRolesWrite))
+	admins.Handle(http.MethodDelete, "/roles/:name", rgh.Delete, scope(auth.ScopeRolesWrite))
+	admins.Handle(http.MethodGet, "/permissions", rgh.QueryPermissions, scope(auth.ScopeRolesRead))
+	admins.Handle(http.MethodPost, "/permissions", rgh.CreatePermission, scope(auth.ScopeRolesWrite))
+	admins.Handle(http.MethodDelete, "/permissions/:name", rgh.DeletePermission, scope(auth.ScopeRolesWrite))
+
+	adh := auditgrp.Handlers{
+		Audit: audCore,
+	}
+	admins.Handle(http.MethodGet, "/audit/:page/:rows", adh.Query, scope(auth.ScopeAuditRead), negotiate)
 }
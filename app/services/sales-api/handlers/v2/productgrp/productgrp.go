@@ -0,0 +1,157 @@
+// Package productgrp maintains the group of handlers for product access.
+package productgrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/product"
+	"github.com/halilylm/micro/business/data/order"
+	"github.com/halilylm/micro/business/web/auth"
+	v1web "github.com/halilylm/micro/business/web/v1"
+	v2web "github.com/halilylm/micro/business/web/v2"
+	"github.com/halilylm/micro/foundation/web"
+	"net/http"
+)
+
+var ErrInvalidID = errors.New("ID is not in its proper form")
+
+// Handlers manages the set of product endpoints.
+type Handlers struct {
+	Product *product.Core
+	Auth    *auth.Auth
+}
+
+// Create adds a new product to the system.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var np product.NewProduct
+	if err := web.Decode(r, &np); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	prod, err := h.Product.Create(ctx, np)
+	if err != nil {
+		return fmt.Errorf("creating new product, np[%+v]: %w", np, err)
+	}
+
+	return v2web.Respond(ctx, w, prod, http.StatusCreated)
+}
+
+// Update updates a product in the system.
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var upd product.UpdateProduct
+	if err := web.Decode(r, &upd); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	prd, err := h.queryOwned(ctx, r, auth.ActionUpdate)
+	if err != nil {
+		return err
+	}
+
+	prd, err = h.Product.Update(ctx, prd, upd)
+	if err != nil {
+		return fmt.Errorf("ID[%s] Product[%+v]: %w", prd.ID, &upd, err)
+	}
+
+	return v2web.Respond(ctx, w, prd, http.StatusOK)
+}
+
+// Delete removes a product from the system. Unlike v1, deleting a product
+// that doesn't exist is reported as not found.
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	prd, err := h.queryOwned(ctx, r, auth.ActionDelete)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Product.Delete(ctx, prd); err != nil {
+		if errors.Is(err, product.ErrInUse) {
+			return v1web.NewRequestError(err, http.StatusConflict)
+		}
+		return fmt.Errorf("ID[%s]: %w", prd.ID, err)
+	}
+
+	return v2web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Query returns a page of products. The next page is requested with the
+// cursor of the response.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	page, err := v2web.GetPage(r)
+	if err != nil {
+		return v1web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	filter, err := getFilter(r)
+	if err != nil {
+		return v1web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	orderBy, err := v1web.GetOrderBy(r, product.DefaultOrderBy)
+	if err != nil {
+		return v1web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	products, err := h.Product.QueryAfter(ctx, filter, orderBy, page.After, page.Limit)
+	if err != nil {
+		if errors.Is(err, product.ErrInvalidOrder) {
+			return v1web.NewRequestError(err, http.StatusBadRequest)
+		}
+		return fmt.Errorf("unable to query for products: %w", err)
+	}
+
+	sortKey := func(prd product.Product) order.Key {
+		return product.SortKey(prd, orderBy.Field)
+	}
+
+	return v2web.RespondPage(ctx, w, products, page, sortKey)
+}
+
+// QueryByID returns a product by its ID.
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	prdID, err := uuid.Parse(web.Param(r, "id"))
+	if err != nil {
+		return v1web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+	}
+
+	prd, err := h.Product.QueryByID(ctx, prdID)
+	if err != nil {
+		switch {
+		case errors.Is(err, product.ErrNotFound):
+			return v1web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", prdID, err)
+		}
+	}
+
+	return v2web.Respond(ctx, w, prd, http.StatusOK)
+}
+
+// queryOwned returns the product of the request when the claims are for
+// its owner or an admin.
+func (h Handlers) queryOwned(ctx context.Context, r *http.Request, action string) (product.Product, error) {
+	prdID, err := uuid.Parse(web.Param(r, "id"))
+	if err != nil {
+		return product.Product{}, v1web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+	}
+
+	prd, err := h.Product.QueryByID(ctx, prdID)
+	if err != nil {
+		switch {
+		case errors.Is(err, product.ErrNotFound):
+			return product.Product{}, v1web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return product.Product{}, fmt.Errorf("query product[%s]: %w", prdID, err)
+		}
+	}
+
+	claims := auth.GetClaims(ctx)
+	res := auth.Resource{Type: auth.ResourceProduct, OwnerID: prd.UserID.String(), Action: action}
+	if err := h.Auth.Authorize(ctx, claims, auth.RuleOwnerOrAdmin, res); err != nil {
+		return product.Product{}, auth.NewAuthError("auth failed")
+	}
+
+	return prd, nil
+}
@@ -0,0 +1,83 @@
+// Package usergrp maintains the group of handlers for user access
+package usergrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/user"
+	"github.com/halilylm/micro/business/data/order"
+	"github.com/halilylm/micro/business/web/auth"
+	v1web "github.com/halilylm/micro/business/web/v1"
+	v2web "github.com/halilylm/micro/business/web/v2"
+	"github.com/halilylm/micro/foundation/web"
+	"net/http"
+)
+
+var ErrInvalidID = errors.New("ID is not in its proper form")
+
+// Handlers manages the set of user endpoints
+type Handlers struct {
+	User *user.Core
+	Auth *auth.Auth
+}
+
+// Query returns a page of users. The next page is requested with the
+// cursor of the response.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	page, err := v2web.GetPage(r)
+	if err != nil {
+		return v1web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	filter, err := getFilter(r)
+	if err != nil {
+		return v1web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	orderBy, err := v1web.GetOrderBy(r, user.DefaultOrderBy)
+	if err != nil {
+		return v1web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	users, err := h.User.QueryAfter(ctx, filter, orderBy, page.After, page.Limit)
+	if err != nil {
+		if errors.Is(err, user.ErrInvalidOrder) {
+			return v1web.NewRequestError(err, http.StatusBadRequest)
+		}
+		return fmt.Errorf("unable to query for users: %w", err)
+	}
+
+	sortKey := func(usr user.User) order.Key {
+		return user.SortKey(usr, orderBy.Field)
+	}
+
+	return v2web.RespondPage(ctx, w, users, page, sortKey)
+}
+
+// QueryByID returns a user by its ID.
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := uuid.Parse(web.Param(r, "id"))
+	if err != nil {
+		return v1web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+	}
+
+	claims := auth.GetClaims(ctx)
+	res := auth.Resource{Type: auth.ResourceUser, OwnerID: userID.String(), Action: auth.ActionRead}
+	if err := h.Auth.Authorize(ctx, claims, auth.RuleOwnerOrAdmin, res); err != nil {
+		return auth.NewAuthError("auth failed")
+	}
+
+	usr, err := h.User.QueryByID(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNotFound):
+			return v1web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", userID, err)
+		}
+	}
+
+	return v2web.Respond(ctx, w, usr, http.StatusOK)
+}
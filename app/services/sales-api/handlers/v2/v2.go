@@ -0,0 +1,76 @@
+// Package v2 contains the full set of handler functions and routes
+// supported by the v2 web api. Responses are sent in an envelope and lists
+// are paged with cursors.
+package v2
+
+import (
+	"github.com/halilylm/micro/app/services/sales-api/handlers/v2/productgrp"
+	"github.com/halilylm/micro/app/services/sales-api/handlers/v2/usergrp"
+	"github.com/halilylm/micro/business/core/audit"
+	"github.com/halilylm/micro/business/core/audit/repository/auditdb"
+	"github.com/halilylm/micro/business/core/product"
+	"github.com/halilylm/micro/business/core/product/repository/productcache"
+	"github.com/halilylm/micro/business/core/user"
+	"github.com/halilylm/micro/business/core/user/repository/usercache"
+	"github.com/halilylm/micro/business/web/auth"
+	"github.com/halilylm/micro/business/web/v1/mid"
+	"github.com/halilylm/micro/foundation/web"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// Config contains all the mandatory systems required by handlers.
+type Config struct {
+	Log          *zap.SugaredLogger
+	Auth         *auth.Auth
+	DB           *sqlx.DB
+	UserCache    *usercache.Repository
+	ProductCache *productcache.Repository
+	AdminMFA     bool
+}
+
+// Routes binds all the version 2 routes.
+func Routes(app *web.App, cfg Config) {
+	const version = "v2"
+
+	authen := mid.Authenticate(cfg.Log, cfg.Auth)
+	admin := mid.Authorize(cfg.Auth, auth.RuleAdminOnly)
+	if cfg.AdminMFA {
+		admin = mid.Authorize(cfg.Auth, auth.RuleAdminOnlyMFA)
+	}
+
+	// Every authenticated route requires a scope so a token limited to some
+	// scopes can't be used anywhere else.
+	scope := func(scope string) web.Middleware {
+		return mid.RequireScope(cfg.Auth, scope)
+	}
+
+	// Only records and lists of them can be asked for in other media types
+	// than JSON, like CSV for spreadsheets or MessagePack.
+	negotiate := web.Negotiate()
+
+	api := app.Group(version, authen)
+	admins := api.Group("", admin)
+
+	audCore := audit.NewCore(auditdb.NewRepository(cfg.Log, cfg.DB))
+
+	ugh := usergrp.Handlers{
+		User: user.NewCore(cfg.UserCache, audCore),
+		Auth: cfg.Auth,
+	}
+	users := api.Group("/users")
+	users.Handle(http.MethodGet, "/:id", ugh.QueryByID, scope(auth.ScopeUsersRead), negotiate)
+	admins.Handle(http.MethodGet, "/users", ugh.Query, scope(auth.ScopeUsersRead), negotiate)
+
+	pgh := productgrp.Handlers{
+		Product: product.NewCore(cfg.ProductCache, audCore),
+		Auth:    cfg.Auth,
+	}
+	products := api.Group("/products")
+	products.Handle(http.MethodGet, "", pgh.Query, scope(auth.ScopeProductsRead), negotiate)
+	products.Handle(http.MethodGet, "/:id", pgh.QueryByID, scope(auth.ScopeProductsRead), negotiate)
+	products.Handle(http.MethodPost, "", pgh.Create, scope(auth.ScopeProductsWrite))
+	products.Handle(http.MethodPut, "/:id", pgh.Update, scope(auth.ScopeProductsWrite))
+	products.Handle(http.MethodDelete, "/:id", pgh.Delete, scope(auth.ScopeProductsWrite))
+}
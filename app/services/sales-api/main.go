@@ -2,16 +2,26 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"expvar"
 	"fmt"
 	"github.com/ardanlabs/conf/v3"
 	"github.com/halilylm/micro/app/services/sales-api/handlers"
+	"github.com/halilylm/micro/business/core/product/repository/productcache"
+	"github.com/halilylm/micro/business/core/product/repository/productdb"
+	"github.com/halilylm/micro/business/core/user/repository/usercache"
+	"github.com/halilylm/micro/business/core/user/repository/userdb"
 	"github.com/halilylm/micro/business/sys/database"
 	"github.com/halilylm/micro/business/web/auth"
 	"github.com/halilylm/micro/business/web/v1/debug"
+	"github.com/halilylm/micro/foundation/cache"
+	"github.com/halilylm/micro/foundation/decisionlog"
+	"github.com/halilylm/micro/foundation/keystore"
 	"github.com/halilylm/micro/foundation/logger"
 	"github.com/halilylm/micro/foundation/vault"
+	"github.com/halilylm/micro/foundation/web"
+	"github.com/halilylm/micro/foundation/worker"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/zipkin"
@@ -24,6 +34,7 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -61,6 +72,52 @@ func run(log *zap.SugaredLogger) error {
 			ShutdownTimeout time.Duration `conf:"default:20s"`
 			APIHost         string        `conf:"default:0.0.0.0:3000"`
 			DebugHost       string        `conf:"default:0.0.0.0:4000"`
+			MaxBodySize     int64         `conf:"default:1048576,help:largest request body in bytes"`
+			V1Deprecated    time.Time     `conf:"default:2026-10-18T00:00:00Z,env:WEB_V1_DEPRECATED,flag:web-v1-deprecated,help:RFC 3339 date the v1 api was deprecated"`
+			V1Sunset        time.Time     `conf:"env:WEB_V1_SUNSET,flag:web-v1-sunset,help:RFC 3339 date the v1 api stops being served"`
+		}
+		Auth struct {
+			AdminMFA           bool          `conf:"default:false"`
+			KeySource          string        `conf:"default:vault,help:where signing keys are read from: vault or fs"`
+			KeysFolder         string        `conf:"default:zarf/keys/"`
+			PolicyPath         string        `conf:"help:directory or bundle with rego policies and data (the embedded ones are used when empty)"`
+			PolicyPollInterval time.Duration `conf:"default:10s"`
+		}
+		OIDC struct {
+			Issuer       string            `conf:"help:external OpenID Connect issuer whose tokens are accepted (federation is off when empty)"`
+			Audience     string            `conf:"help:client id the issuer's tokens must be meant for"`
+			GroupsClaim  string            `conf:"default:groups"`
+			GroupRoles   map[string]string `conf:"help:roles granted to each group like admins:ADMIN;staff:USER with the roles of a group separated by commas"`
+			DefaultRoles []string          `conf:"help:roles granted when none of the groups are mapped"`
+			SyncLinked   bool              `conf:"default:false,help:let the issuer manage the roles of local users linked to it"`
+		}
+		DecisionLog struct {
+			Sink          string        `conf:"default:zap,help:where policy decisions are logged: none or zap or file or http"`
+			SampleRate    float64       `conf:"default:1,help:share of allowed decisions logged (denials are always logged)"`
+			FilePath      string        `conf:"default:decisions.log"`
+			URL           string        `conf:"help:endpoint receiving batches of decisions for the http sink"`
+			BatchSize     int           `conf:"default:100"`
+			FlushInterval time.Duration `conf:"default:5s"`
+		}
+		Worker struct {
+			MaxRunningJobs int `conf:"default:4"`
+		}
+		Cache struct {
+			Backend       string `conf:"default:memory,help:memory or redis"`
+			MaxEntries    int    `conf:"default:10000"`
+			RedisAddress  string `conf:"default:redis-service.sales-system.svc.cluster.local:6379"`
+			RedisPassword string `conf:"mask"`
+			RedisDB       int    `conf:"default:0"`
+			RedisPrefix   string `conf:"default:sales-api"`
+		}
+		UserCache struct {
+			TTL         time.Duration `conf:"default:5m"`
+			NegativeTTL time.Duration `conf:"default:30s"`
+			Key         string        `conf:"mask,help:base64 encoded AES key the cached users are encrypted with"`
+		}
+		ProductCache struct {
+			TTL     time.Duration `conf:"default:5m"`
+			PageTTL time.Duration `conf:"default:30s"`
 		}
 		Vault struct {
 			Address   string `conf:"default:http://vault-service.sales-system.svc.cluster.local:8200"`
@@ -114,7 +171,7 @@ func run(log *zap.SugaredLogger) error {
 	// Database Support
 	log.Infow("startup", "status", "initializing database support", "host", cfg.DB.Host)
 
-	db, err := database.Open(database.Config{
+	dbCfg := database.Config{
 		User:               cfg.DB.User,
 		Password:           cfg.DB.Password,
 		Host:               cfg.DB.Host,
@@ -122,7 +179,9 @@ func run(log *zap.SugaredLogger) error {
 		MaxIdleConnections: cfg.DB.MaxIdleConns,
 		MaxOpenConnections: cfg.DB.MaxOpenConns,
 		DisableTLS:         cfg.DB.DisableTLS,
-	})
+	}
+
+	db, err := database.Open(dbCfg)
 	if err != nil {
 		return fmt.Errorf("connecting to db: %w", err)
 	}
@@ -131,24 +190,205 @@ func run(log *zap.SugaredLogger) error {
 		log.Infow("shutdown", "status", "stopping database support", "host", cfg.DB.Host)
 		db.Close()
 	}()
+
 	// =========================================================================
-	// Initialize authentication support
+	// Cache Support
+
+	log.Infow("startup", "status", "initializing cache support", "backend", cfg.Cache.Backend)
+
+	var redisCaches []*cache.Redis
+	newBackend := func(name string) (cache.Backend, error) {
+		switch cfg.Cache.Backend {
+		case "memory":
+			return cache.NewMemory(cache.MemoryConfig{
+				Name:       name,
+				MaxEntries: cfg.Cache.MaxEntries,
+			}), nil
+		case "redis":
+			rds := cache.NewRedis(cache.RedisConfig{
+				Name:     name,
+				Address:  cfg.Cache.RedisAddress,
+				Password: cfg.Cache.RedisPassword,
+				DB:       cfg.Cache.RedisDB,
+				Prefix:   cfg.Cache.RedisPrefix + ":" + name + ":",
+			})
+			redisCaches = append(redisCaches, rds)
+			return rds, nil
+		}
+		return nil, fmt.Errorf("unknown cache backend %q", cfg.Cache.Backend)
+	}
 
-	log.Infow("startup", "status", "initializing auth support")
+	defer func() {
+		for _, rds := range redisCaches {
+			rds.Close()
+		}
+	}()
 
-	vault, err := vault.New(vault.Config{
-		Address:   cfg.Vault.Address,
-		MountPath: cfg.Vault.MountPath,
-		Token:     cfg.Vault.Token,
+	usrBackend, err := newBackend("usercache")
+	if err != nil {
+		return fmt.Errorf("constructing user cache: %w", err)
+	}
+
+	usrCacheKey, err := base64.StdEncoding.DecodeString(cfg.UserCache.Key)
+	if err != nil {
+		return fmt.Errorf("decoding user cache key: %w", err)
+	}
+
+	usrCache, err := usercache.NewRepository(log, userdb.NewRepository(log, db), usrBackend, usercache.Config{
+		TTL:         cfg.UserCache.TTL,
+		NegativeTTL: cfg.UserCache.NegativeTTL,
+		Key:         usrCacheKey,
 	})
 	if err != nil {
-		return fmt.Errorf("constructing vault: %w", err)
+		return fmt.Errorf("constructing user cache: %w", err)
+	}
+
+	prdBackend, err := newBackend("productcache")
+	if err != nil {
+		return fmt.Errorf("constructing product cache: %w", err)
+	}
+
+	prdCache := productcache.NewRepository(log, productdb.NewRepository(log, db), prdBackend, productcache.Config{
+		TTL:     cfg.ProductCache.TTL,
+		PageTTL: cfg.ProductCache.PageTTL,
+	})
+
+	// Every instance evicts the users changed by the others. After a
+	// reconnect the notifications that were missed are unknown, so the whole
+	// cache is flushed. A shared backend is already consistent, but evicting
+	// twice is cheap.
+	flushUsers := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		usrCache.Flush(ctx)
+	}
+
+	invalidate := func(payload string) {
+		n, err := userdb.ParseNotification(payload)
+		if err != nil {
+			log.Errorw("user cache", "status", "invalid notification", "ERROR", err)
+			flushUsers()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		usrCache.Invalidate(ctx, n.UserID, n.Email)
+	}
+
+	listenCtx, stopListen := context.WithCancel(context.Background())
+	defer stopListen()
+
+	// Without the listener the changes made by other instances go unnoticed,
+	// so subscribing is retried with backoff until it succeeds. The cache is
+	// flushed after every failure since notifications were missed meanwhile.
+	go func() {
+		backoff := time.Second
+		for {
+			err := database.Listen(listenCtx, log, dbCfg, userdb.NotifyChannel, invalidate, flushUsers)
+			if listenCtx.Err() != nil {
+				return
+			}
+
+			log.Errorw("user cache", "status", "listening for invalidations", "retry", backoff, "ERROR", err)
+			flushUsers()
+
+			select {
+			case <-listenCtx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			if backoff *= 2; backoff > time.Minute {
+				backoff = time.Minute
+			}
+		}
+	}()
+
+	// =========================================================================
+	// Initialize authentication support
+
+	log.Infow("startup", "status", "initializing auth support")
+
+	var keyLookup auth.KeyLookup
+	switch cfg.Auth.KeySource {
+	case "vault":
+		vault, err := vault.New(vault.Config{
+			Address:   cfg.Vault.Address,
+			MountPath: cfg.Vault.MountPath,
+			Token:     cfg.Vault.Token,
+		})
+		if err != nil {
+			return fmt.Errorf("constructing vault: %w", err)
+		}
+		keyLookup = vault
+
+	case "fs":
+		ks, err := keystore.NewFS(os.DirFS(cfg.Auth.KeysFolder))
+		if err != nil {
+			return fmt.Errorf("reading keys: %w", err)
+		}
+		keyLookup = ks
+
+	default:
+		return fmt.Errorf("unknown key source %q", cfg.Auth.KeySource)
+	}
+
+	log.Infow("startup", "status", "signing keys loaded", "source", cfg.Auth.KeySource)
+
+	var decisions decisionlog.Logger
+	switch cfg.DecisionLog.Sink {
+	case "none":
+
+	case "zap":
+		decisions = decisionlog.NewZap(log)
+
+	case "file":
+		file, err := decisionlog.NewFile(cfg.DecisionLog.FilePath)
+		if err != nil {
+			return fmt.Errorf("constructing decision log: %w", err)
+		}
+		defer file.Close()
+		decisions = file
+
+	case "http":
+		if cfg.DecisionLog.URL == "" {
+			return errors.New("decision log url is required for the http sink")
+		}
+		h := decisionlog.NewHTTP(log, decisionlog.HTTPConfig{
+			URL:           cfg.DecisionLog.URL,
+			BatchSize:     cfg.DecisionLog.BatchSize,
+			FlushInterval: cfg.DecisionLog.FlushInterval,
+		})
+		defer h.Close()
+		decisions = h
+
+	default:
+		return fmt.Errorf("unknown decision log sink %q", cfg.DecisionLog.Sink)
+	}
+
+	if decisions != nil && cfg.DecisionLog.SampleRate < 1 {
+		decisions = decisionlog.NewSampler(decisions, cfg.DecisionLog.SampleRate)
 	}
 
 	authCfg := auth.Config{
-		Log:       log,
-		DB:        db,
-		KeyLookup: vault,
+		Log:         log,
+		DB:          db,
+		UserRepo:    usrCache,
+		KeyLookup:   keyLookup,
+		PolicyPath:  cfg.Auth.PolicyPath,
+		DecisionLog: decisions,
+		OIDC: auth.OIDCConfig{
+			Issuer:       cfg.OIDC.Issuer,
+			Audience:     cfg.OIDC.Audience,
+			GroupsClaim:  cfg.OIDC.GroupsClaim,
+			GroupRoles:   make(map[string][]string),
+			DefaultRoles: cfg.OIDC.DefaultRoles,
+			SyncLinked:   cfg.OIDC.SyncLinked,
+		},
+	}
+	for group, roles := range cfg.OIDC.GroupRoles {
+		authCfg.OIDC.GroupRoles[group] = strings.Split(roles, ",")
 	}
 
 	auth, err := auth.New(authCfg)
@@ -156,6 +396,25 @@ func run(log *zap.SugaredLogger) error {
 		return fmt.Errorf("constructing auth: %w", err)
 	}
 
+	log.Infow("startup", "status", "auth policy loaded", "policy", auth.PolicyStatus())
+
+	if cfg.Auth.PolicyPath != "" {
+		policyCtx, stopPolicy := context.WithCancel(context.Background())
+		defer stopPolicy()
+
+		go auth.WatchPolicy(policyCtx, cfg.Auth.PolicyPollInterval)
+	}
+
+	// =========================================================================
+	// Start Worker Support
+
+	log.Infow("startup", "status", "initializing worker support", "maxRunningJobs", cfg.Worker.MaxRunningJobs)
+
+	wrk, err := worker.New(cfg.Worker.MaxRunningJobs)
+	if err != nil {
+		return fmt.Errorf("constructing worker: %w", err)
+	}
+
 	// =========================================================================
 	// Start Tracing Support
 
@@ -177,7 +436,7 @@ func run(log *zap.SugaredLogger) error {
 	// Start Debug Service
 	log.Infow("startup", "status", "debug v1 router started", "host", cfg.Web.DebugHost)
 	go func() {
-		if err := http.ListenAndServe(cfg.Web.DebugHost, debug.Mux(build, log, db)); err != nil {
+		if err := http.ListenAndServe(cfg.Web.DebugHost, debug.Mux(build, log, db, auth)); err != nil {
 			log.Errorw("shutdown", "status", "debug v1 router closed", "host", cfg.Web.DebugHost, "ERROR", err)
 		}
 	}()
@@ -190,11 +449,18 @@ func run(log *zap.SugaredLogger) error {
 	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
 
 	apiMux := handlers.APIMux(handlers.APIMuxConfig{
-		Shutdown: shutdown,
-		Log:      log,
-		Auth:     auth,
-		DB:       db,
-		Tracer:   tracer,
+		Shutdown:     shutdown,
+		Log:          log,
+		Auth:         auth,
+		DB:           db,
+		Tracer:       tracer,
+		Worker:       wrk,
+		UserCache:    usrCache,
+		ProductCache: prdCache,
+		AdminMFA:     cfg.Auth.AdminMFA,
+		MaxBodySize:  cfg.Web.MaxBodySize,
+		V1Deprecated: cfg.Web.V1Deprecated,
+		V1Sunset:     cfg.Web.V1Sunset,
 	})
 
 	api := http.Server{
@@ -204,6 +470,7 @@ func run(log *zap.SugaredLogger) error {
 		WriteTimeout: cfg.Web.WriteTimeout,
 		IdleTimeout:  cfg.Web.IdleTimeout,
 		ErrorLog:     zap.NewStdLog(log.Desugar()),
+		ConnContext:  web.ConnContext,
 	}
 
 	serverErrors := make(chan error, 1)
@@ -229,6 +496,11 @@ func run(log *zap.SugaredLogger) error {
 			api.Close()
 			return fmt.Errorf("could not stop server gracefully: %w", err)
 		}
+
+		log.Infow("shutdown", "status", "stopping worker support", "running", wrk.Running())
+		if err := wrk.Shutdown(ctx); err != nil {
+			return fmt.Errorf("could not stop worker gracefully: %w", err)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/apikey"
+	"github.com/halilylm/micro/business/core/apikey/repository/apikeydb"
+	"github.com/halilylm/micro/business/core/audit"
+	"github.com/halilylm/micro/business/core/audit/repository/auditdb"
+	"github.com/halilylm/micro/business/core/user"
+	"github.com/halilylm/micro/business/core/user/repository/userdb"
+	"github.com/halilylm/micro/business/sys/database"
+	"github.com/halilylm/micro/business/web/auth"
+	"go.uber.org/zap"
+	"os"
+	"strings"
+	"time"
+)
+
+// APIKey manages the api keys issued to users. The sub command decides
+// whether a key is created, listed or revoked.
+func APIKey(log *zap.SugaredLogger, cfg database.Config, args []string) error {
+	help := func() error {
+		fmt.Println("help: apikey create <user_id> <name> <roles,...> [ttl|0] [scopes,...]")
+		fmt.Println("      apikey list <user_id>")
+		fmt.Println("      apikey revoke <api_key_id>")
+		return ErrHelp
+	}
+
+	if len(args) < 2 {
+		return help()
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	audCore := audit.NewCore(auditdb.NewRepository(log, db))
+	usrCore := user.NewCore(userdb.NewRepository(log, db), audCore)
+	core := apikey.NewCore(usrCore, audCore, apikeydb.NewRepository(log, db))
+
+	switch args[0] {
+	case "create":
+		if len(args) < 4 {
+			return help()
+		}
+
+		userID, err := uuid.Parse(args[1])
+		if err != nil {
+			return fmt.Errorf("parsing user id: %w", err)
+		}
+
+		nk := apikey.NewAPIKey{
+			UserID: userID,
+			Name:   args[2],
+			Roles:  strings.Split(args[3], ","),
+		}
+
+		if len(args) > 4 && args[4] != "0" {
+			ttl, err := time.ParseDuration(args[4])
+			if err != nil {
+				return fmt.Errorf("parsing ttl: %w", err)
+			}
+			nk.DateExpires = time.Now().Add(ttl)
+		}
+
+		if len(args) > 5 {
+			nk.Scopes = strings.Split(args[5], ",")
+			if err := auth.CheckScopes(nk.Scopes); err != nil {
+				return err
+			}
+		}
+
+		key, err := core.Create(ctx, nk)
+		if err != nil {
+			return fmt.Errorf("create api key: %w", err)
+		}
+
+		fmt.Println("api key id:", key.ID)
+		fmt.Printf("-----BEGIN API KEY-----\n%s\n-----END API KEY-----\n", key.Key)
+		fmt.Println("store this key now, it can't be retrieved again")
+
+	case "list":
+		userID, err := uuid.Parse(args[1])
+		if err != nil {
+			return fmt.Errorf("parsing user id: %w", err)
+		}
+
+		keys, err := core.QueryByUserID(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("retrieve api keys: %w", err)
+		}
+
+		return json.NewEncoder(os.Stdout).Encode(keys)
+
+	case "revoke":
+		keyID, err := uuid.Parse(args[1])
+		if err != nil {
+			return fmt.Errorf("parsing api key id: %w", err)
+		}
+
+		key, err := core.QueryByID(ctx, keyID)
+		if err != nil {
+			return fmt.Errorf("retrieve api key: %w", err)
+		}
+
+		if err := core.Delete(ctx, key); err != nil {
+			return fmt.Errorf("revoke api key: %w", err)
+		}
+
+		fmt.Println("api key revoked:", key.ID)
+
+	default:
+		return help()
+	}
+
+	return nil
+}
@@ -1,6 +1,10 @@
 package commands
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -9,12 +13,20 @@ import (
 	"os"
 )
 
+// Set of key types that can be generated. ECDSA keys use the P-256 curve
+// for ES256 and Ed25519 keys are used for EdDSA.
+const (
+	KeyTypeRSA     = "rsa"
+	KeyTypeECDSA   = "ecdsa"
+	KeyTypeEd25519 = "ed25519"
+)
+
 // GenKey creates a x509 private/public key for auth tokens.
-func GenKey() error {
-	// Generate a new private key
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+func GenKey(keyType string) error {
+	// Generate a new private key in PEM form.
+	privateKey, privatePEM, err := generateKey(keyType)
 	if err != nil {
-		return fmt.Errorf("generating key: %w", err)
+		return err
 	}
 
 	// Create a file for the private key information in PEM form.
@@ -24,19 +36,13 @@ func GenKey() error {
 	}
 	defer privateFile.Close()
 
-	// Construct a PEM block for the private key.
-	privateBlock := pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
-	}
-
 	// Write the private key to private key file
-	if err := pem.Encode(privateFile, &privateBlock); err != nil {
+	if _, err := privateFile.Write(privatePEM); err != nil {
 		return fmt.Errorf("encoding to private file: %w", err)
 	}
 
 	// Marshal the public key from the private PKIX
-	asn1Bytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	asn1Bytes, err := x509.MarshalPKIXPublicKey(privateKey.Public())
 	if err != nil {
 		return fmt.Errorf("marshaling public key: %w", err)
 	}
@@ -59,6 +65,61 @@ func GenKey() error {
 		return fmt.Errorf("encoding to public file: %w", err)
 	}
 
-	fmt.Println("private and public key generated")
+	fmt.Printf("%s private and public key generated\n", keyType)
 	return nil
-}
\ No newline at end of file
+}
+
+// generateKey creates a private key of the specified type and encodes it
+// in PEM form.
+func generateKey(keyType string) (crypto.Signer, []byte, error) {
+	var privateKey crypto.Signer
+	var privateBlock pem.Block
+
+	switch keyType {
+	case KeyTypeRSA, "":
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, nil, fmt.Errorf("generating key: %w", err)
+		}
+		privateKey = key
+		privateBlock = pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		}
+
+	case KeyTypeECDSA:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("generating key: %w", err)
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("marshaling private key: %w", err)
+		}
+		privateKey = key
+		privateBlock = pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: der,
+		}
+
+	case KeyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("generating key: %w", err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("marshaling private key: %w", err)
+		}
+		privateKey = key
+		privateBlock = pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: der,
+		}
+
+	default:
+		return nil, nil, fmt.Errorf("unknown key type %q, must be %s, %s or %s", keyType, KeyTypeRSA, KeyTypeECDSA, KeyTypeEd25519)
+	}
+
+	return privateKey, pem.EncodeToMemory(&privateBlock), nil
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/audit"
+	"github.com/halilylm/micro/business/core/audit/repository/auditdb"
 	"github.com/halilylm/micro/business/core/user"
 	"github.com/halilylm/micro/business/core/user/repository/userdb"
 	"github.com/halilylm/micro/business/sys/database"
@@ -15,11 +17,7 @@ import (
 )
 
 // GenToken generates a JWT for he specified user.
-func GenToken(log *zap.SugaredLogger, dbConfig database.Config, vaultConfig vault.Config, userID uuid.UUID, kid string) error {
-	if kid == "" {
-		fmt.Println("help: gentoken <user_id> <kid>")
-		return ErrHelp
-	}
+func GenToken(log *zap.SugaredLogger, dbConfig database.Config, vaultConfig vault.Config, userID uuid.UUID) error {
 	db, err := database.Open(dbConfig)
 	if err != nil {
 		return fmt.Errorf("connect database: %w", err)
@@ -29,7 +27,7 @@ func GenToken(log *zap.SugaredLogger, dbConfig database.Config, vaultConfig vaul
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	core := user.NewCore(userdb.NewRepository(log, db))
+	core := user.NewCore(userdb.NewRepository(log, db), audit.NewCore(auditdb.NewRepository(log, db)))
 
 	usr, err := core.QueryByID(ctx, userID)
 	if err != nil {
@@ -75,9 +73,9 @@ func GenToken(log *zap.SugaredLogger, dbConfig database.Config, vaultConfig vaul
 
 	// This will generate a JWT with the claims embedded in them. The database
 	// with need to be configured with the information found in the public key
-	// file to validate these claims. Dgraph does not support key rotate at
-	// this time.
-	token, err := a.GenerateToken(kid, claims)
+	// file to validate these claims. The token is signed with the active key
+	// of the key set.
+	token, err := a.GenerateToken(claims)
 	if err != nil {
 		return fmt.Errorf("generating token: %w", err)
 	}
@@ -0,0 +1,156 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/foundation/keyset"
+	"github.com/halilylm/micro/foundation/vault"
+	"os"
+	"time"
+)
+
+// defaultRetireAfter is how long a replaced key keeps verifying tokens. It
+// has to outlive the tokens signed with it.
+const defaultRetireAfter = 24 * time.Hour
+
+// defaultActivateAfter is how long a new key is only published before it
+// signs tokens. It has to outlive the time clients cache the published
+// keys, which is five minutes.
+const defaultActivateAfter = 10 * time.Minute
+
+// Keys manages the key set used to sign tokens. The sub command decides
+// whether the set is listed, a new key is rotated in or a key is retired.
+func Keys(vaultConfig vault.Config, args []string) error {
+	help := func() error {
+		fmt.Println("help: keys list")
+		fmt.Println("      keys rotate [retire_after] [rsa|ecdsa|ed25519] [activate_after]")
+		fmt.Println("      keys retire <kid> [retire_after]")
+		return ErrHelp
+	}
+
+	if len(args) < 1 {
+		return help()
+	}
+
+	vault, err := vault.New(vaultConfig)
+	if err != nil {
+		return fmt.Errorf("constructing vault: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	set, version, err := vault.KeySet(ctx)
+	if err != nil {
+		return fmt.Errorf("retrieve key set: %w", err)
+	}
+
+	retireAfter := defaultRetireAfter
+	parseRetireAfter := func(arg string) error {
+		if arg == "" {
+			return nil
+		}
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return fmt.Errorf("parsing retire after: %w", err)
+		}
+		retireAfter = d
+		return nil
+	}
+
+	now := time.Now().UTC()
+
+	switch args[0] {
+	case "list":
+		return json.NewEncoder(os.Stdout).Encode(set)
+
+	case "rotate":
+		if len(args) > 1 {
+			if err := parseRetireAfter(args[1]); err != nil {
+				return err
+			}
+		}
+
+		var keyType string
+		if len(args) > 2 {
+			keyType = args[2]
+		}
+
+		activateAfter := defaultActivateAfter
+		if len(args) > 3 {
+			d, err := time.ParseDuration(args[3])
+			if err != nil {
+				return fmt.Errorf("parsing activate after: %w", err)
+			}
+			activateAfter = d
+		}
+
+		// The keys loaded before there was a key set keep signing until the
+		// new key is activated and then retire like a replaced key.
+		if version == 0 {
+			kids, err := vault.ActiveKIDs()
+			if err != nil {
+				return fmt.Errorf("retrieve kids: %w", err)
+			}
+			for _, kid := range kids {
+				set.Keys = append(set.Keys, keyset.Key{
+					KID:         kid,
+					Status:      keyset.StatusActive,
+					DateCreated: now,
+				})
+			}
+		}
+
+		kid := uuid.NewString()
+
+		_, privatePEM, err := generateKey(keyType)
+		if err != nil {
+			return err
+		}
+
+		if err := vault.AddPrivateKey(ctx, kid, privatePEM); err != nil {
+			return fmt.Errorf("store private key: %w", err)
+		}
+
+		set, err = set.Rotate(kid, now, activateAfter, retireAfter)
+		if err != nil {
+			return fmt.Errorf("rotate: %w", err)
+		}
+
+		if err := vault.StoreKeySet(ctx, set, version); err != nil {
+			return fmt.Errorf("store key set: %w", err)
+		}
+
+		fmt.Println("new kid:", kid)
+		fmt.Println("signs from:", now.Add(activateAfter).Format(time.RFC3339))
+
+	case "retire":
+		if len(args) < 2 {
+			return help()
+		}
+		retireAfter = 0
+		if len(args) > 2 {
+			if err := parseRetireAfter(args[2]); err != nil {
+				return err
+			}
+		}
+
+		set, err = set.Retire(args[1], now.Add(retireAfter))
+		if err != nil {
+			return fmt.Errorf("retire: %w", err)
+		}
+
+		if err := vault.StoreKeySet(ctx, set, version); err != nil {
+			return fmt.Errorf("store key set: %w", err)
+		}
+
+		fmt.Println("kid retires at:", now.Add(retireAfter).Format(time.RFC3339))
+
+	default:
+		return help()
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/halilylm/micro/foundation/oidcstub"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// OIDCStub runs a stand-in OpenID Connect issuer for local development until
+// it's interrupted. Point SALES_OIDC_ISSUER and SALES_OIDC_AUDIENCE of the
+// sales-api at it.
+func OIDCStub(addr string, audience string) error {
+	if addr == "" {
+		addr = "localhost:9000"
+	}
+	if audience == "" {
+		audience = "sales-api"
+	}
+
+	s, err := oidcstub.Start(addr, audience)
+	if err != nil {
+		return fmt.Errorf("starting issuer: %w", err)
+	}
+	defer s.Close()
+
+	fmt.Println("issuer:  ", s.Issuer())
+	fmt.Println("audience:", audience)
+	fmt.Printf("token:    curl '%s/token?sub=jdoe&email=jdoe@example.com&name=John+Doe&groups=staff'\n", s.Issuer())
+
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
+	<-shutdown
+
+	return nil
+}
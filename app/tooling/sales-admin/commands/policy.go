@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/halilylm/micro/business/web/auth"
+	"os"
+	"text/tabwriter"
+)
+
+// defaultPolicyFixtures is where the cases for the embedded policy live.
+const defaultPolicyFixtures = "business/web/auth/rego/testdata"
+
+// Policy works with the auth policies. The test sub command runs the
+// fixtures against the embedded policy, or the one at the policy path, and
+// prints the failed cases and the coverage of every rule.
+func Policy(args []string) error {
+	help := func() error {
+		fmt.Println("help: policy test [fixtures] [policy_path]")
+		return ErrHelp
+	}
+
+	if len(args) < 1 || args[0] != "test" {
+		return help()
+	}
+
+	fixtures := defaultPolicyFixtures
+	if len(args) > 1 && args[1] != "" {
+		fixtures = args[1]
+	}
+
+	var policyPath string
+	if len(args) > 2 {
+		policyPath = args[2]
+	}
+
+	suites, err := auth.LoadPolicySuites(fixtures)
+	if err != nil {
+		return err
+	}
+
+	report, err := auth.RunPolicyTests(context.Background(), policyPath, suites)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("policy %s revision %s\n\n", report.Source, report.Revision)
+
+	failed := report.Failed()
+	for _, res := range failed {
+		if res.Err != nil {
+			fmt.Printf("FAIL %s: %s: rule %s: %s\n", res.File, res.Case.Name, res.Case.Rule, res.Err)
+			continue
+		}
+		fmt.Printf("FAIL %s: %s: rule %s allowed %v, expected %v\n", res.File, res.Case.Name, res.Case.Rule, res.Allowed, res.Case.Allow)
+	}
+	if len(failed) > 0 {
+		fmt.Println()
+	}
+
+	var covered int
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "RULE\tALLOW\tDENY\tCOVERED")
+	for _, cov := range report.Coverage {
+		if cov.Covered() {
+			covered++
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%v\n", cov.Rule, cov.Allow, cov.Deny, cov.Covered())
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d cases, %d failed, %d of %d rules covered\n", len(report.Results), len(failed), covered, len(report.Coverage))
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d cases failed", len(failed), len(report.Results))
+	}
+
+	return nil
+}
@@ -3,6 +3,8 @@ package commands
 import (
 	"context"
 	"fmt"
+	"github.com/halilylm/micro/business/core/audit"
+	"github.com/halilylm/micro/business/core/audit/repository/auditdb"
 	"github.com/halilylm/micro/business/core/user"
 	"github.com/halilylm/micro/business/core/user/repository/userdb"
 	"github.com/halilylm/micro/business/sys/database"
@@ -27,7 +29,7 @@ func UserAdd(log *zap.SugaredLogger, cfg database.Config, name, email, password
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	core := user.NewCore(userdb.NewRepository(log, db))
+	core := user.NewCore(userdb.NewRepository(log, db), audit.NewCore(auditdb.NewRepository(log, db)))
 
 	addr, err := mail.ParseAddress(email)
 	if err != nil {
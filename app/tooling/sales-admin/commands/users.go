@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/halilylm/micro/business/core/audit"
+	"github.com/halilylm/micro/business/core/audit/repository/auditdb"
 	"github.com/halilylm/micro/business/core/user"
 	"github.com/halilylm/micro/business/core/user/repository/userdb"
 	"github.com/halilylm/micro/business/sys/database"
@@ -33,7 +35,7 @@ func Users(log *zap.SugaredLogger, cfg database.Config, pageNumber string, rowsP
 		return fmt.Errorf("converting rows per page: %w", err)
 	}
 
-	core := user.NewCore(userdb.NewRepository(log, db))
+	core := user.NewCore(userdb.NewRepository(log, db), audit.NewCore(auditdb.NewRepository(log, db)))
 
 	users, err := core.Query(ctx, user.QueryFilter{}, user.DefaultOrderBy, page, rows)
 	if err != nil {
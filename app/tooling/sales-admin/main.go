@@ -117,8 +117,13 @@ func processCommands(args conf.Args, log *zap.SugaredLogger, cfg config) error {
 			return fmt.Errorf("getting users: %w", err)
 		}
 
+	case "apikey":
+		if err := commands.APIKey(log, dbConfig, args[1:]); err != nil {
+			return fmt.Errorf("managing api keys: %w", err)
+		}
+
 	case "genkey":
-		if err := commands.GenKey(); err != nil {
+		if err := commands.GenKey(args.Num(1)); err != nil {
 			return fmt.Errorf("key generation: %w", err)
 		}
 
@@ -127,11 +132,25 @@ func processCommands(args conf.Args, log *zap.SugaredLogger, cfg config) error {
 		if err != nil {
 			return fmt.Errorf("generating token: %w", err)
 		}
-		kid := args.Num(2)
-		if err := commands.GenToken(log, dbConfig, vaultConfig, userID, kid); err != nil {
+		if err := commands.GenToken(log, dbConfig, vaultConfig, userID); err != nil {
 			return fmt.Errorf("generating token: %w", err)
 		}
 
+	case "keys":
+		if err := commands.Keys(vaultConfig, args[1:]); err != nil {
+			return fmt.Errorf("managing signing keys: %w", err)
+		}
+
+	case "oidc-stub":
+		if err := commands.OIDCStub(args.Num(1), args.Num(2)); err != nil {
+			return fmt.Errorf("running oidc issuer: %w", err)
+		}
+
+	case "policy":
+		if err := commands.Policy(args[1:]); err != nil {
+			return fmt.Errorf("testing policy: %w", err)
+		}
+
 	case "vault":
 		if err := commands.Vault(vaultConfig, cfg.Vault.KeysFolder); err != nil {
 			return fmt.Errorf("setting private key: %w", err)
@@ -147,8 +166,12 @@ func processCommands(args conf.Args, log *zap.SugaredLogger, cfg config) error {
 		fmt.Println("seed:       add data to the database")
 		fmt.Println("useradd:    add a new user to the database")
 		fmt.Println("users:      get a list of users from the database")
-		fmt.Println("genkey:     generate a set of private/public key files")
+		fmt.Println("apikey:     create, list or revoke api keys for a user")
+		fmt.Println("genkey:     generate a set of private/public key files [rsa|ecdsa|ed25519]")
 		fmt.Println("gentoken:   generate a JWT for a user with claims")
+		fmt.Println("keys:       list or rotate the token signing keys in vault")
+		fmt.Println("oidc-stub:  run a local OpenID Connect issuer [addr] [audience]")
+		fmt.Println("policy:     run the policy test fixtures [fixtures] [policy_path]")
 		fmt.Println("vault:      load private keys into vault system")
 		fmt.Println("vault-init: initialize a new vault instance")
 		fmt.Println("provide a command to get more help.")
@@ -0,0 +1,49 @@
+package apikey
+
+import (
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/sys/validate"
+	"time"
+)
+
+// APIKey represents a long-lived credential issued to a user for
+// non-interactive access to the API.
+type APIKey struct {
+	ID          uuid.UUID `json:"id"`
+	UserID      uuid.UUID `json:"user_id"`
+	Name        string    `json:"name"`
+	Prefix      string    `json:"prefix"`
+	KeyHash     string    `json:"-"`
+	Roles       []string  `json:"roles"`
+	Scopes      []string  `json:"scopes"`
+	DateExpires time.Time `json:"date_expires"`
+	DateCreated time.Time `json:"date_created"`
+}
+
+// Expired reports whether the key has an expiry that has already passed.
+func (k APIKey) Expired(now time.Time) bool {
+	return !k.DateExpires.IsZero() && now.After(k.DateExpires)
+}
+
+// NewAPIKey contains information needed to create a new APIKey. A zero
+// DateExpires means the key never expires and no Scopes mean the key isn't
+// limited beyond its roles.
+type NewAPIKey struct {
+	UserID      uuid.UUID `json:"user_id" validate:"required"`
+	Name        string    `json:"name" validate:"required"`
+	Roles       []string  `json:"roles" validate:"required,min=1"`
+	Scopes      []string  `json:"scopes"`
+	DateExpires time.Time `json:"date_expires"`
+}
+
+// Validate checks the NewAPIKey against its validate tags.
+func (nk NewAPIKey) Validate() error {
+	return validate.Check(nk)
+}
+
+// CreatedAPIKey is returned once when a key is created. It is the only time
+// the plain text key is available since only its hash is stored.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,165 @@
+// Package apikeydb contains api key related CRUD functionality.
+package apikeydb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/apikey"
+	"github.com/halilylm/micro/business/sys/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Repository manages the set of APIs for api key database access.
+type Repository struct {
+	log    *zap.SugaredLogger
+	db     sqlx.ExtContext
+	inTran bool
+}
+
+// NewRepository constructs the api for data access.
+func NewRepository(log *zap.SugaredLogger, db *sqlx.DB) *Repository {
+	if log == nil {
+		log = zap.NewNop().Sugar()
+	}
+	return &Repository{
+		log: log,
+		db:  db,
+	}
+}
+
+// WithinTran runs passed function and do commit/rollback at the end.
+func (r *Repository) WithinTran(ctx context.Context, fn func(ctx context.Context, s apikey.Repository) error) error {
+	if r.inTran {
+		return fn(ctx, r)
+	}
+
+	f := func(ctx context.Context, tx *sqlx.Tx) error {
+		s := &Repository{
+			log:    r.log,
+			db:     tx,
+			inTran: true,
+		}
+		return fn(ctx, s)
+	}
+
+	return database.WithinTran(ctx, r.log, r.db.(*sqlx.DB), f)
+}
+
+// Create inserts a new api key into the database.
+func (r *Repository) Create(ctx context.Context, key apikey.APIKey) error {
+	const q = `
+	INSERT INTO api_keys
+		(api_key_id, user_id, name, prefix, key_hash, roles, scopes, date_expires, date_created)
+	VALUES
+		(:api_key_id, :user_id, :name, :prefix, :key_hash, :roles, :scopes, :date_expires, :date_created)`
+
+	if err := database.NamedExecContext(ctx, r.log, r.db, q, toDBAPIKey(key)); err != nil {
+		return fmt.Errorf("inserting api key: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes an api key from the database.
+func (r *Repository) Delete(ctx context.Context, key apikey.APIKey) error {
+	data := struct {
+		ID string `db:"api_key_id"`
+	}{
+		ID: key.ID.String(),
+	}
+
+	const q = `
+	DELETE FROM
+		api_keys
+	WHERE
+		api_key_id = :api_key_id`
+
+	if err := database.NamedExecContext(ctx, r.log, r.db, q, data); err != nil {
+		return fmt.Errorf("deleting api keyID[%s]: %w", key.ID, err)
+	}
+
+	return nil
+}
+
+// QueryByID gets the specified api key from the database.
+func (r *Repository) QueryByID(ctx context.Context, keyID uuid.UUID) (apikey.APIKey, error) {
+	data := struct {
+		ID string `db:"api_key_id"`
+	}{
+		ID: keyID.String(),
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		api_keys
+	WHERE
+		api_key_id = :api_key_id`
+
+	var key dbAPIKey
+	if err := database.NamedQueryStruct(ctx, r.log, r.db, q, data, &key); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return apikey.APIKey{}, apikey.ErrNotFound
+		}
+		return apikey.APIKey{}, fmt.Errorf("selecting api keyID[%q]: %w", keyID, err)
+	}
+
+	return toCoreAPIKey(key), nil
+}
+
+// QueryByUserID gets the api keys that belong to the specified user.
+func (r *Repository) QueryByUserID(ctx context.Context, userID uuid.UUID) ([]apikey.APIKey, error) {
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID.String(),
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		api_keys
+	WHERE
+		user_id = :user_id
+	ORDER BY
+		date_created`
+
+	var keys []dbAPIKey
+	if err := database.NamedQuerySlice(ctx, r.log, r.db, q, data, &keys); err != nil {
+		return nil, fmt.Errorf("selecting api keys userID[%s]: %w", userID, err)
+	}
+
+	return toCoreAPIKeySlice(keys), nil
+}
+
+// QueryByHash gets the api key matching the specified key hash.
+func (r *Repository) QueryByHash(ctx context.Context, hash string) (apikey.APIKey, error) {
+	data := struct {
+		KeyHash string `db:"key_hash"`
+	}{
+		KeyHash: hash,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		api_keys
+	WHERE
+		key_hash = :key_hash`
+
+	var key dbAPIKey
+	if err := database.NamedQueryStruct(ctx, r.log, r.db, q, data, &key); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return apikey.APIKey{}, apikey.ErrNotFound
+		}
+		return apikey.APIKey{}, fmt.Errorf("selecting api key by hash: %w", err)
+	}
+
+	return toCoreAPIKey(key), nil
+}
@@ -0,0 +1,74 @@
+package apikeydb
+
+import (
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/apikey"
+	"github.com/lib/pq"
+	"time"
+)
+
+// dbAPIKey represent the structure we need for moving data
+// between the app and the database.
+type dbAPIKey struct {
+	ID          uuid.UUID      `db:"api_key_id"`
+	UserID      uuid.UUID      `db:"user_id"`
+	Name        string         `db:"name"`
+	Prefix      string         `db:"prefix"`
+	KeyHash     string         `db:"key_hash"`
+	Roles       pq.StringArray `db:"roles"`
+	Scopes      pq.StringArray `db:"scopes"`
+	DateExpires sql.NullTime   `db:"date_expires"`
+	DateCreated time.Time      `db:"date_created"`
+}
+
+func toDBAPIKey(key apikey.APIKey) dbAPIKey {
+
+	// The column can't be null, a key without scopes has an empty list.
+	scopes := key.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	return dbAPIKey{
+		ID:      key.ID,
+		UserID:  key.UserID,
+		Name:    key.Name,
+		Prefix:  key.Prefix,
+		KeyHash: key.KeyHash,
+		Roles:   key.Roles,
+		Scopes:  scopes,
+		DateExpires: sql.NullTime{
+			Time:  key.DateExpires.UTC(),
+			Valid: !key.DateExpires.IsZero(),
+		},
+		DateCreated: key.DateCreated.UTC(),
+	}
+}
+
+func toCoreAPIKey(dbKey dbAPIKey) apikey.APIKey {
+	key := apikey.APIKey{
+		ID:          dbKey.ID,
+		UserID:      dbKey.UserID,
+		Name:        dbKey.Name,
+		Prefix:      dbKey.Prefix,
+		KeyHash:     dbKey.KeyHash,
+		Roles:       dbKey.Roles,
+		Scopes:      dbKey.Scopes,
+		DateCreated: dbKey.DateCreated.In(time.Local),
+	}
+
+	if dbKey.DateExpires.Valid {
+		key.DateExpires = dbKey.DateExpires.Time.In(time.Local)
+	}
+
+	return key
+}
+
+func toCoreAPIKeySlice(dbKeys []dbAPIKey) []apikey.APIKey {
+	keys := make([]apikey.APIKey, len(dbKeys))
+	for i, dbKey := range dbKeys {
+		keys[i] = toCoreAPIKey(dbKey)
+	}
+	return keys
+}
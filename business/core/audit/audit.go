@@ -0,0 +1,138 @@
+// Package audit provides the core business API for recording who changed
+// what in the system.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/sys/validate"
+	"github.com/halilylm/micro/foundation/web"
+	"reflect"
+	"time"
+)
+
+// ActorSystem is recorded when a mutation isn't made on behalf of an
+// authenticated caller, like from the admin tooling.
+const ActorSystem = "system"
+
+// Repository interface declares the behaviour this package needs to persist
+// and retrieve data.
+type Repository interface {
+	Create(ctx context.Context, entry Entry) error
+	Redact(ctx context.Context, entity string, entityID string) error
+	Query(ctx context.Context, filter QueryFilter, pageNumber int, rowsPerPage int) ([]Entry, error)
+}
+
+// Core manages the set of APIs for audit access.
+type Core struct {
+	repo Repository
+}
+
+// NewCore constructs a core for audit api access.
+func NewCore(repo Repository) *Core {
+	return &Core{repo: repo}
+}
+
+// Record stores an entry for the mutation of an entity. The before and after
+// values are compared through their JSON form, so fields that are hidden from
+// JSON, like secrets, are never recorded.
+func (c *Core) Record(ctx context.Context, action string, entity string, entityID string, before any, after any) error {
+	diff, err := Diff(before, after)
+	if err != nil {
+		return fmt.Errorf("diff: %w", err)
+	}
+
+	entry := Entry{
+		ID:          uuid.New(),
+		Actor:       GetActor(ctx),
+		OnBehalfOf:  GetOnBehalfOf(ctx),
+		Action:      action,
+		Entity:      entity,
+		EntityID:    entityID,
+		Diff:        diff,
+		TraceID:     web.GetTraceID(ctx),
+		DateCreated: time.Now(),
+	}
+
+	if err := c.repo.Create(ctx, entry); err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+
+	return nil
+}
+
+// Redact removes the recorded values for an entity while keeping the
+// entries themselves. It's used when personal data has to be erased.
+func (c *Core) Redact(ctx context.Context, entity string, entityID string) error {
+	if err := c.repo.Redact(ctx, entity, entityID); err != nil {
+		return fmt.Errorf("redact: %w", err)
+	}
+
+	return nil
+}
+
+// Query retrieves a list of audit entries, newest first.
+func (c *Core) Query(ctx context.Context, filter QueryFilter, pageNumber int, rowsPerPage int) ([]Entry, error) {
+	if err := validate.Check(filter); err != nil {
+		return nil, fmt.Errorf("validating filter: %w", err)
+	}
+
+	entries, err := c.repo.Query(ctx, filter, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return entries, nil
+}
+
+// Diff returns the fields that differ between the JSON forms of before and
+// after. Either value can be nil.
+func Diff(before any, after any) (map[string]Change, error) {
+	b, err := toMap(before)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := toMap(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := make(map[string]Change)
+	for k, bv := range b {
+		av, exists := a[k]
+		if !exists || !reflect.DeepEqual(bv, av) {
+			diff[k] = Change{Before: bv, After: av}
+		}
+	}
+	for k, av := range a {
+		if _, exists := b[k]; !exists {
+			diff[k] = Change{Before: nil, After: av}
+		}
+	}
+
+	return diff, nil
+}
+
+// =============================================================================
+
+// toMap converts the value into its JSON object form.
+func toMap(v any) (map[string]any, error) {
+	if v == nil {
+		return map[string]any{}, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+
+	m := make(map[string]any)
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,42 @@
+package audit
+
+import (
+	"context"
+)
+
+// ctxKey represents the type of value for the context key.
+type ctxKey int
+
+// Keys used to store/retrieve the actor and the impersonated user from a
+// context.Context.
+const (
+	key           ctxKey = 1
+	onBehalfOfKey ctxKey = 2
+)
+
+// SetActor stores the actor that is responsible for the mutations made with
+// the context.
+func SetActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, key, actor)
+}
+
+// GetActor returns the actor from the context or ActorSystem if none is set.
+func GetActor(ctx context.Context) string {
+	v, ok := ctx.Value(key).(string)
+	if !ok || v == "" {
+		return ActorSystem
+	}
+	return v
+}
+
+// SetOnBehalfOf stores the user the actor is impersonating.
+func SetOnBehalfOf(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, onBehalfOfKey, userID)
+}
+
+// GetOnBehalfOf returns the user the actor is impersonating or an empty
+// string when the actor acts as themselves.
+func GetOnBehalfOf(ctx context.Context) string {
+	v, _ := ctx.Value(onBehalfOfKey).(string)
+	return v
+}
@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"time"
+)
+
+// QueryFilter holds the available fields filters to search
+// for audit entries on the database.
+type QueryFilter struct {
+	Actor      *string
+	OnBehalfOf *string
+	Action     *string `validate:"omitempty,oneof=create update delete anonymize revoke impersonation_start impersonation_end"`
+	Entity     *string
+	EntityID   *string
+	TraceID    *string
+	StartDate  *time.Time
+	EndDate    *time.Time
+}
+
+// ByActor sets the Actor field of the QueryFilter value.
+func (f *QueryFilter) ByActor(actor string) {
+	if actor != "" {
+		f.Actor = &actor
+	}
+}
+
+// ByOnBehalfOf sets the OnBehalfOf field of the QueryFilter value.
+func (f *QueryFilter) ByOnBehalfOf(userID string) {
+	if userID != "" {
+		f.OnBehalfOf = &userID
+	}
+}
+
+// ByAction sets the Action field of the QueryFilter value.
+func (f *QueryFilter) ByAction(action string) {
+	if action != "" {
+		f.Action = &action
+	}
+}
+
+// ByEntity sets the Entity field of the QueryFilter value.
+func (f *QueryFilter) ByEntity(entity string) {
+	if entity != "" {
+		f.Entity = &entity
+	}
+}
+
+// ByEntityID sets the EntityID field of the QueryFilter value.
+func (f *QueryFilter) ByEntityID(entityID string) {
+	if entityID != "" {
+		f.EntityID = &entityID
+	}
+}
+
+// ByTraceID sets the TraceID field of the QueryFilter value.
+func (f *QueryFilter) ByTraceID(traceID string) {
+	if traceID != "" {
+		f.TraceID = &traceID
+	}
+}
+
+// ByStartDate sets the StartDate field of the QueryFilter value.
+func (f *QueryFilter) ByStartDate(t time.Time) {
+	if !t.IsZero() {
+		f.StartDate = &t
+	}
+}
+
+// ByEndDate sets the EndDate field of the QueryFilter value.
+func (f *QueryFilter) ByEndDate(t time.Time) {
+	if !t.IsZero() {
+		f.EndDate = &t
+	}
+}
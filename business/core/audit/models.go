@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Set of actions that are recorded.
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionDelete    = "delete"
+	ActionAnonymize = "anonymize"
+	ActionRevoke    = "revoke"
+
+	ActionImpersonationStart = "impersonation_start"
+	ActionImpersonationEnd   = "impersonation_end"
+)
+
+// Set of entities that are recorded.
+const (
+	EntityUser       = "user"
+	EntityProduct    = "product"
+	EntityAPIKey     = "apikey"
+	EntityRole       = "role"
+	EntityPermission = "permission"
+	EntityToken      = "token"
+)
+
+// Entry represents a single mutation of an entity. OnBehalfOf is the user
+// the actor was impersonating when the mutation was made.
+type Entry struct {
+	ID          uuid.UUID         `json:"id"`
+	Actor       string            `json:"actor"`
+	OnBehalfOf  string            `json:"on_behalf_of,omitempty"`
+	Action      string            `json:"action"`
+	Entity      string            `json:"entity"`
+	EntityID    string            `json:"entity_id"`
+	Diff        map[string]Change `json:"diff"`
+	TraceID     string            `json:"trace_id"`
+	DateCreated time.Time         `json:"date_created"`
+}
+
+// Change holds the value of a field before and after a mutation. Before is
+// nil for a created entity and After is nil for a deleted one.
+type Change struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
@@ -0,0 +1,154 @@
+// Package auditdb contains audit related CRUD functionality.
+package auditdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/halilylm/micro/business/core/audit"
+	"github.com/halilylm/micro/business/sys/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+// Repository manages the set of APIs for audit database access.
+type Repository struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewRepository constructs the api for data access.
+func NewRepository(log *zap.SugaredLogger, db *sqlx.DB) *Repository {
+	if log == nil {
+		log = zap.NewNop().Sugar()
+	}
+	return &Repository{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create inserts a new entry into the database.
+func (r *Repository) Create(ctx context.Context, entry audit.Entry) error {
+	dbEnt, err := toDBEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	const q = `
+	INSERT INTO audit_log
+		(audit_id, actor, on_behalf_of, action, entity, entity_id, diff, trace_id, date_created)
+	VALUES
+		(:audit_id, :actor, :on_behalf_of, :action, :entity, :entity_id, :diff, :trace_id, :date_created)`
+
+	// The entry is written in the transaction of the mutation it records
+	// when there is one, so one isn't kept without the other.
+	if err := database.NamedExecContext(ctx, r.log, database.Conn(ctx, r.db), q, dbEnt); err != nil {
+		return fmt.Errorf("inserting audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// Redact clears the recorded values of every entry for the entity.
+func (r *Repository) Redact(ctx context.Context, entity string, entityID string) error {
+	data := struct {
+		Entity   string `db:"entity"`
+		EntityID string `db:"entity_id"`
+	}{
+		Entity:   entity,
+		EntityID: entityID,
+	}
+
+	const q = `
+	UPDATE
+		audit_log
+	SET
+		"diff" = NULL
+	WHERE
+		entity = :entity AND
+		entity_id = :entity_id`
+
+	if err := database.NamedExecContext(ctx, r.log, database.Conn(ctx, r.db), q, data); err != nil {
+		return fmt.Errorf("redacting entity[%s] entityID[%s]: %w", entity, entityID, err)
+	}
+
+	return nil
+}
+
+// Query retrieves a list of audit entries from the database, newest first.
+func (r *Repository) Query(ctx context.Context, filter audit.QueryFilter, pageNumber int, rowsPerPage int) ([]audit.Entry, error) {
+	data := struct {
+		Actor       string    `db:"actor"`
+		OnBehalfOf  string    `db:"on_behalf_of"`
+		Action      string    `db:"action"`
+		Entity      string    `db:"entity"`
+		EntityID    string    `db:"entity_id"`
+		TraceID     string    `db:"trace_id"`
+		StartDate   time.Time `db:"start_date"`
+		EndDate     time.Time `db:"end_date"`
+		Offset      int       `db:"offset"`
+		RowsPerPage int       `db:"rows_per_page"`
+	}{
+		Offset:      (pageNumber - 1) * rowsPerPage,
+		RowsPerPage: rowsPerPage,
+	}
+
+	var wc []string
+	if filter.Actor != nil {
+		data.Actor = *filter.Actor
+		wc = append(wc, "actor = :actor")
+	}
+	if filter.OnBehalfOf != nil {
+		data.OnBehalfOf = *filter.OnBehalfOf
+		wc = append(wc, "on_behalf_of = :on_behalf_of")
+	}
+	if filter.Action != nil {
+		data.Action = *filter.Action
+		wc = append(wc, "action = :action")
+	}
+	if filter.Entity != nil {
+		data.Entity = *filter.Entity
+		wc = append(wc, "entity = :entity")
+	}
+	if filter.EntityID != nil {
+		data.EntityID = *filter.EntityID
+		wc = append(wc, "entity_id = :entity_id")
+	}
+	if filter.TraceID != nil {
+		data.TraceID = *filter.TraceID
+		wc = append(wc, "trace_id = :trace_id")
+	}
+	if filter.StartDate != nil {
+		data.StartDate = filter.StartDate.UTC()
+		wc = append(wc, "date_created >= :start_date")
+	}
+	if filter.EndDate != nil {
+		data.EndDate = filter.EndDate.UTC()
+		wc = append(wc, "date_created <= :end_date")
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		audit_log
+	`
+	buf := bytes.NewBufferString(q)
+
+	if len(wc) > 0 {
+		buf.WriteString("WHERE ")
+		buf.WriteString(strings.Join(wc, " AND "))
+	}
+	buf.WriteString(" ORDER BY date_created DESC")
+	buf.WriteString(" OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY")
+
+	var dbEntries []dbEntry
+	if err := database.NamedQuerySlice(ctx, r.log, r.db, buf.String(), data, &dbEntries); err != nil {
+		return nil, fmt.Errorf("selecting audit entries: %w", err)
+	}
+
+	return toCoreEntrySlice(dbEntries)
+}
@@ -0,0 +1,80 @@
+package auditdb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/audit"
+	"time"
+)
+
+// dbEntry represent the structure we need for moving data
+// between the app and the database.
+type dbEntry struct {
+	ID          uuid.UUID      `db:"audit_id"`
+	Actor       string         `db:"actor"`
+	OnBehalfOf  sql.NullString `db:"on_behalf_of"`
+	Action      string         `db:"action"`
+	Entity      string         `db:"entity"`
+	EntityID    string         `db:"entity_id"`
+	Diff        sql.NullString `db:"diff"`
+	TraceID     string         `db:"trace_id"`
+	DateCreated time.Time      `db:"date_created"`
+}
+
+func toDBEntry(entry audit.Entry) (dbEntry, error) {
+	dbEnt := dbEntry{
+		ID:          entry.ID,
+		Actor:       entry.Actor,
+		OnBehalfOf:  sql.NullString{String: entry.OnBehalfOf, Valid: entry.OnBehalfOf != ""},
+		Action:      entry.Action,
+		Entity:      entry.Entity,
+		EntityID:    entry.EntityID,
+		TraceID:     entry.TraceID,
+		DateCreated: entry.DateCreated.UTC(),
+	}
+
+	if entry.Diff != nil {
+		data, err := json.Marshal(entry.Diff)
+		if err != nil {
+			return dbEntry{}, fmt.Errorf("marshal diff: %w", err)
+		}
+		dbEnt.Diff = sql.NullString{String: string(data), Valid: true}
+	}
+
+	return dbEnt, nil
+}
+
+func toCoreEntry(dbEnt dbEntry) (audit.Entry, error) {
+	entry := audit.Entry{
+		ID:          dbEnt.ID,
+		Actor:       dbEnt.Actor,
+		OnBehalfOf:  dbEnt.OnBehalfOf.String,
+		Action:      dbEnt.Action,
+		Entity:      dbEnt.Entity,
+		EntityID:    dbEnt.EntityID,
+		TraceID:     dbEnt.TraceID,
+		DateCreated: dbEnt.DateCreated.In(time.Local),
+	}
+
+	if dbEnt.Diff.Valid {
+		if err := json.Unmarshal([]byte(dbEnt.Diff.String), &entry.Diff); err != nil {
+			return audit.Entry{}, fmt.Errorf("unmarshal diff: %w", err)
+		}
+	}
+
+	return entry, nil
+}
+
+func toCoreEntrySlice(dbEntries []dbEntry) ([]audit.Entry, error) {
+	entries := make([]audit.Entry, len(dbEntries))
+	for i, dbEnt := range dbEntries {
+		entry, err := toCoreEntry(dbEnt)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = entry
+	}
+	return entries, nil
+}
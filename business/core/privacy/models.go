@@ -0,0 +1,52 @@
+package privacy
+
+import (
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/apikey"
+	"github.com/halilylm/micro/business/core/product"
+	"github.com/halilylm/micro/business/core/sale"
+	"github.com/halilylm/micro/business/core/user"
+	"time"
+)
+
+// Set of job kinds.
+const (
+	KindExport  = "export"
+	KindErasure = "erasure"
+)
+
+// Set of job statuses.
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// Job represents a request to export or erase the data of a user. Jobs run
+// in the background and are polled for their status.
+type Job struct {
+	ID          uuid.UUID `json:"id"`
+	UserID      uuid.UUID `json:"user_id"`
+	Kind        string    `json:"kind"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	Archive     *Archive  `json:"-"`
+	DateCreated time.Time `json:"date_created"`
+	DateUpdated time.Time `json:"date_updated"`
+}
+
+// Done reports whether the job is no longer running.
+func (j Job) Done() bool {
+	return j.Status == StatusCompleted || j.Status == StatusFailed
+}
+
+// Archive contains everything in the system that is tied to a user.
+type Archive struct {
+	User          user.User         `json:"user"`
+	APIKeys       []apikey.APIKey   `json:"api_keys"`
+	Products      []product.Product `json:"products"`
+	Purchases     []sale.Sale       `json:"purchases"`
+	Sales         []sale.Sale       `json:"sales"`
+	DateGenerated time.Time         `json:"date_generated"`
+}
@@ -0,0 +1,252 @@
+// Package privacy provides the core business API for exporting and erasing
+// the personal data of a user.
+package privacy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/apikey"
+	"github.com/halilylm/micro/business/core/product"
+	"github.com/halilylm/micro/business/core/sale"
+	"github.com/halilylm/micro/business/core/user"
+	"github.com/halilylm/micro/foundation/worker"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Set of error variables for privacy jobs.
+var (
+	ErrNotFound   = errors.New("job not found")
+	ErrNotExport  = errors.New("job is not an export")
+	ErrNotDone    = errors.New("job is not completed")
+	ErrJobsBusy   = errors.New("unable to start job")
+	ErrJobRunning = errors.New("a job is already running for the user")
+)
+
+// Timeouts for the jobs. The status is recorded with its own deadline so a
+// job that ran out of time can still be marked as failed.
+const (
+	jobTimeout    = 5 * time.Minute
+	statusTimeout = 10 * time.Second
+)
+
+// Repository interface declares the behaviour this package needs to persist
+// and retrieve data.
+type Repository interface {
+	Create(ctx context.Context, job Job) error
+	Update(ctx context.Context, job Job) error
+	QueryByID(ctx context.Context, jobID uuid.UUID) (Job, error)
+	QueryActiveByUserID(ctx context.Context, userID uuid.UUID, since time.Time) ([]Job, error)
+	ClearArchives(ctx context.Context, userID uuid.UUID) error
+}
+
+// Core manages the set of APIs for privacy access.
+type Core struct {
+	log     *zap.SugaredLogger
+	worker  *worker.Worker
+	user    *user.Core
+	apiKey  *apikey.Core
+	product *product.Core
+	sale    *sale.Core
+	repo    Repository
+}
+
+// NewCore constructs a core for privacy api access.
+func NewCore(log *zap.SugaredLogger, wrk *worker.Worker, usrCore *user.Core, apiKeyCore *apikey.Core, prdCore *product.Core, saleCore *sale.Core, repo Repository) *Core {
+	return &Core{
+		log:     log,
+		worker:  wrk,
+		user:    usrCore,
+		apiKey:  apiKeyCore,
+		product: prdCore,
+		sale:    saleCore,
+		repo:    repo,
+	}
+}
+
+// StartExport starts a job that assembles the archive of the user's data.
+func (c *Core) StartExport(ctx context.Context, usr user.User) (Job, error) {
+	return c.start(ctx, usr, KindExport, c.export)
+}
+
+// StartErasure starts a job that erases the personal data of the user. The
+// user row is anonymized instead of deleted so the sales ledger stays intact.
+func (c *Core) StartErasure(ctx context.Context, usr user.User) (Job, error) {
+	return c.start(ctx, usr, KindErasure, c.erase)
+}
+
+// QueryByID gets the specified job from the database.
+func (c *Core) QueryByID(ctx context.Context, jobID uuid.UUID) (Job, error) {
+	job, err := c.repo.QueryByID(ctx, jobID)
+	if err != nil {
+		return Job{}, fmt.Errorf("query: %w", err)
+	}
+
+	return job, nil
+}
+
+// QueryArchive returns the archive produced by a completed export job.
+func (c *Core) QueryArchive(ctx context.Context, job Job) (Archive, error) {
+	if job.Kind != KindExport {
+		return Archive{}, ErrNotExport
+	}
+
+	if job.Status != StatusCompleted || job.Archive == nil {
+		return Archive{}, ErrNotDone
+	}
+
+	return *job.Archive, nil
+}
+
+// =============================================================================
+
+// start records the job and hands the work to the worker.
+func (c *Core) start(ctx context.Context, usr user.User, kind string, fn func(ctx context.Context, job Job) (Job, error)) (Job, error) {
+	// Jobs that weren't updated within the timeout were abandoned, for
+	// instance by a restart, and don't block new ones.
+	active, err := c.repo.QueryActiveByUserID(ctx, usr.ID, time.Now().Add(-jobTimeout))
+	if err != nil {
+		return Job{}, fmt.Errorf("query active: %w", err)
+	}
+
+	if len(active) > 0 {
+		return Job{}, ErrJobRunning
+	}
+
+	now := time.Now()
+
+	job := Job{
+		ID:          uuid.New(),
+		UserID:      usr.ID,
+		Kind:        kind,
+		Status:      StatusPending,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	if err := c.repo.Create(ctx, job); err != nil {
+		return Job{}, fmt.Errorf("create: %w", err)
+	}
+
+	// The worker detaches the job from the request but keeps the deadline
+	// and the values, so what the job changes is audited under the actor
+	// and the trace id of the request. The request isn't held waiting for a
+	// worker to be free.
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+
+	if _, err := c.worker.TryStart(ctx, func(ctx context.Context) { c.run(ctx, job, fn) }); err != nil {
+		job.Status = StatusFailed
+		job.Error = ErrJobsBusy.Error()
+		c.record(job)
+		return Job{}, fmt.Errorf("%w: %s", ErrJobsBusy, err)
+	}
+
+	return job, nil
+}
+
+// run executes the job and records its progress.
+func (c *Core) run(ctx context.Context, job Job, fn func(ctx context.Context, job Job) (Job, error)) {
+	c.log.Infow("privacy job", "status", "started", "job_id", job.ID, "kind", job.Kind, "user_id", job.UserID)
+
+	job.Status = StatusRunning
+	c.record(job)
+
+	done, err := fn(ctx, job)
+	if err != nil {
+		c.log.Errorw("privacy job", "status", "failed", "job_id", job.ID, "kind", job.Kind, "ERROR", err)
+		job.Status = StatusFailed
+		job.Error = err.Error()
+		c.record(job)
+		return
+	}
+
+	done.Status = StatusCompleted
+	c.record(done)
+
+	c.log.Infow("privacy job", "status", "completed", "job_id", job.ID, "kind", job.Kind, "user_id", job.UserID)
+}
+
+// record stores the current state of the job.
+func (c *Core) record(job Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), statusTimeout)
+	defer cancel()
+
+	job.DateUpdated = time.Now()
+	if err := c.repo.Update(ctx, job); err != nil {
+		c.log.Errorw("privacy job", "status", "recording status", "job_id", job.ID, "ERROR", err)
+	}
+}
+
+// export assembles the archive of the user's data.
+func (c *Core) export(ctx context.Context, job Job) (Job, error) {
+	usr, err := c.user.QueryByID(ctx, job.UserID)
+	if err != nil {
+		return Job{}, fmt.Errorf("user: %w", err)
+	}
+
+	keys, err := c.apiKey.QueryByUserID(ctx, job.UserID)
+	if err != nil {
+		return Job{}, fmt.Errorf("api keys: %w", err)
+	}
+
+	prds, err := c.product.QueryByUserID(ctx, job.UserID)
+	if err != nil {
+		return Job{}, fmt.Errorf("products: %w", err)
+	}
+
+	purchases, err := c.sale.QueryByUserID(ctx, job.UserID)
+	if err != nil {
+		return Job{}, fmt.Errorf("purchases: %w", err)
+	}
+
+	sales, err := c.sale.QueryBySellerID(ctx, job.UserID)
+	if err != nil {
+		return Job{}, fmt.Errorf("sales: %w", err)
+	}
+
+	job.Archive = &Archive{
+		User:          usr,
+		APIKeys:       keys,
+		Products:      prds,
+		Purchases:     purchases,
+		Sales:         sales,
+		DateGenerated: time.Now(),
+	}
+
+	return job, nil
+}
+
+// erase revokes the user's api keys and anonymizes the user. Products and
+// sales are kept since they are part of the ledger and only reference the
+// user by ID.
+func (c *Core) erase(ctx context.Context, job Job) (Job, error) {
+	usr, err := c.user.QueryByID(ctx, job.UserID)
+	if err != nil {
+		return Job{}, fmt.Errorf("user: %w", err)
+	}
+
+	keys, err := c.apiKey.QueryByUserID(ctx, job.UserID)
+	if err != nil {
+		return Job{}, fmt.Errorf("api keys: %w", err)
+	}
+
+	for _, key := range keys {
+		if err := c.apiKey.Delete(ctx, key); err != nil {
+			return Job{}, fmt.Errorf("revoking api key[%s]: %w", key.ID, err)
+		}
+	}
+
+	if _, err := c.user.Anonymize(ctx, usr); err != nil {
+		return Job{}, fmt.Errorf("anonymize: %w", err)
+	}
+
+	// Earlier exports hold the full profile so they go with it.
+	if err := c.repo.ClearArchives(ctx, job.UserID); err != nil {
+		return Job{}, fmt.Errorf("clearing archives: %w", err)
+	}
+
+	return job, nil
+}
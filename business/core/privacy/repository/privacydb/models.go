@@ -0,0 +1,67 @@
+package privacydb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/privacy"
+	"time"
+)
+
+// dbJob represent the structure we need for moving data
+// between the app and the database.
+type dbJob struct {
+	ID          uuid.UUID      `db:"job_id"`
+	UserID      uuid.UUID      `db:"user_id"`
+	Kind        string         `db:"kind"`
+	Status      string         `db:"status"`
+	Error       string         `db:"error"`
+	Archive     sql.NullString `db:"archive"`
+	DateCreated time.Time      `db:"date_created"`
+	DateUpdated time.Time      `db:"date_updated"`
+}
+
+func toDBJob(job privacy.Job) (dbJob, error) {
+	dbJb := dbJob{
+		ID:          job.ID,
+		UserID:      job.UserID,
+		Kind:        job.Kind,
+		Status:      job.Status,
+		Error:       job.Error,
+		DateCreated: job.DateCreated.UTC(),
+		DateUpdated: job.DateUpdated.UTC(),
+	}
+
+	if job.Archive != nil {
+		data, err := json.Marshal(job.Archive)
+		if err != nil {
+			return dbJob{}, fmt.Errorf("marshal archive: %w", err)
+		}
+		dbJb.Archive = sql.NullString{String: string(data), Valid: true}
+	}
+
+	return dbJb, nil
+}
+
+func toCoreJob(dbJb dbJob) (privacy.Job, error) {
+	job := privacy.Job{
+		ID:          dbJb.ID,
+		UserID:      dbJb.UserID,
+		Kind:        dbJb.Kind,
+		Status:      dbJb.Status,
+		Error:       dbJb.Error,
+		DateCreated: dbJb.DateCreated.In(time.Local),
+		DateUpdated: dbJb.DateUpdated.In(time.Local),
+	}
+
+	if dbJb.Archive.Valid {
+		var archive privacy.Archive
+		if err := json.Unmarshal([]byte(dbJb.Archive.String), &archive); err != nil {
+			return privacy.Job{}, fmt.Errorf("unmarshal archive: %w", err)
+		}
+		job.Archive = &archive
+	}
+
+	return job, nil
+}
@@ -0,0 +1,169 @@
+// Package privacydb contains privacy job related CRUD functionality.
+package privacydb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/privacy"
+	"github.com/halilylm/micro/business/sys/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Repository manages the set of APIs for privacy job database access.
+type Repository struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewRepository constructs the api for data access.
+func NewRepository(log *zap.SugaredLogger, db *sqlx.DB) *Repository {
+	if log == nil {
+		log = zap.NewNop().Sugar()
+	}
+	return &Repository{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create inserts a new job into the database.
+func (r *Repository) Create(ctx context.Context, job privacy.Job) error {
+	dbJb, err := toDBJob(job)
+	if err != nil {
+		return err
+	}
+
+	const q = `
+	INSERT INTO privacy_jobs
+		(job_id, user_id, kind, status, error, archive, date_created, date_updated)
+	VALUES
+		(:job_id, :user_id, :kind, :status, :error, :archive, :date_created, :date_updated)`
+
+	if err := database.NamedExecContext(ctx, r.log, r.db, q, dbJb); err != nil {
+		return fmt.Errorf("inserting job: %w", err)
+	}
+
+	return nil
+}
+
+// Update replaces the state of a job in the database.
+func (r *Repository) Update(ctx context.Context, job privacy.Job) error {
+	dbJb, err := toDBJob(job)
+	if err != nil {
+		return err
+	}
+
+	const q = `
+	UPDATE
+		privacy_jobs
+	SET
+		"status" = :status,
+		"error" = :error,
+		"archive" = :archive,
+		"date_updated" = :date_updated
+	WHERE
+		job_id = :job_id`
+
+	if err := database.NamedExecContext(ctx, r.log, r.db, q, dbJb); err != nil {
+		return fmt.Errorf("updating jobID[%s]: %w", job.ID, err)
+	}
+
+	return nil
+}
+
+// ClearArchives removes the archives of every export job of a user.
+func (r *Repository) ClearArchives(ctx context.Context, userID uuid.UUID) error {
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID.String(),
+	}
+
+	const q = `
+	UPDATE
+		privacy_jobs
+	SET
+		"archive" = NULL
+	WHERE
+		user_id = :user_id AND
+		archive IS NOT NULL`
+
+	if err := database.NamedExecContext(ctx, r.log, r.db, q, data); err != nil {
+		return fmt.Errorf("clearing archives userID[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// QueryByID gets the specified job from the database.
+func (r *Repository) QueryByID(ctx context.Context, jobID uuid.UUID) (privacy.Job, error) {
+	data := struct {
+		JobID string `db:"job_id"`
+	}{
+		JobID: jobID.String(),
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		privacy_jobs
+	WHERE
+		job_id = :job_id`
+
+	var dbJb dbJob
+	if err := database.NamedQueryStruct(ctx, r.log, r.db, q, data, &dbJb); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return privacy.Job{}, privacy.ErrNotFound
+		}
+		return privacy.Job{}, fmt.Errorf("selecting jobID[%q]: %w", jobID, err)
+	}
+
+	return toCoreJob(dbJb)
+}
+
+// QueryActiveByUserID retrieves the pending and running jobs of a user that
+// were updated after the specified time.
+func (r *Repository) QueryActiveByUserID(ctx context.Context, userID uuid.UUID, since time.Time) ([]privacy.Job, error) {
+	data := struct {
+		UserID    string    `db:"user_id"`
+		Pending   string    `db:"pending"`
+		Running   string    `db:"running"`
+		DateSince time.Time `db:"date_since"`
+	}{
+		UserID:    userID.String(),
+		Pending:   privacy.StatusPending,
+		Running:   privacy.StatusRunning,
+		DateSince: since.UTC(),
+	}
+
+	const q = `
+	SELECT
+		job_id, user_id, kind, status, error, NULL AS archive, date_created, date_updated
+	FROM
+		privacy_jobs
+	WHERE
+		user_id = :user_id AND
+		status IN (:pending, :running) AND
+		date_updated > :date_since`
+
+	var dbJobs []dbJob
+	if err := database.NamedQuerySlice(ctx, r.log, r.db, q, data, &dbJobs); err != nil {
+		return nil, fmt.Errorf("selecting jobs userID[%s]: %w", userID, err)
+	}
+
+	jobs := make([]privacy.Job, len(dbJobs))
+	for i, dbJb := range dbJobs {
+		job, err := toCoreJob(dbJb)
+		if err != nil {
+			return nil, err
+		}
+		jobs[i] = job
+	}
+
+	return jobs, nil
+}
@@ -2,6 +2,7 @@ package product
 
 import (
 	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/sys/validate"
 	"time"
 )
 
@@ -26,6 +27,11 @@ type NewProduct struct {
 	UserID   uuid.UUID `json:"user_id" validate:"required,uuid4"`
 }
 
+// Validate checks the NewProduct against its validate tags.
+func (np NewProduct) Validate() error {
+	return validate.Check(np)
+}
+
 // UpdateProduct defines what information may be provided to modify an
 // existing Product. All fields are optional so clients can send just the
 // fields they want changed. It uses pointer fields, so we can differentiate
@@ -37,3 +43,8 @@ type UpdateProduct struct {
 	Cost     *int    `json:"cost" validate:"omitempty,gte=0"`
 	Quantity *int    `json:"quantity" validate:"omitempty,gte=1"`
 }
+
+// Validate checks the UpdateProduct against its validate tags.
+func (up UpdateProduct) Validate() error {
+	return validate.Check(up)
+}
@@ -1,6 +1,9 @@
 package product
 
-import "github.com/halilylm/micro/business/data/order"
+import (
+	"github.com/halilylm/micro/business/data/order"
+	"strconv"
+)
 
 var ordering = order.New(orderByFields, OrderByID)
 
@@ -34,3 +37,29 @@ var orderByFields = map[string]bool{
 func NewOrderBy(field string, direction string) (order.By, error) {
 	return ordering.By(field, direction)
 }
+
+// SortKey returns the position of the product in an ordering by the field.
+func SortKey(prd Product, field string) order.Key {
+	key := order.Key{
+		ID: prd.ID.String(),
+	}
+
+	switch field {
+	case OrderByID:
+		key.Value = prd.ID.String()
+	case OrderByName:
+		key.Value = prd.Name
+	case OrderByCost:
+		key.Value = strconv.Itoa(prd.Cost)
+	case OrderByQuantity:
+		key.Value = strconv.Itoa(prd.Quantity)
+	case OrderBySold:
+		key.Value = strconv.Itoa(prd.Sold)
+	case OrderByRevenue:
+		key.Value = strconv.Itoa(prd.Revenue)
+	case OrderByUserID:
+		key.Value = prd.UserID.String()
+	}
+
+	return key
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/audit"
 	"github.com/halilylm/micro/business/data/order"
 	"github.com/halilylm/micro/business/sys/validate"
 	"time"
@@ -14,27 +15,35 @@ var (
 	ErrNotFound     = errors.New("product not found")
 	ErrInvalidID    = errors.New("ID is not in its proper form")
 	ErrInvalidOrder = errors.New("validating order by")
+	ErrInUse        = errors.New("product is referenced by sales and can't be deleted")
 )
 
 // Repository interface declares the behaviour this package needs to persist
 // and retrieve data
 type Repository interface {
+	WithinTran(ctx context.Context, fn func(ctx context.Context, r Repository) error) error
 	Create(ctx context.Context, prd Product) error
 	Update(ctx context.Context, prd Product) error
 	Delete(ctx context.Context, prd Product) error
 	Query(ctx context.Context, filter QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) ([]Product, error)
+	QueryAfter(ctx context.Context, filter QueryFilter, orderBy order.By, after *order.Key, limit int) ([]Product, error)
+	QueryAll(ctx context.Context, filter QueryFilter, orderBy order.By, fn func(Product) error) error
 	QueryByID(ctx context.Context, productID uuid.UUID) (Product, error)
 	QueryByUserID(ctx context.Context, userID uuid.UUID) ([]Product, error)
 }
 
 // Core manages the set of APIs for product access.
 type Core struct {
-	repo Repository
+	repo  Repository
+	audit *audit.Core
 }
 
 // NewCore constructs a core for product api access.
-func NewCore(repo Repository) *Core {
-	return &Core{repo: repo}
+func NewCore(repo Repository, audCore *audit.Core) *Core {
+	return &Core{
+		repo:  repo,
+		audit: audCore,
+	}
 }
 
 // Create adds a Product to the database. It returns the created Product with
@@ -56,8 +65,18 @@ func (c *Core) Create(ctx context.Context, np NewProduct) (Product, error) {
 		DateUpdated: now,
 	}
 
-	if err := c.repo.Create(ctx, prd); err != nil {
-		return Product{}, fmt.Errorf("create: %w", err)
+	tran := func(ctx context.Context, r Repository) error {
+		if err := r.Create(ctx, prd); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+		if err := c.audit.Record(ctx, audit.ActionCreate, audit.EntityProduct, prd.ID.String(), nil, prd); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+		return nil
+	}
+
+	if err := c.repo.WithinTran(ctx, tran); err != nil {
+		return Product{}, err
 	}
 
 	return prd, nil
@@ -70,6 +89,8 @@ func (c *Core) Update(ctx context.Context, prd Product, up UpdateProduct) (Produ
 		return Product{}, fmt.Errorf("validating data: %w", err)
 	}
 
+	before := prd
+
 	if up.Name != nil {
 		prd.Name = *up.Name
 	}
@@ -84,8 +105,18 @@ func (c *Core) Update(ctx context.Context, prd Product, up UpdateProduct) (Produ
 
 	prd.DateUpdated = time.Now()
 
-	if err := c.repo.Update(ctx, prd); err != nil {
-		return Product{}, fmt.Errorf("update: %w", err)
+	tran := func(ctx context.Context, r Repository) error {
+		if err := r.Update(ctx, prd); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+		if err := c.audit.Record(ctx, audit.ActionUpdate, audit.EntityProduct, prd.ID.String(), before, prd); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+		return nil
+	}
+
+	if err := c.repo.WithinTran(ctx, tran); err != nil {
+		return Product{}, err
 	}
 
 	return prd, nil
@@ -93,11 +124,17 @@ func (c *Core) Update(ctx context.Context, prd Product, up UpdateProduct) (Produ
 
 // Delete removes the product identified by a given ID.
 func (c *Core) Delete(ctx context.Context, prd Product) error {
-	if err := c.repo.Delete(ctx, prd); err != nil {
-		return fmt.Errorf("delete: %w", err)
+	tran := func(ctx context.Context, r Repository) error {
+		if err := r.Delete(ctx, prd); err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+		if err := c.audit.Record(ctx, audit.ActionDelete, audit.EntityProduct, prd.ID.String(), prd, nil); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+		return nil
 	}
 
-	return nil
+	return c.repo.WithinTran(ctx, tran)
 }
 
 // Query gets all Products from the database.
@@ -118,6 +155,43 @@ func (c *Core) Query(ctx context.Context, filter QueryFilter, orderBy order.By,
 	return prds, nil
 }
 
+// QueryAfter gets up to limit Products that come after the key in the
+// order, or the first ones when there is no key.
+func (c *Core) QueryAfter(ctx context.Context, filter QueryFilter, orderBy order.By, after *order.Key, limit int) ([]Product, error) {
+	if err := validate.Check(filter); err != nil {
+		return nil, fmt.Errorf("validating filter: %w", err)
+	}
+
+	if err := ordering.Check(orderBy); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidOrder, err.Error())
+	}
+
+	prds, err := c.repo.QueryAfter(ctx, filter, orderBy, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return prds, nil
+}
+
+// QueryAll passes every matching Product to fn as it is read from the
+// database, without loading them all into memory.
+func (c *Core) QueryAll(ctx context.Context, filter QueryFilter, orderBy order.By, fn func(Product) error) error {
+	if err := validate.Check(filter); err != nil {
+		return fmt.Errorf("validating filter: %w", err)
+	}
+
+	if err := ordering.Check(orderBy); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidOrder, err.Error())
+	}
+
+	if err := c.repo.QueryAll(ctx, filter, orderBy, fn); err != nil {
+		return fmt.Errorf("query: %w", err)
+	}
+
+	return nil
+}
+
 // QueryByID finds the product identified by a given ID.
 func (c *Core) QueryByID(ctx context.Context, productID uuid.UUID) (Product, error) {
 	prd, err := c.repo.QueryByID(ctx, productID)
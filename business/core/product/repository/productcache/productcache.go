@@ -0,0 +1,339 @@
+// Package productcache contains product related CRUD functionality with
+// caching.
+package productcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/product"
+	"github.com/halilylm/micro/business/data/order"
+	"github.com/halilylm/micro/foundation/cache"
+	"go.uber.org/zap"
+	"strconv"
+	"time"
+)
+
+// Default settings used for zero values in the Config.
+const (
+	defaultTTL     = 5 * time.Minute
+	defaultPageTTL = 30 * time.Second
+)
+
+// generationKey holds the generation that is part of every page key. Any
+// write replaces it, which leaves every cached page unreachable since a
+// change to one product can affect any page.
+const generationKey = "product:generation"
+
+// Config represents the settings for the cache. Pages are kept for a shorter
+// time since sales change the sold and revenue figures without a write to
+// the product.
+type Config struct {
+	TTL     time.Duration
+	PageTTL time.Duration
+}
+
+// Repository manages the set of APIs for product data and caching access.
+type Repository struct {
+	log     *zap.SugaredLogger
+	repo    product.Repository
+	backend cache.Backend
+	cfg     Config
+}
+
+// NewRepository constructs the api for data and caching access.
+func NewRepository(log *zap.SugaredLogger, repo product.Repository, backend cache.Backend, cfg Config) *Repository {
+	if log == nil {
+		log = zap.NewNop().Sugar()
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultTTL
+	}
+	if cfg.PageTTL <= 0 {
+		cfg.PageTTL = defaultPageTTL
+	}
+	return &Repository{
+		log:     log,
+		repo:    repo,
+		backend: backend,
+		cfg:     cfg,
+	}
+}
+
+// WithinTran runs passed function and do commit/rollback at the end. What
+// the products changed in the transaction are part of is evicted once it's
+// committed.
+func (r *Repository) WithinTran(ctx context.Context, fn func(ctx context.Context, r product.Repository) error) error {
+	var changed []product.Product
+	f := func(ctx context.Context, repo product.Repository) error {
+		return fn(ctx, &tranRepository{Repository: repo, changed: &changed})
+	}
+
+	if err := r.repo.WithinTran(ctx, f); err != nil {
+		return err
+	}
+
+	for _, prd := range changed {
+		r.invalidate(ctx, prd)
+	}
+
+	return nil
+}
+
+// Create inserts a new product into the database.
+func (r *Repository) Create(ctx context.Context, prd product.Product) error {
+	if err := r.repo.Create(ctx, prd); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, prd)
+
+	return nil
+}
+
+// Update replaces a product document in the database.
+func (r *Repository) Update(ctx context.Context, prd product.Product) error {
+	if err := r.repo.Update(ctx, prd); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, prd)
+
+	return nil
+}
+
+// Delete removes a product from the database.
+func (r *Repository) Delete(ctx context.Context, prd product.Product) error {
+	if err := r.repo.Delete(ctx, prd); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, prd)
+
+	return nil
+}
+
+// Query retrieves a page of products from the cache or the database.
+func (r *Repository) Query(ctx context.Context, filter product.QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) ([]product.Product, error) {
+	key, err := r.pageKey(ctx, filter, orderBy, strconv.Itoa(pageNumber), rowsPerPage)
+	if err != nil {
+		r.log.Errorw("productcache", "status", "page key", "ERROR", err)
+		return r.repo.Query(ctx, filter, orderBy, pageNumber, rowsPerPage)
+	}
+
+	if prds, ok := r.readPage(ctx, key); ok {
+		return prds, nil
+	}
+
+	prds, err := r.repo.Query(ctx, filter, orderBy, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, err
+	}
+
+	r.writeCache(ctx, key, prds, r.cfg.PageTTL)
+
+	return prds, nil
+}
+
+// QueryAfter retrieves the products after the key from the cache or the
+// database.
+func (r *Repository) QueryAfter(ctx context.Context, filter product.QueryFilter, orderBy order.By, after *order.Key, limit int) ([]product.Product, error) {
+	position := "first"
+	if after != nil {
+		position = fmt.Sprintf("after:%q:%q", after.Value, after.ID)
+	}
+
+	key, err := r.pageKey(ctx, filter, orderBy, position, limit)
+	if err != nil {
+		r.log.Errorw("productcache", "status", "page key", "ERROR", err)
+		return r.repo.QueryAfter(ctx, filter, orderBy, after, limit)
+	}
+
+	if prds, ok := r.readPage(ctx, key); ok {
+		return prds, nil
+	}
+
+	prds, err := r.repo.QueryAfter(ctx, filter, orderBy, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	r.writeCache(ctx, key, prds, r.cfg.PageTTL)
+
+	return prds, nil
+}
+
+// QueryAll streams the matching products from the database. The results
+// are too large to be worth caching.
+func (r *Repository) QueryAll(ctx context.Context, filter product.QueryFilter, orderBy order.By, fn func(product.Product) error) error {
+	return r.repo.QueryAll(ctx, filter, orderBy, fn)
+}
+
+// QueryByID gets the specified product from the cache or the database.
+func (r *Repository) QueryByID(ctx context.Context, productID uuid.UUID) (product.Product, error) {
+	key := idKey(productID)
+
+	var prd product.Product
+	if r.readCache(ctx, key, &prd) {
+		return prd, nil
+	}
+
+	prd, err := r.repo.QueryByID(ctx, productID)
+	if err != nil {
+		return product.Product{}, err
+	}
+
+	r.writeCache(ctx, key, prd, r.cfg.TTL)
+
+	return prd, nil
+}
+
+// QueryByUserID gets the products of a user from the cache or the database.
+func (r *Repository) QueryByUserID(ctx context.Context, userID uuid.UUID) ([]product.Product, error) {
+	key := userKey(userID)
+
+	if prds, ok := r.readPage(ctx, key); ok {
+		return prds, nil
+	}
+
+	prds, err := r.repo.QueryByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.writeCache(ctx, key, prds, r.cfg.PageTTL)
+
+	return prds, nil
+}
+
+// =============================================================================
+
+// invalidate evicts everything the product is part of.
+func (r *Repository) invalidate(ctx context.Context, prd product.Product) {
+	if err := r.backend.Delete(ctx, idKey(prd.ID), userKey(prd.UserID), generationKey); err != nil {
+		r.log.Errorw("productcache", "status", "delete", "product_id", prd.ID, "ERROR", err)
+	}
+}
+
+// pageKey builds the key for a page from the current generation and the
+// query parameters. The position is the page number or the key the page
+// starts after.
+func (r *Repository) pageKey(ctx context.Context, filter product.QueryFilter, orderBy order.By, position string, rowsPerPage int) (string, error) {
+	gen, err := r.backend.Get(ctx, generationKey)
+	if err != nil {
+		if !errors.Is(err, cache.ErrNotFound) {
+			return "", err
+		}
+
+		gen = []byte(uuid.NewString())
+		if err := r.backend.Set(ctx, generationKey, gen, r.cfg.TTL); err != nil {
+			return "", err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(filter); err != nil {
+		return "", fmt.Errorf("encode filter: %w", err)
+	}
+	fmt.Fprintf(&buf, "|%s|%s|%s|%d", orderBy.Field, orderBy.Direction, position, rowsPerPage)
+
+	sum := sha256.Sum256(buf.Bytes())
+
+	return "product:page:" + string(gen) + ":" + hex.EncodeToString(sum[:]), nil
+}
+
+// readCache decodes the cached value for the key into v and reports
+// whether it was found.
+func (r *Repository) readCache(ctx context.Context, key string, v any) bool {
+	data, err := r.backend.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, cache.ErrNotFound) {
+			r.log.Errorw("productcache", "status", "get", "key", key, "ERROR", err)
+		}
+		return false
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+		r.log.Errorw("productcache", "status", "decode", "key", key, "ERROR", err)
+		return false
+	}
+
+	return true
+}
+
+// readPage gets a page of products from the cache. Gob decodes an empty
+// page to a nil slice, which is turned back into an empty one so a cached
+// page isn't encoded as null.
+func (r *Repository) readPage(ctx context.Context, key string) ([]product.Product, bool) {
+	var prds []product.Product
+	if !r.readCache(ctx, key, &prds) {
+		return nil, false
+	}
+
+	if prds == nil {
+		prds = []product.Product{}
+	}
+
+	return prds, true
+}
+
+// writeCache stores the value for the key and logs a failure.
+func (r *Repository) writeCache(ctx context.Context, key string, v any, ttl time.Duration) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		r.log.Errorw("productcache", "status", "encode", "key", key, "ERROR", err)
+		return
+	}
+
+	if err := r.backend.Set(ctx, key, buf.Bytes(), ttl); err != nil {
+		r.log.Errorw("productcache", "status", "set", "key", key, "ERROR", err)
+	}
+}
+
+func idKey(productID uuid.UUID) string {
+	return "product:id:" + productID.String()
+}
+
+func userKey(userID uuid.UUID) string {
+	return "product:user:" + userID.String()
+}
+
+// =============================================================================
+
+// tranRepository keeps track of the products changed within a transaction.
+type tranRepository struct {
+	product.Repository
+	changed *[]product.Product
+}
+
+// Create inserts a new product into the database.
+func (t *tranRepository) Create(ctx context.Context, prd product.Product) error {
+	if err := t.Repository.Create(ctx, prd); err != nil {
+		return err
+	}
+	*t.changed = append(*t.changed, prd)
+	return nil
+}
+
+// Update replaces a product document in the database.
+func (t *tranRepository) Update(ctx context.Context, prd product.Product) error {
+	if err := t.Repository.Update(ctx, prd); err != nil {
+		return err
+	}
+	*t.changed = append(*t.changed, prd)
+	return nil
+}
+
+// Delete removes a product from the database.
+func (t *tranRepository) Delete(ctx context.Context, prd product.Product) error {
+	if err := t.Repository.Delete(ctx, prd); err != nil {
+		return err
+	}
+	*t.changed = append(*t.changed, prd)
+	return nil
+}
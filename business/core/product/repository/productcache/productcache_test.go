@@ -0,0 +1,91 @@
+package productcache_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/product"
+	"github.com/halilylm/micro/business/core/product/repository/productcache"
+	"github.com/halilylm/micro/business/data/order"
+	"github.com/halilylm/micro/foundation/cache"
+	"testing"
+)
+
+// TestEmptyPage makes sure an empty page read from the cache is an empty
+// list, like the one the database returned, and not null.
+func TestEmptyPage(t *testing.T) {
+	ctx := context.Background()
+	orderBy := order.NewBy(product.OrderByName, order.ASC)
+	userID := uuid.New()
+
+	tests := []struct {
+		name  string
+		query func(r *productcache.Repository) ([]product.Product, error)
+	}{
+		{
+			name: "query",
+			query: func(r *productcache.Repository) ([]product.Product, error) {
+				return r.Query(ctx, product.QueryFilter{}, orderBy, 1, 10)
+			},
+		},
+		{
+			name: "query after",
+			query: func(r *productcache.Repository) ([]product.Product, error) {
+				return r.QueryAfter(ctx, product.QueryFilter{}, orderBy, nil, 10)
+			},
+		},
+		{
+			name: "query by user",
+			query: func(r *productcache.Repository) ([]product.Product, error) {
+				return r.QueryByUserID(ctx, userID)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		repo := &emptyRepo{}
+		r := productcache.NewRepository(nil, repo, cache.NewMemory(cache.MemoryConfig{}), productcache.Config{})
+
+		for _, from := range []string{"database", "cache"} {
+			prds, err := tt.query(r)
+			if err != nil {
+				t.Fatalf("%s from the %s: %s", tt.name, from, err)
+			}
+
+			data, err := json.Marshal(prds)
+			if err != nil {
+				t.Fatalf("%s from the %s: marshaling: %s", tt.name, from, err)
+			}
+			if string(data) != "[]" {
+				t.Errorf("%s from the %s: got %s, want []", tt.name, from, data)
+			}
+		}
+
+		if repo.calls != 1 {
+			t.Errorf("%s: got %d database queries, want 1", tt.name, repo.calls)
+		}
+	}
+}
+
+// =============================================================================
+
+// emptyRepo has no products and counts the queries it gets.
+type emptyRepo struct {
+	product.Repository
+	calls int
+}
+
+func (r *emptyRepo) Query(ctx context.Context, filter product.QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) ([]product.Product, error) {
+	r.calls++
+	return []product.Product{}, nil
+}
+
+func (r *emptyRepo) QueryAfter(ctx context.Context, filter product.QueryFilter, orderBy order.By, after *order.Key, limit int) ([]product.Product, error) {
+	r.calls++
+	return []product.Product{}, nil
+}
+
+func (r *emptyRepo) QueryByUserID(ctx context.Context, userID uuid.UUID) ([]product.Product, error) {
+	r.calls++
+	return []product.Product{}, nil
+}
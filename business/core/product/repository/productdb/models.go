@@ -71,6 +71,16 @@ var orderByFields = map[string]string{
 
 // orderByClause validates the order by for correct fields and sql injection.
 func orderByClause(orderBy order.By) (string, error) {
+	by, err := orderByColumn(orderBy)
+	if err != nil {
+		return "", err
+	}
+
+	return by + " " + orderBy.Direction, nil
+}
+
+// orderByColumn validates the order by and returns the column it is for.
+func orderByColumn(orderBy order.By) (string, error) {
 	if err := order.Validate(orderBy.Field, orderBy.Direction); err != nil {
 		return "", err
 	}
@@ -80,5 +90,5 @@ func orderByClause(orderBy order.By) (string, error) {
 		return "", fmt.Errorf("field %q does not exists", orderBy.Field)
 	}
 
-	return by + " " + orderBy.Direction, nil
+	return by, nil
 }
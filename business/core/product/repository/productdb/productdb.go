@@ -75,8 +75,9 @@ const (
 
 // Repository manages the set of APIs for product database access.
 type Repository struct {
-	log *zap.SugaredLogger
-	db  sqlx.ExtContext
+	log    *zap.SugaredLogger
+	db     sqlx.ExtContext
+	inTran bool
 }
 
 // NewRepository constructs the api for data access.
@@ -90,6 +91,24 @@ func NewRepository(log *zap.SugaredLogger, db *sqlx.DB) *Repository {
 	}
 }
 
+// WithinTran runs passed function and do commit/rollback at the end.
+func (r *Repository) WithinTran(ctx context.Context, fn func(ctx context.Context, s product.Repository) error) error {
+	if r.inTran {
+		return fn(ctx, r)
+	}
+
+	f := func(ctx context.Context, tx *sqlx.Tx) error {
+		s := &Repository{
+			log:    r.log,
+			db:     tx,
+			inTran: true,
+		}
+		return fn(ctx, s)
+	}
+
+	return database.WithinTran(ctx, r.log, r.db.(*sqlx.DB), f)
+}
+
 func (r *Repository) Create(ctx context.Context, prd product.Product) error {
 	if err := database.NamedExecContext(ctx, r.log, r.db, createQuery, toDBProduct(prd)); err != nil {
 		return fmt.Errorf("inserting product: %w", err)
@@ -111,29 +130,112 @@ func (r *Repository) Delete(ctx context.Context, prd product.Product) error {
 		ProductID: prd.ID.String(),
 	}
 	if err := database.NamedExecContext(ctx, r.log, r.db, deleteQuery, data); err != nil {
+		if errors.Is(err, database.ErrDBReferenced) {
+			return product.ErrInUse
+		}
 		return fmt.Errorf("deleting product productID[%s]: %w", prd.ID, err)
 	}
 	return nil
 }
 
 func (r *Repository) Query(ctx context.Context, filter product.QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) ([]product.Product, error) {
-	data := struct {
-		ID          string `db:"id"`
-		Name        string `db:"name"`
-		Cost        int    `db:"cost"`
-		Quantity    int    `db:"quantity"`
-		Offset      int    `db:"offset"`
-		RowsPerPage int    `db:"rows_per_page"`
-	}{
-		Offset:      (pageNumber - 1) * rowsPerPage,
-		RowsPerPage: rowsPerPage,
+	buf, data, err := filteredQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeOrderBy(buf, orderBy); err != nil {
+		return nil, err
 	}
+	data.Offset = (pageNumber - 1) * rowsPerPage
+	data.RowsPerPage = rowsPerPage
+	buf.WriteString(" OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY ")
 
-	orderByClause, err := orderByClause(orderBy)
+	var prds []dbProduct
+	if err := database.NamedQuerySlice(ctx, r.log, r.db, buf.String(), data, &prds); err != nil {
+		return nil, fmt.Errorf("selecting products: %w", err)
+	}
+
+	return toCoreProductSlice(prds), nil
+}
+
+// QueryAfter gets up to limit products that come after the key in the
+// order. The product id breaks ties so no product is skipped or repeated
+// between pages.
+func (r *Repository) QueryAfter(ctx context.Context, filter product.QueryFilter, orderBy order.By, after *order.Key, limit int) ([]product.Product, error) {
+	column, err := orderByColumn(orderBy)
 	if err != nil {
 		return nil, err
 	}
 
+	inner, data, err := filteredQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+	data.RowsPerPage = limit
+
+	// The products are selected in a subquery so the sold and revenue
+	// totals can be compared like any other column.
+	buf := bytes.NewBufferString("SELECT * FROM (")
+	buf.WriteString(inner.String())
+	buf.WriteString(") AS q ")
+
+	if after != nil {
+		data.AfterValue = after.Value
+		data.AfterID = after.ID
+
+		op := ">"
+		if orderBy.Direction == order.DESC {
+			op = "<"
+		}
+		fmt.Fprintf(buf, "WHERE (q.%s, q.product_id) %s (:after_value, :after_id) ", column, op)
+	}
+
+	fmt.Fprintf(buf, "ORDER BY q.%[1]s %[2]s, q.product_id %[2]s ", column, orderBy.Direction)
+	buf.WriteString("FETCH FIRST :rows_per_page ROWS ONLY")
+
+	var prds []dbProduct
+	if err := database.NamedQuerySlice(ctx, r.log, r.db, buf.String(), data, &prds); err != nil {
+		return nil, fmt.Errorf("selecting products: %w", err)
+	}
+
+	return toCoreProductSlice(prds), nil
+}
+
+func (r *Repository) QueryAll(ctx context.Context, filter product.QueryFilter, orderBy order.By, fn func(product.Product) error) error {
+	buf, data, err := filteredQuery(filter)
+	if err != nil {
+		return err
+	}
+	if err := writeOrderBy(buf, orderBy); err != nil {
+		return err
+	}
+
+	f := func(prd dbProduct) error {
+		return fn(toCoreProduct(prd))
+	}
+	if err := database.NamedQueryStream(ctx, r.log, r.db, buf.String(), data, f); err != nil {
+		return fmt.Errorf("streaming products: %w", err)
+	}
+
+	return nil
+}
+
+// filterData holds the values of the named parameters of a filtered query.
+type filterData struct {
+	ID          string `db:"id"`
+	Name        string `db:"name"`
+	Cost        int    `db:"cost"`
+	Quantity    int    `db:"quantity"`
+	Offset      int    `db:"offset"`
+	RowsPerPage int    `db:"rows_per_page"`
+	AfterValue  string `db:"after_value"`
+	AfterID     string `db:"after_id"`
+}
+
+// filteredQuery builds the query selecting the products that match the
+// filter.
+func filteredQuery(filter product.QueryFilter) (*bytes.Buffer, filterData, error) {
+	var data filterData
 	var wc []string
 	if filter.ID != nil {
 		data.ID = *filter.ID
@@ -157,16 +259,21 @@ func (r *Repository) Query(ctx context.Context, filter product.QueryFilter, orde
 		buf.WriteString(strings.Join(wc, " AND "))
 	}
 	buf.WriteString(" GROUP BY p.product_id ")
-	buf.WriteString(" ORDER BY ")
-	buf.WriteString(orderByClause)
-	buf.WriteString(" OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY ")
 
-	var prds []dbProduct
-	if err := database.NamedQuerySlice(ctx, r.log, r.db, buf.String(), data, &prds); err != nil {
-		return nil, fmt.Errorf("selecting products: %w", err)
+	return buf, data, nil
+}
+
+// writeOrderBy appends the order by clause to the query.
+func writeOrderBy(buf *bytes.Buffer, orderBy order.By) error {
+	orderByClause, err := orderByClause(orderBy)
+	if err != nil {
+		return err
 	}
 
-	return toCoreProductSlice(prds), nil
+	buf.WriteString(" ORDER BY ")
+	buf.WriteString(orderByClause)
+
+	return nil
 }
 
 func (r *Repository) QueryByID(ctx context.Context, productID uuid.UUID) (product.Product, error) {
@@ -0,0 +1,14 @@
+package revocation
+
+import (
+	"time"
+)
+
+// Revocation records a token that can't be used anymore even though it
+// hasn't expired. A zero DateExpires means the token never expires.
+type Revocation struct {
+	TokenID     string    `json:"token_id"`
+	Subject     string    `json:"subject"`
+	DateExpires time.Time `json:"date_expires"`
+	DateRevoked time.Time `json:"date_revoked"`
+}
@@ -0,0 +1,42 @@
+package revocationdb
+
+import (
+	"database/sql"
+	"github.com/halilylm/micro/business/core/revocation"
+	"time"
+)
+
+// dbRevocation represent the structure we need for moving data
+// between the app and the database.
+type dbRevocation struct {
+	TokenID     string       `db:"token_id"`
+	Subject     string       `db:"subject"`
+	DateExpires sql.NullTime `db:"date_expires"`
+	DateRevoked time.Time    `db:"date_revoked"`
+}
+
+func toDBRevocation(rev revocation.Revocation) dbRevocation {
+	return dbRevocation{
+		TokenID: rev.TokenID,
+		Subject: rev.Subject,
+		DateExpires: sql.NullTime{
+			Time:  rev.DateExpires.UTC(),
+			Valid: !rev.DateExpires.IsZero(),
+		},
+		DateRevoked: rev.DateRevoked.UTC(),
+	}
+}
+
+func toCoreRevocation(dbRev dbRevocation) revocation.Revocation {
+	rev := revocation.Revocation{
+		TokenID:     dbRev.TokenID,
+		Subject:     dbRev.Subject,
+		DateRevoked: dbRev.DateRevoked.In(time.Local),
+	}
+
+	if dbRev.DateExpires.Valid {
+		rev.DateExpires = dbRev.DateExpires.Time.In(time.Local)
+	}
+
+	return rev
+}
@@ -0,0 +1,95 @@
+// Package revocationdb contains token revocation related CRUD functionality.
+package revocationdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/halilylm/micro/business/core/revocation"
+	"github.com/halilylm/micro/business/sys/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Repository manages the set of APIs for revocation database access.
+type Repository struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewRepository constructs the api for data access.
+func NewRepository(log *zap.SugaredLogger, db *sqlx.DB) *Repository {
+	if log == nil {
+		log = zap.NewNop().Sugar()
+	}
+	return &Repository{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create inserts a new revocation into the database. A token that is
+// already revoked is left as it is.
+func (r *Repository) Create(ctx context.Context, rev revocation.Revocation) error {
+	const q = `
+	INSERT INTO revoked_tokens
+		(token_id, subject, date_expires, date_revoked)
+	VALUES
+		(:token_id, :subject, :date_expires, :date_revoked)
+	ON CONFLICT (token_id) DO NOTHING`
+
+	if err := database.NamedExecContext(ctx, r.log, r.db, q, toDBRevocation(rev)); err != nil {
+		return fmt.Errorf("inserting revocation: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes the revocations of tokens that have expired.
+func (r *Repository) DeleteExpired(ctx context.Context, now time.Time) error {
+	data := struct {
+		Now time.Time `db:"now"`
+	}{
+		Now: now.UTC(),
+	}
+
+	const q = `
+	DELETE FROM
+		revoked_tokens
+	WHERE
+		date_expires < :now`
+
+	if err := database.NamedExecContext(ctx, r.log, r.db, q, data); err != nil {
+		return fmt.Errorf("deleting expired revocations: %w", err)
+	}
+
+	return nil
+}
+
+// QueryByTokenID gets the revocation of the specified token.
+func (r *Repository) QueryByTokenID(ctx context.Context, tokenID string) (revocation.Revocation, error) {
+	data := struct {
+		TokenID string `db:"token_id"`
+	}{
+		TokenID: tokenID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		revoked_tokens
+	WHERE
+		token_id = :token_id`
+
+	var rev dbRevocation
+	if err := database.NamedQueryStruct(ctx, r.log, r.db, q, data, &rev); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return revocation.Revocation{}, revocation.ErrNotFound
+		}
+		return revocation.Revocation{}, fmt.Errorf("selecting tokenID[%q]: %w", tokenID, err)
+	}
+
+	return toCoreRevocation(rev), nil
+}
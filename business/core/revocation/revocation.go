@@ -0,0 +1,77 @@
+// Package revocation provides the core business API for keeping track of
+// tokens that were revoked before they expired.
+package revocation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/halilylm/micro/business/core/audit"
+	"time"
+)
+
+// Set of error variables for CRUD operations.
+var (
+	ErrNotFound = errors.New("revocation not found")
+)
+
+// Repository interface declares the behaviour this package needs to persist
+// and retrieve data.
+type Repository interface {
+	Create(ctx context.Context, rev Revocation) error
+	DeleteExpired(ctx context.Context, now time.Time) error
+	QueryByTokenID(ctx context.Context, tokenID string) (Revocation, error)
+}
+
+// Core manages the set of APIs for revocation access.
+type Core struct {
+	audit *audit.Core
+	repo  Repository
+}
+
+// NewCore constructs a core for revocation access.
+func NewCore(audCore *audit.Core, repo Repository) *Core {
+	return &Core{
+		audit: audCore,
+		repo:  repo,
+	}
+}
+
+// Revoke records that the token can't be used anymore. Revoking a token
+// twice has no effect. Revocations of tokens that have expired since are
+// removed along the way since they are no longer needed.
+func (c *Core) Revoke(ctx context.Context, tokenID string, subject string, expires time.Time) (Revocation, error) {
+	rev := Revocation{
+		TokenID:     tokenID,
+		Subject:     subject,
+		DateExpires: expires,
+		DateRevoked: time.Now(),
+	}
+
+	if err := c.repo.Create(ctx, rev); err != nil {
+		return Revocation{}, fmt.Errorf("create: %w", err)
+	}
+
+	if err := c.audit.Record(ctx, audit.ActionRevoke, audit.EntityToken, rev.TokenID, nil, rev); err != nil {
+		return Revocation{}, fmt.Errorf("audit: %w", err)
+	}
+
+	if err := c.repo.DeleteExpired(ctx, rev.DateRevoked); err != nil {
+		return Revocation{}, fmt.Errorf("delete expired: %w", err)
+	}
+
+	return rev, nil
+}
+
+// IsRevoked reports whether the token was revoked.
+func (c *Core) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	_, err := c.repo.QueryByTokenID(ctx, tokenID)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, ErrNotFound):
+		return false, nil
+	default:
+		return false, fmt.Errorf("query: %w", err)
+	}
+}
@@ -0,0 +1,58 @@
+package role
+
+import (
+	"github.com/halilylm/micro/business/sys/validate"
+	"time"
+)
+
+// Role represents a named set of permissions that can be given to users.
+type Role struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	DateCreated time.Time `json:"date_created"`
+	DateUpdated time.Time `json:"date_updated"`
+}
+
+// NewRole contains information needed to create a new Role.
+type NewRole struct {
+	Name        string   `json:"name" validate:"required,uppercase"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// Validate checks the NewRole against its validate tags.
+func (nr NewRole) Validate() error {
+	return validate.Check(nr)
+}
+
+// UpdateRole defines what information may be provided to modify an existing
+// Role. All fields are optional so clients can send just the fields they want
+// changed.
+type UpdateRole struct {
+	Description *string  `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// Validate checks the UpdateRole against its validate tags.
+func (ur UpdateRole) Validate() error {
+	return validate.Check(ur)
+}
+
+// Permission represents a capability policies can check for.
+type Permission struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	DateCreated time.Time `json:"date_created"`
+}
+
+// NewPermission contains information needed to create a new Permission.
+type NewPermission struct {
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+}
+
+// Validate checks the NewPermission against its validate tags.
+func (np NewPermission) Validate() error {
+	return validate.Check(np)
+}
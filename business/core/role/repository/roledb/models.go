@@ -0,0 +1,82 @@
+package roledb
+
+import (
+	"github.com/halilylm/micro/business/core/role"
+	"github.com/lib/pq"
+	"time"
+)
+
+// dbRole represent the structure we need for moving data
+// between the app and the database.
+type dbRole struct {
+	Name        string         `db:"name"`
+	Description string         `db:"description"`
+	Permissions pq.StringArray `db:"permissions"`
+	DateCreated time.Time      `db:"date_created"`
+	DateUpdated time.Time      `db:"date_updated"`
+}
+
+func toDBRole(rol role.Role) dbRole {
+	return dbRole{
+		Name:        rol.Name,
+		Description: rol.Description,
+		Permissions: rol.Permissions,
+		DateCreated: rol.DateCreated.UTC(),
+		DateUpdated: rol.DateUpdated.UTC(),
+	}
+}
+
+func toCoreRole(dbRol dbRole) role.Role {
+	perms := []string(dbRol.Permissions)
+	if perms == nil {
+		perms = []string{}
+	}
+
+	return role.Role{
+		Name:        dbRol.Name,
+		Description: dbRol.Description,
+		Permissions: perms,
+		DateCreated: dbRol.DateCreated.In(time.Local),
+		DateUpdated: dbRol.DateUpdated.In(time.Local),
+	}
+}
+
+func toCoreRoleSlice(dbRoles []dbRole) []role.Role {
+	roles := make([]role.Role, len(dbRoles))
+	for i, dbRol := range dbRoles {
+		roles[i] = toCoreRole(dbRol)
+	}
+	return roles
+}
+
+// dbPermission represent the structure we need for moving data
+// between the app and the database.
+type dbPermission struct {
+	Name        string    `db:"name"`
+	Description string    `db:"description"`
+	DateCreated time.Time `db:"date_created"`
+}
+
+func toDBPermission(perm role.Permission) dbPermission {
+	return dbPermission{
+		Name:        perm.Name,
+		Description: perm.Description,
+		DateCreated: perm.DateCreated.UTC(),
+	}
+}
+
+func toCorePermission(dbPerm dbPermission) role.Permission {
+	return role.Permission{
+		Name:        dbPerm.Name,
+		Description: dbPerm.Description,
+		DateCreated: dbPerm.DateCreated.In(time.Local),
+	}
+}
+
+func toCorePermissionSlice(dbPerms []dbPermission) []role.Permission {
+	perms := make([]role.Permission, len(dbPerms))
+	for i, dbPerm := range dbPerms {
+		perms[i] = toCorePermission(dbPerm)
+	}
+	return perms
+}
@@ -0,0 +1,315 @@
+// Package roledb contains role and permission related CRUD functionality.
+package roledb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/halilylm/micro/business/core/role"
+	"github.com/halilylm/micro/business/sys/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+const (
+	selectRoles = `
+	SELECT
+		r.name,
+		r.description,
+		r.date_created,
+		r.date_updated,
+		ARRAY_REMOVE(ARRAY_AGG(rp.permission_name ORDER BY rp.permission_name), NULL) AS permissions
+	FROM
+		roles AS r
+	LEFT JOIN
+		role_permissions AS rp ON rp.role_name = r.name
+	`
+)
+
+// Repository manages the set of APIs for role database access.
+type Repository struct {
+	log    *zap.SugaredLogger
+	db     sqlx.ExtContext
+	inTran bool
+}
+
+// NewRepository constructs the api for data access.
+func NewRepository(log *zap.SugaredLogger, db *sqlx.DB) *Repository {
+	if log == nil {
+		log = zap.NewNop().Sugar()
+	}
+	return &Repository{
+		log: log,
+		db:  db,
+	}
+}
+
+// WithinTran runs passed function and do commit/rollback at the end.
+func (r *Repository) WithinTran(ctx context.Context, fn func(ctx context.Context, s role.Repository) error) error {
+	if r.inTran {
+		return fn(ctx, r)
+	}
+
+	f := func(ctx context.Context, tx *sqlx.Tx) error {
+		s := &Repository{
+			log:    r.log,
+			db:     tx,
+			inTran: true,
+		}
+		return fn(ctx, s)
+	}
+
+	return database.WithinTran(ctx, r.log, r.db.(*sqlx.DB), f)
+}
+
+// Create inserts a new role into the database.
+func (r *Repository) Create(ctx context.Context, rol role.Role) error {
+	const q = `
+	INSERT INTO roles
+		(name, description, date_created, date_updated)
+	VALUES
+		(:name, :description, :date_created, :date_updated)`
+
+	if err := database.NamedExecContext(ctx, r.log, r.db, q, toDBRole(rol)); err != nil {
+		if errors.Is(err, database.ErrDBDuplicatedEntry) {
+			return fmt.Errorf("create: %w", role.ErrUniqueName)
+		}
+		return fmt.Errorf("inserting role: %w", err)
+	}
+
+	return nil
+}
+
+// Update replaces a role document in the database.
+func (r *Repository) Update(ctx context.Context, rol role.Role) error {
+	const q = `
+	UPDATE
+		roles
+	SET
+		"description" = :description,
+		"date_updated" = :date_updated
+	WHERE
+		name = :name`
+
+	if err := database.NamedExecContext(ctx, r.log, r.db, q, toDBRole(rol)); err != nil {
+		return fmt.Errorf("updating role[%s]: %w", rol.Name, err)
+	}
+
+	return nil
+}
+
+// Delete removes a role from the database.
+func (r *Repository) Delete(ctx context.Context, rol role.Role) error {
+	data := struct {
+		Name string `db:"name"`
+	}{
+		Name: rol.Name,
+	}
+
+	const q = `
+	DELETE FROM
+		roles
+	WHERE
+		name = :name`
+
+	if err := database.NamedExecContext(ctx, r.log, r.db, q, data); err != nil {
+		return fmt.Errorf("deleting role[%s]: %w", rol.Name, err)
+	}
+
+	return nil
+}
+
+// SetPermissions replaces the set of permissions given to a role.
+func (r *Repository) SetPermissions(ctx context.Context, rol role.Role) error {
+	data := struct {
+		Name string `db:"role_name"`
+	}{
+		Name: rol.Name,
+	}
+
+	const del = `
+	DELETE FROM
+		role_permissions
+	WHERE
+		role_name = :role_name`
+
+	if err := database.NamedExecContext(ctx, r.log, r.db, del, data); err != nil {
+		return fmt.Errorf("clearing permissions role[%s]: %w", rol.Name, err)
+	}
+
+	const ins = `
+	INSERT INTO role_permissions
+		(role_name, permission_name)
+	VALUES
+		(:role_name, :permission_name)`
+
+	for _, perm := range rol.Permissions {
+		data := struct {
+			RoleName       string `db:"role_name"`
+			PermissionName string `db:"permission_name"`
+		}{
+			RoleName:       rol.Name,
+			PermissionName: perm,
+		}
+
+		if err := database.NamedExecContext(ctx, r.log, r.db, ins, data); err != nil {
+			if errors.Is(err, database.ErrDBDuplicatedEntry) {
+				continue
+			}
+			return fmt.Errorf("adding permission[%s] role[%s]: %w", perm, rol.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// CountUsers returns the number of users that have the role.
+func (r *Repository) CountUsers(ctx context.Context, rol role.Role) (int, error) {
+	data := struct {
+		Name string `db:"name"`
+	}{
+		Name: rol.Name,
+	}
+
+	const q = `
+	SELECT
+		COUNT(*) AS count
+	FROM
+		users
+	WHERE
+		:name = ANY(roles)`
+
+	var result struct {
+		Count int `db:"count"`
+	}
+	if err := database.NamedQueryStruct(ctx, r.log, r.db, q, data, &result); err != nil {
+		return 0, fmt.Errorf("counting users role[%s]: %w", rol.Name, err)
+	}
+
+	return result.Count, nil
+}
+
+// Query retrieves all roles from the database.
+func (r *Repository) Query(ctx context.Context) ([]role.Role, error) {
+	q := selectRoles + `
+	GROUP BY
+		r.name
+	ORDER BY
+		r.name`
+
+	var roles []dbRole
+	if err := database.QuerySlice(ctx, r.log, r.db, q, &roles); err != nil {
+		return nil, fmt.Errorf("selecting roles: %w", err)
+	}
+
+	return toCoreRoleSlice(roles), nil
+}
+
+// QueryByName gets the specified role from the database.
+func (r *Repository) QueryByName(ctx context.Context, name string) (role.Role, error) {
+	data := struct {
+		Name string `db:"name"`
+	}{
+		Name: name,
+	}
+
+	q := selectRoles + `
+	WHERE
+		r.name = :name
+	GROUP BY
+		r.name`
+
+	var rol dbRole
+	if err := database.NamedQueryStruct(ctx, r.log, r.db, q, data, &rol); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return role.Role{}, role.ErrNotFound
+		}
+		return role.Role{}, fmt.Errorf("selecting role[%q]: %w", name, err)
+	}
+
+	return toCoreRole(rol), nil
+}
+
+// CreatePermission inserts a new permission into the database.
+func (r *Repository) CreatePermission(ctx context.Context, perm role.Permission) error {
+	const q = `
+	INSERT INTO permissions
+		(name, description, date_created)
+	VALUES
+		(:name, :description, :date_created)`
+
+	if err := database.NamedExecContext(ctx, r.log, r.db, q, toDBPermission(perm)); err != nil {
+		if errors.Is(err, database.ErrDBDuplicatedEntry) {
+			return fmt.Errorf("create: %w", role.ErrUniqueName)
+		}
+		return fmt.Errorf("inserting permission: %w", err)
+	}
+
+	return nil
+}
+
+// DeletePermission removes a permission from the database.
+func (r *Repository) DeletePermission(ctx context.Context, perm role.Permission) error {
+	data := struct {
+		Name string `db:"name"`
+	}{
+		Name: perm.Name,
+	}
+
+	const q = `
+	DELETE FROM
+		permissions
+	WHERE
+		name = :name`
+
+	if err := database.NamedExecContext(ctx, r.log, r.db, q, data); err != nil {
+		return fmt.Errorf("deleting permission[%s]: %w", perm.Name, err)
+	}
+
+	return nil
+}
+
+// QueryPermissions retrieves all permissions from the database.
+func (r *Repository) QueryPermissions(ctx context.Context) ([]role.Permission, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		permissions
+	ORDER BY
+		name`
+
+	var perms []dbPermission
+	if err := database.QuerySlice(ctx, r.log, r.db, q, &perms); err != nil {
+		return nil, fmt.Errorf("selecting permissions: %w", err)
+	}
+
+	return toCorePermissionSlice(perms), nil
+}
+
+// QueryPermissionByName gets the specified permission from the database.
+func (r *Repository) QueryPermissionByName(ctx context.Context, name string) (role.Permission, error) {
+	data := struct {
+		Name string `db:"name"`
+	}{
+		Name: name,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		permissions
+	WHERE
+		name = :name`
+
+	var perm dbPermission
+	if err := database.NamedQueryStruct(ctx, r.log, r.db, q, data, &perm); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return role.Permission{}, role.ErrPermissionNotFound
+		}
+		return role.Permission{}, fmt.Errorf("selecting permission[%q]: %w", name, err)
+	}
+
+	return toCorePermission(perm), nil
+}
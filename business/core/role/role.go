@@ -0,0 +1,285 @@
+// Package role provides the core business API for the roles and permissions
+// that are used by the authorization policies.
+package role
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/halilylm/micro/business/core/audit"
+	"github.com/halilylm/micro/business/sys/validate"
+	"time"
+)
+
+// Set of error variables for CRUD operations.
+var (
+	ErrNotFound           = errors.New("role not found")
+	ErrPermissionNotFound = errors.New("permission not found")
+	ErrUniqueName         = errors.New("name is not unique")
+	ErrInUse              = errors.New("still assigned and can't be deleted")
+	ErrUnknownPermission  = errors.New("unknown permission")
+)
+
+// Repository interface declares the behaviour this package needs to persist
+// and retrieve data.
+type Repository interface {
+	WithinTran(ctx context.Context, fn func(ctx context.Context, r Repository) error) error
+	Create(ctx context.Context, rol Role) error
+	Update(ctx context.Context, rol Role) error
+	Delete(ctx context.Context, rol Role) error
+	SetPermissions(ctx context.Context, rol Role) error
+	CountUsers(ctx context.Context, rol Role) (int, error)
+	Query(ctx context.Context) ([]Role, error)
+	QueryByName(ctx context.Context, name string) (Role, error)
+	CreatePermission(ctx context.Context, perm Permission) error
+	DeletePermission(ctx context.Context, perm Permission) error
+	QueryPermissions(ctx context.Context) ([]Permission, error)
+	QueryPermissionByName(ctx context.Context, name string) (Permission, error)
+}
+
+// Core manages the set of APIs for role access.
+type Core struct {
+	repo  Repository
+	audit *audit.Core
+}
+
+// NewCore constructs a core for role api access.
+func NewCore(repo Repository, audCore *audit.Core) *Core {
+	return &Core{
+		repo:  repo,
+		audit: audCore,
+	}
+}
+
+// Create inserts a new role and its permissions into the database.
+func (c *Core) Create(ctx context.Context, nr NewRole) (Role, error) {
+	if err := validate.Check(nr); err != nil {
+		return Role{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	if err := c.checkPermissions(ctx, nr.Permissions); err != nil {
+		return Role{}, err
+	}
+
+	now := time.Now()
+
+	rol := Role{
+		Name:        nr.Name,
+		Description: nr.Description,
+		Permissions: nr.Permissions,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	tran := func(ctx context.Context, r Repository) error {
+		if err := r.Create(ctx, rol); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+		if err := r.SetPermissions(ctx, rol); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+		if err := c.audit.Record(ctx, audit.ActionCreate, audit.EntityRole, rol.Name, nil, rol); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+		return nil
+	}
+
+	if err := c.repo.WithinTran(ctx, tran); err != nil {
+		return Role{}, err
+	}
+
+	return rol, nil
+}
+
+// Update modifies the description and permissions of a role.
+func (c *Core) Update(ctx context.Context, rol Role, ur UpdateRole) (Role, error) {
+	if err := validate.Check(ur); err != nil {
+		return Role{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	before := rol
+
+	if ur.Description != nil {
+		rol.Description = *ur.Description
+	}
+	if ur.Permissions != nil {
+		if err := c.checkPermissions(ctx, ur.Permissions); err != nil {
+			return Role{}, err
+		}
+		rol.Permissions = ur.Permissions
+	}
+	rol.DateUpdated = time.Now()
+
+	tran := func(ctx context.Context, r Repository) error {
+		if err := r.Update(ctx, rol); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+		if err := r.SetPermissions(ctx, rol); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+		if err := c.audit.Record(ctx, audit.ActionUpdate, audit.EntityRole, rol.Name, before, rol); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+		return nil
+	}
+
+	if err := c.repo.WithinTran(ctx, tran); err != nil {
+		return Role{}, err
+	}
+
+	return rol, nil
+}
+
+// Delete removes a role that isn't assigned to any user.
+func (c *Core) Delete(ctx context.Context, rol Role) error {
+	n, err := c.repo.CountUsers(ctx, rol)
+	if err != nil {
+		return fmt.Errorf("count users: %w", err)
+	}
+
+	if n > 0 {
+		return fmt.Errorf("role[%s] has %d users: %w", rol.Name, n, ErrInUse)
+	}
+
+	tran := func(ctx context.Context, r Repository) error {
+		if err := r.Delete(ctx, rol); err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+		if err := c.audit.Record(ctx, audit.ActionDelete, audit.EntityRole, rol.Name, rol, nil); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+		return nil
+	}
+
+	return c.repo.WithinTran(ctx, tran)
+}
+
+// Query retrieves all roles with their permissions.
+func (c *Core) Query(ctx context.Context) ([]Role, error) {
+	roles, err := c.repo.Query(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return roles, nil
+}
+
+// QueryByName gets the specified role from the database.
+func (c *Core) QueryByName(ctx context.Context, name string) (Role, error) {
+	rol, err := c.repo.QueryByName(ctx, name)
+	if err != nil {
+		return Role{}, fmt.Errorf("query: %w", err)
+	}
+
+	return rol, nil
+}
+
+// CreatePermission inserts a new permission into the database.
+func (c *Core) CreatePermission(ctx context.Context, np NewPermission) (Permission, error) {
+	if err := validate.Check(np); err != nil {
+		return Permission{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	perm := Permission{
+		Name:        np.Name,
+		Description: np.Description,
+		DateCreated: time.Now(),
+	}
+
+	tran := func(ctx context.Context, r Repository) error {
+		if err := r.CreatePermission(ctx, perm); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+		if err := c.audit.Record(ctx, audit.ActionCreate, audit.EntityPermission, perm.Name, nil, perm); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+		return nil
+	}
+
+	if err := c.repo.WithinTran(ctx, tran); err != nil {
+		return Permission{}, err
+	}
+
+	return perm, nil
+}
+
+// DeletePermission removes a permission, which also takes it away from
+// every role that had it.
+func (c *Core) DeletePermission(ctx context.Context, perm Permission) error {
+	tran := func(ctx context.Context, r Repository) error {
+		if err := r.DeletePermission(ctx, perm); err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+		if err := c.audit.Record(ctx, audit.ActionDelete, audit.EntityPermission, perm.Name, perm, nil); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+		return nil
+	}
+
+	return c.repo.WithinTran(ctx, tran)
+}
+
+// QueryPermissions retrieves all permissions.
+func (c *Core) QueryPermissions(ctx context.Context) ([]Permission, error) {
+	perms, err := c.repo.QueryPermissions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return perms, nil
+}
+
+// QueryPermissionByName gets the specified permission from the database.
+func (c *Core) QueryPermissionByName(ctx context.Context, name string) (Permission, error) {
+	perm, err := c.repo.QueryPermissionByName(ctx, name)
+	if err != nil {
+		return Permission{}, fmt.Errorf("query: %w", err)
+	}
+
+	return perm, nil
+}
+
+// PolicyData returns the roles and their permissions in the shape of the
+// data document the authorization policy expects.
+func (c *Core) PolicyData(ctx context.Context) (map[string]any, error) {
+	roles, err := c.Query(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]any, len(roles))
+	for _, rol := range roles {
+		perms := make([]any, len(rol.Permissions))
+		for i, perm := range rol.Permissions {
+			perms[i] = perm
+		}
+		m[rol.Name] = map[string]any{
+			"permissions": perms,
+		}
+	}
+
+	return map[string]any{"roles": m}, nil
+}
+
+// =============================================================================
+
+// checkPermissions validates that every permission exists.
+func (c *Core) checkPermissions(ctx context.Context, names []string) error {
+	perms, err := c.repo.QueryPermissions(ctx)
+	if err != nil {
+		return fmt.Errorf("query permissions: %w", err)
+	}
+
+	known := make(map[string]bool, len(perms))
+	for _, perm := range perms {
+		known[perm.Name] = true
+	}
+
+	for _, name := range names {
+		if !known[name] {
+			return fmt.Errorf("%w: %s", ErrUnknownPermission, name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,17 @@
+package sale
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Sale represents an individual sale of a product. The buyer is the user
+// that made the purchase.
+type Sale struct {
+	ID          uuid.UUID `json:"id"`
+	UserID      uuid.UUID `json:"user_id"`
+	ProductID   uuid.UUID `json:"product_id"`
+	Quantity    int       `json:"quantity"`
+	Paid        int       `json:"paid"`
+	DateCreated time.Time `json:"date_created"`
+}
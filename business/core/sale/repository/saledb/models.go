@@ -0,0 +1,37 @@
+package saledb
+
+import (
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/sale"
+	"time"
+)
+
+// dbSale represent the structure we need for moving data
+// between the app and the database.
+type dbSale struct {
+	ID          uuid.UUID `db:"sale_id"`
+	UserID      uuid.UUID `db:"user_id"`
+	ProductID   uuid.UUID `db:"product_id"`
+	Quantity    int       `db:"quantity"`
+	Paid        int       `db:"paid"`
+	DateCreated time.Time `db:"date_created"`
+}
+
+func toCoreSale(dbSl dbSale) sale.Sale {
+	return sale.Sale{
+		ID:          dbSl.ID,
+		UserID:      dbSl.UserID,
+		ProductID:   dbSl.ProductID,
+		Quantity:    dbSl.Quantity,
+		Paid:        dbSl.Paid,
+		DateCreated: dbSl.DateCreated.In(time.Local),
+	}
+}
+
+func toCoreSaleSlice(dbSales []dbSale) []sale.Sale {
+	sales := make([]sale.Sale, len(dbSales))
+	for i, dbSl := range dbSales {
+		sales[i] = toCoreSale(dbSl)
+	}
+	return sales
+}
@@ -0,0 +1,83 @@
+// Package saledb contains sale related read functionality.
+package saledb
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/sale"
+	"github.com/halilylm/micro/business/sys/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Repository manages the set of APIs for sale database access.
+type Repository struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewRepository constructs the api for data access.
+func NewRepository(log *zap.SugaredLogger, db *sqlx.DB) *Repository {
+	if log == nil {
+		log = zap.NewNop().Sugar()
+	}
+	return &Repository{
+		log: log,
+		db:  db,
+	}
+}
+
+// QueryByUserID retrieves the sales where the user is the buyer.
+func (r *Repository) QueryByUserID(ctx context.Context, userID uuid.UUID) ([]sale.Sale, error) {
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID.String(),
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		sales
+	WHERE
+		user_id = :user_id
+	ORDER BY
+		date_created`
+
+	var sales []dbSale
+	if err := database.NamedQuerySlice(ctx, r.log, r.db, q, data, &sales); err != nil {
+		return nil, fmt.Errorf("selecting sales userID[%s]: %w", userID, err)
+	}
+
+	return toCoreSaleSlice(sales), nil
+}
+
+// QueryBySellerID retrieves the sales of the products owned by the user.
+func (r *Repository) QueryBySellerID(ctx context.Context, userID uuid.UUID) ([]sale.Sale, error) {
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID.String(),
+	}
+
+	const q = `
+	SELECT
+		s.*
+	FROM
+		sales AS s
+	JOIN
+		products AS p ON p.product_id = s.product_id
+	WHERE
+		p.user_id = :user_id
+	ORDER BY
+		s.date_created`
+
+	var sales []dbSale
+	if err := database.NamedQuerySlice(ctx, r.log, r.db, q, data, &sales); err != nil {
+		return nil, fmt.Errorf("selecting sales sellerID[%s]: %w", userID, err)
+	}
+
+	return toCoreSaleSlice(sales), nil
+}
@@ -0,0 +1,45 @@
+// Package sale provides the core business API for reading the sales ledger.
+package sale
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+// Repository interface declares the behaviour this package needs to
+// retrieve data.
+type Repository interface {
+	QueryByUserID(ctx context.Context, userID uuid.UUID) ([]Sale, error)
+	QueryBySellerID(ctx context.Context, userID uuid.UUID) ([]Sale, error)
+}
+
+// Core manages the set of APIs for sale access.
+type Core struct {
+	repo Repository
+}
+
+// NewCore constructs a core for sale api access.
+func NewCore(repo Repository) *Core {
+	return &Core{repo: repo}
+}
+
+// QueryByUserID finds the sales where the user is the buyer.
+func (c *Core) QueryByUserID(ctx context.Context, userID uuid.UUID) ([]Sale, error) {
+	sales, err := c.repo.QueryByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return sales, nil
+}
+
+// QueryBySellerID finds the sales of the products owned by the user.
+func (c *Core) QueryBySellerID(ctx context.Context, userID uuid.UUID) ([]Sale, error) {
+	sales, err := c.repo.QueryBySellerID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return sales, nil
+}
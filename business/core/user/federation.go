@@ -0,0 +1,134 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/audit"
+	"sort"
+	"time"
+)
+
+// ErrIdentityConflict is returned when the email of an external user belongs
+// to a local user and the identity provider hasn't verified it.
+var ErrIdentityConflict = errors.New("email belongs to a user not linked to the identity")
+
+// Provision returns the local user linked to the subject of an external
+// identity provider, creating it on the first login. A user with a verified
+// email that already exists is linked instead. The identity provider is the
+// source of the roles of the users created for it, they're updated whenever
+// they change there. The roles of a linked user are managed locally unless
+// the provider is configured to sync them.
+func (c *Core) Provision(ctx context.Context, eu ExternalUser) (User, error) {
+	ident, err := c.repo.QueryIdentity(ctx, eu.Issuer, eu.Subject)
+	switch {
+	case err == nil:
+		usr, err := c.repo.QueryByID(ctx, ident.UserID)
+		if err != nil {
+			return User{}, fmt.Errorf("query: %w", err)
+		}
+		if !ident.Provisioned && !eu.SyncLinked {
+			return usr, nil
+		}
+		return c.syncRoles(ctx, usr, eu.Roles)
+
+	case !errors.Is(err, ErrNotFound):
+		return User{}, fmt.Errorf("query: %w", err)
+	}
+
+	var usr User
+	var provisioned bool
+	tran := func(ctx context.Context, r Repository) error {
+		usr, err = r.QueryByEmail(ctx, eu.Email)
+		switch {
+		case err == nil:
+			if !eu.EmailVerified {
+				return ErrIdentityConflict
+			}
+
+		case errors.Is(err, ErrNotFound):
+			now := time.Now()
+			usr = User{
+				ID:           uuid.New(),
+				Name:         eu.Name,
+				Email:        eu.Email,
+				Roles:        eu.Roles,
+				PasswordHash: []byte{},
+				Enabled:      true,
+				DateCreated:  now,
+				DateUpdated:  now,
+			}
+			if err := r.Create(ctx, usr); err != nil {
+				return fmt.Errorf("create: %w", err)
+			}
+			if err := c.audit.Record(ctx, audit.ActionCreate, audit.EntityUser, usr.ID.String(), nil, usr); err != nil {
+				return fmt.Errorf("audit: %w", err)
+			}
+			provisioned = true
+
+		default:
+			return fmt.Errorf("query: %w", err)
+		}
+
+		ident := Identity{
+			Issuer:      eu.Issuer,
+			Subject:     eu.Subject,
+			UserID:      usr.ID,
+			Provisioned: provisioned,
+			DateCreated: time.Now(),
+		}
+		if err := r.CreateIdentity(ctx, ident); err != nil {
+			return fmt.Errorf("create identity: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := c.repo.WithinTran(ctx, tran); err != nil {
+		return User{}, err
+	}
+
+	if !provisioned && !eu.SyncLinked {
+		return usr, nil
+	}
+
+	return c.syncRoles(ctx, usr, eu.Roles)
+}
+
+// syncRoles updates the roles of the user when they differ from the ones
+// granted by the identity provider.
+func (c *Core) syncRoles(ctx context.Context, usr User, roles []string) (User, error) {
+	if sameRoles(usr.Roles, roles) {
+		return usr, nil
+	}
+
+	before := usr
+	usr.Roles = roles
+	usr.DateUpdated = time.Now()
+
+	if err := c.update(ctx, before, usr); err != nil {
+		return User{}, err
+	}
+
+	return usr, nil
+}
+
+// sameRoles reports whether both lists hold the same roles in any order.
+func sameRoles(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	x := append([]string(nil), a...)
+	y := append([]string(nil), b...)
+	sort.Strings(x)
+	sort.Strings(y)
+
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,245 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"github.com/halilylm/micro/business/core/audit"
+	"github.com/halilylm/micro/foundation/totp"
+	"golang.org/x/crypto/bcrypt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Set of error variables for multi-factor authentication.
+var (
+	ErrMFAEnabled     = errors.New("mfa is already enabled")
+	ErrMFANotEnrolled = errors.New("mfa is not enrolled")
+	ErrInvalidMFACode = errors.New("mfa code is not valid")
+	ErrMFAAttempts    = errors.New("too many mfa attempts")
+)
+
+// Settings for multi-factor authentication.
+const (
+	mfaIssuer         = "micro"
+	mfaSkew           = 1
+	recoveryCodeCount = 10
+	maxMFAAttempts    = 5
+)
+
+// recoveryCodeFormat matches a normalized recovery code, so other input
+// isn't compared against every hash.
+var recoveryCodeFormat = regexp.MustCompile(`^[A-Z2-7]{10}$`)
+
+// EnrollMFA generates a new TOTP secret for the user. The enrollment is
+// pending until it is confirmed with a valid code.
+func (c *Core) EnrollMFA(ctx context.Context, usr User) (User, MFAEnrollment, error) {
+	if usr.MFA.Enabled {
+		return User{}, MFAEnrollment{}, ErrMFAEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return User{}, MFAEnrollment{}, fmt.Errorf("generating secret: %w", err)
+	}
+
+	before := usr
+	usr.MFA = MFA{Secret: secret}
+	usr.DateUpdated = time.Now()
+
+	if err := c.update(ctx, before, usr); err != nil {
+		return User{}, MFAEnrollment{}, err
+	}
+
+	enrollment := MFAEnrollment{
+		Secret: secret,
+		URI:    totp.URI(mfaIssuer, usr.Email.Address, secret),
+	}
+
+	return usr, enrollment, nil
+}
+
+// ConfirmMFA enables MFA for the user after checking the code produced by
+// the authenticator app. It returns a set of recovery codes which are only
+// available here since just their hashes are stored.
+func (c *Core) ConfirmMFA(ctx context.Context, usr User, code string) (User, []string, error) {
+	if usr.MFA.Enabled {
+		return User{}, nil, ErrMFAEnabled
+	}
+
+	if usr.MFA.Secret == "" {
+		return User{}, nil, ErrMFANotEnrolled
+	}
+
+	step, ok := totp.ValidateStep(usr.MFA.Secret, code, time.Now(), mfaSkew)
+	if !ok {
+		return User{}, nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return User{}, nil, err
+	}
+
+	if err := c.useMFAStep(ctx, &usr, step); err != nil {
+		return User{}, nil, err
+	}
+
+	before := usr
+	usr.MFA.Enabled = true
+	usr.MFA.RecoveryCodeHashes = hashes
+	usr.DateUpdated = time.Now()
+
+	if err := c.update(ctx, before, usr); err != nil {
+		return User{}, nil, err
+	}
+
+	return usr, codes, nil
+}
+
+// VerifyMFAChallenge checks the code that completes the login of the
+// challenge. A challenge only allows a few attempts so codes can't be
+// guessed within its lifetime.
+func (c *Core) VerifyMFAChallenge(ctx context.Context, usr User, ch MFAChallenge, code string) (User, error) {
+	n, err := c.repo.AddMFAAttempt(ctx, ch)
+	if err != nil {
+		return User{}, fmt.Errorf("add attempt: %w", err)
+	}
+
+	if n > maxMFAAttempts {
+		return User{}, ErrMFAAttempts
+	}
+
+	return c.VerifyMFA(ctx, usr, code)
+}
+
+// VerifyMFA checks the code for a user with MFA enabled. The code can be
+// a TOTP code, which is accepted once, or one of the recovery codes, which
+// are removed once used.
+func (c *Core) VerifyMFA(ctx context.Context, usr User, code string) (User, error) {
+	if !usr.MFA.Enabled {
+		return User{}, ErrMFANotEnrolled
+	}
+
+	if step, ok := totp.ValidateStep(usr.MFA.Secret, code, time.Now(), mfaSkew); ok {
+		if err := c.useMFAStep(ctx, &usr, step); err != nil {
+			return User{}, err
+		}
+		return usr, nil
+	}
+
+	code = normalizeRecoveryCode(code)
+	if !recoveryCodeFormat.MatchString(code) {
+		return User{}, ErrInvalidMFACode
+	}
+
+	for i, hash := range usr.MFA.RecoveryCodeHashes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(code)) != nil {
+			continue
+		}
+
+		hashes := make([]string, 0, len(usr.MFA.RecoveryCodeHashes)-1)
+		hashes = append(hashes, usr.MFA.RecoveryCodeHashes[:i]...)
+		hashes = append(hashes, usr.MFA.RecoveryCodeHashes[i+1:]...)
+
+		before := usr
+		usr.MFA.RecoveryCodeHashes = hashes
+		usr.DateUpdated = time.Now()
+
+		// The code is removed on the condition it's still there, so a code
+		// used by a concurrent request is refused.
+		tran := func(ctx context.Context, r Repository) error {
+			if err := r.RemoveRecoveryCode(ctx, usr, hash); err != nil {
+				if errors.Is(err, ErrInvalidMFACode) {
+					return err
+				}
+				return fmt.Errorf("remove recovery code: %w", err)
+			}
+			if err := c.audit.Record(ctx, audit.ActionUpdate, audit.EntityUser, usr.ID.String(), before, usr); err != nil {
+				return fmt.Errorf("audit: %w", err)
+			}
+			return nil
+		}
+
+		if err := c.repo.WithinTran(ctx, tran); err != nil {
+			return User{}, err
+		}
+
+		return usr, nil
+	}
+
+	return User{}, ErrInvalidMFACode
+}
+
+// DisableMFA turns MFA off for the user once the code is verified.
+func (c *Core) DisableMFA(ctx context.Context, usr User, code string) (User, error) {
+	usr, err := c.VerifyMFA(ctx, usr, code)
+	if err != nil {
+		return User{}, err
+	}
+
+	before := usr
+	usr.MFA = MFA{}
+	usr.DateUpdated = time.Now()
+
+	if err := c.update(ctx, before, usr); err != nil {
+		return User{}, err
+	}
+
+	return usr, nil
+}
+
+// =============================================================================
+
+// useMFAStep records the time step of an accepted TOTP code. A code of the
+// same or an earlier step has been used already and is refused.
+func (c *Core) useMFAStep(ctx context.Context, usr *User, step int64) error {
+	if step <= usr.MFA.LastStep {
+		return ErrInvalidMFACode
+	}
+
+	if err := c.repo.UpdateMFAStep(ctx, *usr, step); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			return err
+		}
+		return fmt.Errorf("update step: %w", err)
+	}
+
+	usr.MFA.LastStep = step
+
+	return nil
+}
+
+// generateRecoveryCodes returns a set of recovery codes in the form
+// XXXXX-XXXXX along with their bcrypt hashes.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, 6)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("generating recovery code: %w", err)
+		}
+		raw := base32.StdEncoding.EncodeToString(b)[:10]
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(raw), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, nil, fmt.Errorf("generating recovery code hash: %w", err)
+		}
+
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = string(hash)
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode strips formatting so codes can be typed with or
+// without the dash and in any case.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(code, "-", ""))
+}
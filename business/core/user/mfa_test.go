@@ -0,0 +1,193 @@
+package user_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/audit"
+	"github.com/halilylm/micro/business/core/user"
+	"github.com/halilylm/micro/foundation/totp"
+	"net/mail"
+	"testing"
+	"time"
+)
+
+// TestVerifyMFARecoveryCode makes sure a recovery code is accepted once,
+// even when the second request still holds the user from before it was used.
+func TestVerifyMFARecoveryCode(t *testing.T) {
+	core, repo, usr, codes := newMFAUser(t)
+	ctx := context.Background()
+
+	if _, err := core.VerifyMFA(ctx, usr, codes[0]); err != nil {
+		t.Fatalf("first use of the recovery code: %s", err)
+	}
+
+	if _, err := core.VerifyMFA(ctx, usr, codes[0]); !errors.Is(err, user.ErrInvalidMFACode) {
+		t.Fatalf("second use of the recovery code: got %v, want %v", err, user.ErrInvalidMFACode)
+	}
+
+	if n := len(repo.usr.MFA.RecoveryCodeHashes); n != len(codes)-1 {
+		t.Fatalf("got %d recovery codes left, want %d", n, len(codes)-1)
+	}
+
+	if _, err := core.VerifyMFA(ctx, repo.usr, codes[1]); err != nil {
+		t.Fatalf("use of another recovery code: %s", err)
+	}
+}
+
+// TestVerifyMFATOTP checks the clock skew tolerated for TOTP codes and that a
+// code isn't accepted for a step that was used already. The cases run in
+// order against the same user, whose current step was used by ConfirmMFA.
+func TestVerifyMFATOTP(t *testing.T) {
+	waitForPeriodStart(t)
+
+	core, repo, _, _ := newMFAUser(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		offset time.Duration
+		err    error
+	}{
+		{"current step again", 0, user.ErrInvalidMFACode},
+		{"two steps ahead", 2 * totp.Period, user.ErrInvalidMFACode},
+		{"one step ahead", totp.Period, nil},
+		{"one step ahead again", totp.Period, user.ErrInvalidMFACode},
+		{"one step behind", -totp.Period, user.ErrInvalidMFACode},
+	}
+
+	for _, tt := range tests {
+		code, err := totp.Code(repo.usr.MFA.Secret, now.Add(tt.offset))
+		if err != nil {
+			t.Fatalf("%s: generating code: %s", tt.name, err)
+		}
+
+		_, err = core.VerifyMFA(ctx, repo.usr, code)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+// TestVerifyMFAChallenge makes sure a challenge is refused once it's been
+// attempted too often, even with a valid code.
+func TestVerifyMFAChallenge(t *testing.T) {
+	core, repo, usr, codes := newMFAUser(t)
+	ctx := context.Background()
+
+	ch := user.MFAChallenge{
+		ID:          uuid.NewString(),
+		UserID:      usr.ID,
+		DateExpires: time.Now().Add(5 * time.Minute),
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := core.VerifyMFAChallenge(ctx, usr, ch, "000000"); !errors.Is(err, user.ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: got %v, want %v", i+1, err, user.ErrInvalidMFACode)
+		}
+	}
+
+	if _, err := core.VerifyMFAChallenge(ctx, repo.usr, ch, codes[0]); !errors.Is(err, user.ErrMFAAttempts) {
+		t.Fatalf("attempt 6: got %v, want %v", err, user.ErrMFAAttempts)
+	}
+}
+
+// =============================================================================
+
+// newMFAUser constructs a core over an in-memory repository holding a user
+// with MFA enabled, and returns the user's recovery codes.
+func newMFAUser(t *testing.T) (*user.Core, *mfaRepo, user.User, []string) {
+	t.Helper()
+
+	usr := user.User{
+		ID:      uuid.New(),
+		Name:    "Test User",
+		Email:   mail.Address{Address: "test@example.com"},
+		Roles:   []string{user.RoleUser},
+		Enabled: true,
+	}
+
+	repo := &mfaRepo{usr: usr, attempts: make(map[string]int)}
+	core := user.NewCore(repo, audit.NewCore(auditRepo{}))
+	ctx := context.Background()
+
+	usr, enrollment, err := core.EnrollMFA(ctx, usr)
+	if err != nil {
+		t.Fatalf("enrolling: %s", err)
+	}
+
+	code, err := totp.Code(enrollment.Secret, time.Now())
+	if err != nil {
+		t.Fatalf("generating code: %s", err)
+	}
+
+	usr, codes, err := core.ConfirmMFA(ctx, usr, code)
+	if err != nil {
+		t.Fatalf("confirming: %s", err)
+	}
+
+	return core, repo, usr, codes
+}
+
+// waitForPeriodStart sleeps into the next TOTP period when the current one
+// is about to end, so the steps of a test don't shift halfway through.
+func waitForPeriodStart(t *testing.T) {
+	t.Helper()
+
+	left := totp.Period - time.Duration(time.Now().UnixNano())%totp.Period
+	if left < 3*time.Second {
+		time.Sleep(left)
+	}
+}
+
+// mfaRepo keeps a single user in memory. The conditional updates behave
+// like the ones of the database.
+type mfaRepo struct {
+	user.Repository
+	usr      user.User
+	attempts map[string]int
+}
+
+func (r *mfaRepo) WithinTran(ctx context.Context, fn func(ctx context.Context, r user.Repository) error) error {
+	return fn(ctx, r)
+}
+
+func (r *mfaRepo) Update(ctx context.Context, usr user.User) error {
+	r.usr = usr
+	return nil
+}
+
+func (r *mfaRepo) UpdateMFAStep(ctx context.Context, usr user.User, step int64) error {
+	if step <= r.usr.MFA.LastStep {
+		return fmt.Errorf("step[%d] already used: %w", step, user.ErrInvalidMFACode)
+	}
+	r.usr.MFA.LastStep = step
+	return nil
+}
+
+func (r *mfaRepo) RemoveRecoveryCode(ctx context.Context, usr user.User, hash string) error {
+	for i, h := range r.usr.MFA.RecoveryCodeHashes {
+		if h == hash {
+			hashes := append([]string{}, r.usr.MFA.RecoveryCodeHashes[:i]...)
+			r.usr.MFA.RecoveryCodeHashes = append(hashes, r.usr.MFA.RecoveryCodeHashes[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("recovery code already used: %w", user.ErrInvalidMFACode)
+}
+
+func (r *mfaRepo) AddMFAAttempt(ctx context.Context, ch user.MFAChallenge) (int, error) {
+	r.attempts[ch.ID]++
+	return r.attempts[ch.ID], nil
+}
+
+// auditRepo drops the entries it's given.
+type auditRepo struct {
+	audit.Repository
+}
+
+func (auditRepo) Create(ctx context.Context, entry audit.Entry) error {
+	return nil
+}
@@ -2,11 +2,13 @@ package user
 
 import (
 	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/sys/validate"
 	"net/mail"
 	"time"
 )
 
-// Set of possible roles for a user.
+// Set of built-in roles created by the schema. Additional roles and the
+// permissions they grant are managed through the role package.
 const (
 	RoleAdmin = "ADMIN"
 	RoleUser  = "USER"
@@ -20,10 +22,36 @@ type User struct {
 	Roles        []string     `json:"roles"`
 	PasswordHash []byte       `json:"-"`
 	Enabled      bool         `json:"enabled"`
+	MFA          MFA          `json:"mfa"`
 	DateCreated  time.Time    `json:"data_created"`
 	DateUpdated  time.Time    `json:"date_updated"`
 }
 
+// MFA represents the multi-factor authentication state of a user. A secret
+// without Enabled set is an enrollment that hasn't been confirmed yet.
+// LastStep is the TOTP time step of the last code accepted.
+type MFA struct {
+	Enabled            bool     `json:"enabled"`
+	Secret             string   `json:"-"`
+	RecoveryCodeHashes []string `json:"-"`
+	LastStep           int64    `json:"-"`
+}
+
+// MFAChallenge identifies the token issued for the password step of a
+// login, which the MFA step is attempted with.
+type MFAChallenge struct {
+	ID          string
+	UserID      uuid.UUID
+	DateExpires time.Time
+}
+
+// MFAEnrollment contains what a client needs to add the user to an
+// authenticator app.
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
 // NewUser contains information needed to create a new User.
 type NewUser struct {
 	Name            string       `json:"name" validate:"required"`
@@ -33,6 +61,11 @@ type NewUser struct {
 	PasswordConfirm string       `json:"password_confirm" validate:"eqfield=Password"`
 }
 
+// Validate checks the NewUser against its validate tags.
+func (nu NewUser) Validate() error {
+	return validate.Check(nu)
+}
+
 // UpdateUser defines what information may be provided to modify an existing
 // User. All fields are optional so clients can send just the fields they want
 // changed. It uses pointer fields, so we can differentiate between a field that
@@ -47,3 +80,32 @@ type UpdateUser struct {
 	PasswordConfirm *string       `json:"password_confirm" validate:"omitempty,eqfield=Password"`
 	Enabled         *bool         `json:"enabled"`
 }
+
+// Validate checks the UpdateUser against its validate tags.
+func (uu UpdateUser) Validate() error {
+	return validate.Check(uu)
+}
+
+// Identity links a user to the subject of an external identity provider.
+// Provisioned is set when the user was created for the identity rather than
+// an existing user being linked to it.
+type Identity struct {
+	Issuer      string    `json:"issuer"`
+	Subject     string    `json:"subject"`
+	UserID      uuid.UUID `json:"user_id"`
+	Provisioned bool      `json:"provisioned"`
+	DateCreated time.Time `json:"date_created"`
+}
+
+// ExternalUser contains what an external identity provider asserts about a
+// user, with the roles its groups map onto. SyncLinked tells whether the
+// roles also replace those of a local user that was linked to the identity.
+type ExternalUser struct {
+	Issuer        string
+	Subject       string
+	Name          string
+	Email         mail.Address
+	EmailVerified bool
+	Roles         []string
+	SyncLinked    bool
+}
@@ -1,6 +1,10 @@
 package user
 
-import "github.com/halilylm/micro/business/data/order"
+import (
+	"github.com/halilylm/micro/business/data/order"
+	"strconv"
+	"strings"
+)
 
 var ordering = order.New(orderByfields, OrderByID)
 
@@ -30,3 +34,26 @@ var orderByfields = map[string]bool{
 func NewOrderBy(field string, direction string) (order.By, error) {
 	return ordering.By(field, direction)
 }
+
+// SortKey returns the position of the user in an ordering by the field.
+func SortKey(usr User, field string) order.Key {
+	key := order.Key{
+		ID: usr.ID.String(),
+	}
+
+	switch field {
+	case OrderByID:
+		key.Value = usr.ID.String()
+	case OrderByName:
+		key.Value = usr.Name
+	case OrderByEmail:
+		key.Value = usr.Email.Address
+	case OrderByRoles:
+		// Roles are compared as the array literal of the column.
+		key.Value = "{" + strings.Join(usr.Roles, ",") + "}"
+	case OrderByEnabled:
+		key.Value = strconv.FormatBool(usr.Enabled)
+	}
+
+	return key
+}
@@ -2,38 +2,105 @@
 package usercache
 
 import (
+	"bytes"
 	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/gob"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/halilylm/micro/business/core/user"
 	"github.com/halilylm/micro/business/data/order"
+	"github.com/halilylm/micro/foundation/cache"
 	"go.uber.org/zap"
 	"net/mail"
-	"sync"
+	"strings"
+	"time"
 )
 
-type Repository struct {
-	log  *zap.SugaredLogger
-	repo user.Repository
+// defaultTTL is used when Config doesn't specify one.
+const defaultTTL = 5 * time.Minute
 
-	mu    sync.RWMutex
-	cache map[string]*user.User
+// Config represents the settings for the cache. A zero NegativeTTL disables
+// caching of users that were not found. Key is the AES key of 16, 24 or 32
+// bytes the users are encrypted with. Instances sharing a backend need the
+// same key; without one a random key is generated and the entries can only
+// be read by the instance that wrote them.
+type Config struct {
+	TTL         time.Duration
+	NegativeTTL time.Duration
+	Key         []byte
+}
+
+// Repository manages the set of APIs for user data and caching access. Users
+// are kept in the cache backend under their ID and their email. They are
+// encoded with gob, which unlike JSON keeps the password hash and MFA state,
+// and encrypted since the backend may be shared by every instance.
+type Repository struct {
+	log     *zap.SugaredLogger
+	repo    user.Repository
+	backend cache.Backend
+	aead    cipher.AEAD
+	cfg     Config
 }
 
 // NewRepository constructs the api for data and caching access.
-func NewRepository(log *zap.SugaredLogger, repo user.Repository) *Repository {
+func NewRepository(log *zap.SugaredLogger, repo user.Repository, backend cache.Backend, cfg Config) (*Repository, error) {
 	if log == nil {
 		log = zap.NewNop().Sugar()
 	}
-	return &Repository{
-		log:   log,
-		repo:  repo,
-		cache: map[string]*user.User{},
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultTTL
+	}
+
+	key := cfg.Key
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("generating key: %w", err)
+		}
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("constructing cipher: %w", err)
 	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("constructing gcm: %w", err)
+	}
+
+	r := Repository{
+		log:     log,
+		repo:    repo,
+		backend: backend,
+		aead:    aead,
+		cfg:     cfg,
+	}
+
+	return &r, nil
 }
 
-// WithinTran runs passed function and do commit/rollback at the end.
-func (r *Repository) WithinTran(ctx context.Context, fn func(r user.Repository) error) error {
-	return r.repo.WithinTran(ctx, fn)
+// WithinTran runs passed function and do commit/rollback at the end. The
+// users changed in the transaction are evicted once it's committed.
+func (r *Repository) WithinTran(ctx context.Context, fn func(ctx context.Context, r user.Repository) error) error {
+	var changed []user.User
+	f := func(ctx context.Context, repo user.Repository) error {
+		return fn(ctx, &tranRepository{Repository: repo, changed: &changed})
+	}
+
+	if err := r.repo.WithinTran(ctx, f); err != nil {
+		return err
+	}
+
+	for _, usr := range changed {
+		r.Invalidate(ctx, usr.ID, usr.Email.Address)
+	}
+
+	return nil
 }
 
 // Create insert a new user into the database.
@@ -42,7 +109,7 @@ func (r *Repository) Create(ctx context.Context, usr user.User) error {
 		return err
 	}
 
-	r.writeCache(usr)
+	r.writeCache(ctx, usr)
 
 	return nil
 }
@@ -53,82 +120,335 @@ func (r *Repository) Update(ctx context.Context, usr user.User) error {
 		return err
 	}
 
-	r.writeCache(usr)
+	// The email may have changed so the entry under the old one has to go.
+	r.Invalidate(ctx, usr.ID, usr.Email.Address)
+	r.writeCache(ctx, usr)
 
 	return nil
 }
 
+// Delete removes a user from the database.
 func (r *Repository) Delete(ctx context.Context, usr user.User) error {
 	if err := r.repo.Delete(ctx, usr); err != nil {
 		return err
 	}
 
-	r.deleteCache(usr)
+	r.Invalidate(ctx, usr.ID, usr.Email.Address)
 
 	return nil
 }
 
+// Query retrieves a list of existing users from the database. Lists aren't
+// cached.
 func (r *Repository) Query(ctx context.Context, filter user.QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) ([]user.User, error) {
 	return r.repo.Query(ctx, filter, orderBy, pageNumber, rowsPerPage)
 }
 
+// QueryAfter retrieves the users after the key from the database. Lists
+// aren't cached.
+func (r *Repository) QueryAfter(ctx context.Context, filter user.QueryFilter, orderBy order.By, after *order.Key, limit int) ([]user.User, error) {
+	return r.repo.QueryAfter(ctx, filter, orderBy, after, limit)
+}
+
+// QueryByID gets the specified user from the cache or the database.
 func (r *Repository) QueryByID(ctx context.Context, userID uuid.UUID) (user.User, error) {
-	cachedUsr, ok := r.readCache(userID.String())
-	if ok {
-		return cachedUsr, nil
+	return r.query(ctx, idKey(userID), func() (user.User, error) {
+		return r.repo.QueryByID(ctx, userID)
+	})
+}
+
+// QueryByEmail gets the specified user by email from the cache or the
+// database.
+func (r *Repository) QueryByEmail(ctx context.Context, email mail.Address) (user.User, error) {
+	return r.query(ctx, emailKey(email.Address), func() (user.User, error) {
+		return r.repo.QueryByEmail(ctx, email)
+	})
+}
+
+// QueryIdentity gets the link of a user to the subject of an external
+// identity provider from the cache or the database. The keys of the links
+// of a user are kept with the user so they're evicted along with it.
+func (r *Repository) QueryIdentity(ctx context.Context, issuer string, subject string) (user.Identity, error) {
+	key := identityKey(issuer, subject)
+
+	data, err := r.backend.Get(ctx, key)
+	switch {
+	case err == nil:
+		var ident user.Identity
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&ident); err == nil {
+			return ident, nil
+		}
+		r.log.Errorw("usercache", "status", "decode", "key", key, "ERROR", err)
+
+	case !errors.Is(err, cache.ErrNotFound):
+		r.log.Errorw("usercache", "status", "get", "key", key, "ERROR", err)
 	}
 
-	usr, err := r.repo.QueryByID(ctx, userID)
+	ident, err := r.repo.QueryIdentity(ctx, issuer, subject)
 	if err != nil {
-		return user.User{}, err
+		return user.Identity{}, err
 	}
 
-	r.writeCache(usr)
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(ident); err != nil {
+		r.log.Errorw("usercache", "status", "encode", "key", key, "ERROR", err)
+		return ident, nil
+	}
 
-	return usr, nil
+	r.addIdentityKey(ctx, ident.UserID, key)
+	r.setCache(ctx, key, buf.Bytes(), r.cfg.TTL)
+
+	return ident, nil
 }
 
-func (r *Repository) QueryByEmail(ctx context.Context, email mail.Address) (user.User, error) {
-	cachedUsr, ok := r.readCache(email.Address)
-	if ok {
-		return cachedUsr, nil
+// CreateIdentity links a user to the subject of an external identity
+// provider.
+func (r *Repository) CreateIdentity(ctx context.Context, ident user.Identity) error {
+	return r.repo.CreateIdentity(ctx, ident)
+}
+
+// DeleteIdentities removes the links of a user to external identity
+// providers.
+func (r *Repository) DeleteIdentities(ctx context.Context, userID uuid.UUID) error {
+	if err := r.repo.DeleteIdentities(ctx, userID); err != nil {
+		return err
 	}
 
-	usr, err := r.repo.QueryByEmail(ctx, email)
+	r.deleteCache(ctx, r.identityKeys(ctx, userID)...)
+
+	return nil
+}
+
+// UpdateMFAStep records the time step of the last TOTP code accepted for
+// the user and evicts the user.
+func (r *Repository) UpdateMFAStep(ctx context.Context, usr user.User, step int64) error {
+	if err := r.repo.UpdateMFAStep(ctx, usr, step); err != nil {
+		return err
+	}
+
+	r.Invalidate(ctx, usr.ID, usr.Email.Address)
+
+	return nil
+}
+
+// RemoveRecoveryCode removes the hash of a used recovery code from the
+// user and evicts the user.
+func (r *Repository) RemoveRecoveryCode(ctx context.Context, usr user.User, hash string) error {
+	if err := r.repo.RemoveRecoveryCode(ctx, usr, hash); err != nil {
+		return err
+	}
+
+	r.Invalidate(ctx, usr.ID, usr.Email.Address)
+
+	return nil
+}
+
+// AddMFAAttempt counts an attempt of the MFA step made with the challenge.
+// Attempts aren't cached.
+func (r *Repository) AddMFAAttempt(ctx context.Context, ch user.MFAChallenge) (int, error) {
+	return r.repo.AddMFAAttempt(ctx, ch)
+}
+
+// Invalidate evicts the entries for a user that was changed, possibly by
+// another instance. The email is evicted too since a cached not-found
+// result for it is no longer valid, and so are the links to external
+// identities since they may have been removed.
+func (r *Repository) Invalidate(ctx context.Context, userID uuid.UUID, email string) {
+	keys := append(r.identityKeys(ctx, userID), idKey(userID), emailKey(email))
+
+	if data, err := r.backend.Get(ctx, idKey(userID)); err == nil && len(data) > 0 {
+		if usr, err := r.decode(data); err == nil {
+			keys = append(keys, emailKey(usr.Email.Address))
+		}
+	}
+
+	r.deleteCache(ctx, keys...)
+}
+
+// Flush removes every entry from the cache.
+func (r *Repository) Flush(ctx context.Context) {
+	if err := r.backend.Flush(ctx); err != nil {
+		r.log.Errorw("usercache", "status", "flush", "ERROR", err)
+	}
+}
+
+// =============================================================================
+
+// query looks up the key in the cache and calls fn on a miss. Failures of
+// the cache are logged and fall back to the database.
+func (r *Repository) query(ctx context.Context, key string, fn func() (user.User, error)) (user.User, error) {
+	data, err := r.backend.Get(ctx, key)
+	switch {
+	case err == nil && len(data) == 0:
+		return user.User{}, user.ErrNotFound
+
+	case err == nil:
+		usr, err := r.decode(data)
+		if err == nil {
+			return usr, nil
+		}
+		r.log.Errorw("usercache", "status", "decode", "key", key, "ERROR", err)
+
+	case !errors.Is(err, cache.ErrNotFound):
+		r.log.Errorw("usercache", "status", "get", "key", key, "ERROR", err)
+	}
+
+	usr, err := fn()
 	if err != nil {
+		if errors.Is(err, user.ErrNotFound) && r.cfg.NegativeTTL > 0 {
+			r.setCache(ctx, key, []byte{}, r.cfg.NegativeTTL)
+		}
 		return user.User{}, err
 	}
 
-	r.writeCache(usr)
+	r.writeCache(ctx, usr)
 
 	return usr, nil
 }
 
-// readCache performs a safe search in the cache for specified key.
-func (r *Repository) readCache(key string) (user.User, bool) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+// writeCache stores the user under both of its keys.
+func (r *Repository) writeCache(ctx context.Context, usr user.User) {
+	data, err := r.encode(usr)
+	if err != nil {
+		r.log.Errorw("usercache", "status", "encode", "user_id", usr.ID, "ERROR", err)
+		return
+	}
 
-	usr, exists := r.cache[key]
-	if !exists {
-		return user.User{}, false
+	r.setCache(ctx, idKey(usr.ID), data, r.cfg.TTL)
+	r.setCache(ctx, emailKey(usr.Email.Address), data, r.cfg.TTL)
+}
+
+// identityKeys returns the keys of the links of the user to external
+// identities along with the key they're kept under.
+func (r *Repository) identityKeys(ctx context.Context, userID uuid.UUID) []string {
+	keys := []string{identitiesKey(userID)}
+
+	if data, err := r.backend.Get(ctx, identitiesKey(userID)); err == nil && len(data) > 0 {
+		keys = append(keys, strings.Split(string(data), "\n")...)
 	}
 
-	return *usr, true
+	return keys
 }
 
-// writeCache performs a safe write to the cache for specified user.
-func (r *Repository) writeCache(usr user.User) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.cache[usr.ID.String()] = &usr
-	r.cache[usr.Email.Address] = &usr
+// addIdentityKey adds the key of a link to an external identity to the
+// ones kept for the user.
+func (r *Repository) addIdentityKey(ctx context.Context, userID uuid.UUID, key string) {
+	keys := []string{key}
+
+	if data, err := r.backend.Get(ctx, identitiesKey(userID)); err == nil && len(data) > 0 {
+		for _, k := range strings.Split(string(data), "\n") {
+			if k != key {
+				keys = append(keys, k)
+			}
+		}
+	}
+
+	r.setCache(ctx, identitiesKey(userID), []byte(strings.Join(keys, "\n")), r.cfg.TTL)
 }
 
-// deleteCache performs a safe removal from the cache for the specified user.
-func (r *Repository) deleteCache(usr user.User) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	delete(r.cache, usr.ID.String())
-	delete(r.cache, usr.Email.Address)
+// setCache stores the value and logs a failure.
+func (r *Repository) setCache(ctx context.Context, key string, data []byte, ttl time.Duration) {
+	if err := r.backend.Set(ctx, key, data, ttl); err != nil {
+		r.log.Errorw("usercache", "status", "set", "key", key, "ERROR", err)
+	}
+}
+
+// deleteCache removes the keys and logs a failure.
+func (r *Repository) deleteCache(ctx context.Context, keys ...string) {
+	if err := r.backend.Delete(ctx, keys...); err != nil {
+		r.log.Errorw("usercache", "status", "delete", "keys", keys, "ERROR", err)
+	}
+}
+
+// tranRepository keeps track of the users changed within a transaction.
+type tranRepository struct {
+	user.Repository
+	changed *[]user.User
+}
+
+// Create inserts a new user into the database.
+func (t *tranRepository) Create(ctx context.Context, usr user.User) error {
+	if err := t.Repository.Create(ctx, usr); err != nil {
+		return err
+	}
+	*t.changed = append(*t.changed, usr)
+	return nil
+}
+
+// Update replaces a user document in the database.
+func (t *tranRepository) Update(ctx context.Context, usr user.User) error {
+	if err := t.Repository.Update(ctx, usr); err != nil {
+		return err
+	}
+	*t.changed = append(*t.changed, usr)
+	return nil
+}
+
+// RemoveRecoveryCode removes the hash of a used recovery code from the
+// user.
+func (t *tranRepository) RemoveRecoveryCode(ctx context.Context, usr user.User, hash string) error {
+	if err := t.Repository.RemoveRecoveryCode(ctx, usr, hash); err != nil {
+		return err
+	}
+	*t.changed = append(*t.changed, usr)
+	return nil
+}
+
+// Delete removes a user from the database.
+func (t *tranRepository) Delete(ctx context.Context, usr user.User) error {
+	if err := t.Repository.Delete(ctx, usr); err != nil {
+		return err
+	}
+	*t.changed = append(*t.changed, usr)
+	return nil
+}
+
+func idKey(userID uuid.UUID) string {
+	return "user:id:" + userID.String()
+}
+
+func emailKey(email string) string {
+	return "user:email:" + email
+}
+
+func identityKey(issuer string, subject string) string {
+	return fmt.Sprintf("user:identity:%q:%q", issuer, subject)
+}
+
+func identitiesKey(userID uuid.UUID) string {
+	return "user:identities:" + userID.String()
+}
+
+// encode encrypts the gob encoding of the user, prefixed with the nonce.
+func (r *Repository) encode(usr user.User) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(usr); err != nil {
+		return nil, fmt.Errorf("encode: %w", err)
+	}
+
+	nonce := make([]byte, r.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("nonce: %w", err)
+	}
+
+	return r.aead.Seal(nonce, nonce, buf.Bytes(), nil), nil
+}
+
+// decode decrypts and decodes a user stored by encode.
+func (r *Repository) decode(data []byte) (user.User, error) {
+	n := r.aead.NonceSize()
+	if len(data) < n {
+		return user.User{}, errors.New("decrypt: data too short")
+	}
+
+	plain, err := r.aead.Open(nil, data[:n], data[n:], nil)
+	if err != nil {
+		return user.User{}, fmt.Errorf("decrypt: %w", err)
+	}
+
+	var usr user.User
+	if err := gob.NewDecoder(bytes.NewReader(plain)).Decode(&usr); err != nil {
+		return user.User{}, fmt.Errorf("decode: %w", err)
+	}
+	return usr, nil
 }
@@ -0,0 +1,161 @@
+package usercache_test
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/user"
+	"github.com/halilylm/micro/business/core/user/repository/usercache"
+	"github.com/halilylm/micro/foundation/cache"
+	"net/mail"
+	"testing"
+	"time"
+)
+
+// TestEviction makes sure a full cache evicts the users that were used the
+// least recently. Each user takes an entry under its ID and its email.
+func TestEviction(t *testing.T) {
+	const name = "test_usercache_eviction"
+
+	repo := newUserRepo(3)
+	backend := cache.NewMemory(cache.MemoryConfig{Name: name, MaxEntries: 4})
+	r := newRepository(t, repo, backend, usercache.Config{TTL: time.Minute})
+	ctx := context.Background()
+
+	query := func(i int) {
+		t.Helper()
+		if _, err := r.QueryByID(ctx, repo.ids[i]); err != nil {
+			t.Fatalf("querying user %d: %s", i, err)
+		}
+	}
+
+	// The first user is used again after the second one is cached, so it's
+	// the second one that makes room for the third.
+	query(0)
+	query(1)
+	query(0)
+	query(2)
+
+	if n := backend.Len(); n != 4 {
+		t.Fatalf("got %d entries, want 4", n)
+	}
+
+	tests := []struct {
+		user  int
+		calls int
+	}{
+		{0, 1},
+		{2, 1},
+		{1, 2},
+	}
+
+	for _, tt := range tests {
+		query(tt.user)
+		if n := repo.calls[repo.ids[tt.user]]; n != tt.calls {
+			t.Errorf("user %d: got %d database queries, want %d", tt.user, n, tt.calls)
+		}
+	}
+
+	metrics := expvar.Get(name).(*expvar.Map)
+	for _, key := range []string{"hits", "misses", "evictions"} {
+		if metrics.Get(key) == nil {
+			t.Errorf("metric %s wasn't published", key)
+		}
+	}
+}
+
+// TestExpiration makes sure cached users, and cached misses for users that
+// weren't found, go to the database again once their ttl has passed.
+func TestExpiration(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     usercache.Config
+		missing bool
+		calls   int
+	}{
+		{"live", usercache.Config{TTL: time.Minute}, false, 1},
+		{"expired", usercache.Config{TTL: 10 * time.Millisecond}, false, 2},
+		{"live not found", usercache.Config{NegativeTTL: time.Minute}, true, 1},
+		{"expired not found", usercache.Config{NegativeTTL: 10 * time.Millisecond}, true, 2},
+		{"not found without negative ttl", usercache.Config{}, true, 2},
+	}
+
+	ctx := context.Background()
+
+	for _, tt := range tests {
+		repo := newUserRepo(1)
+		r := newRepository(t, repo, cache.NewMemory(cache.MemoryConfig{}), tt.cfg)
+
+		userID := repo.ids[0]
+		if tt.missing {
+			userID = uuid.New()
+		}
+
+		for i := 0; i < 2; i++ {
+			_, err := r.QueryByID(ctx, userID)
+			switch {
+			case tt.missing && !errors.Is(err, user.ErrNotFound):
+				t.Fatalf("%s: query %d: got %v, want %v", tt.name, i+1, err, user.ErrNotFound)
+			case !tt.missing && err != nil:
+				t.Fatalf("%s: query %d: %s", tt.name, i+1, err)
+			}
+
+			time.Sleep(20 * time.Millisecond)
+		}
+
+		if n := repo.calls[userID]; n != tt.calls {
+			t.Errorf("%s: got %d database queries, want %d", tt.name, n, tt.calls)
+		}
+	}
+}
+
+// =============================================================================
+
+func newRepository(t *testing.T, repo user.Repository, backend cache.Backend, cfg usercache.Config) *usercache.Repository {
+	t.Helper()
+
+	r, err := usercache.NewRepository(nil, repo, backend, cfg)
+	if err != nil {
+		t.Fatalf("constructing repository: %s", err)
+	}
+
+	return r
+}
+
+// userRepo keeps users in memory and counts the queries for each ID.
+type userRepo struct {
+	user.Repository
+	ids   []uuid.UUID
+	users map[uuid.UUID]user.User
+	calls map[uuid.UUID]int
+}
+
+func newUserRepo(n int) *userRepo {
+	r := userRepo{
+		users: make(map[uuid.UUID]user.User),
+		calls: make(map[uuid.UUID]int),
+	}
+
+	for i := 0; i < n; i++ {
+		usr := user.User{
+			ID:    uuid.New(),
+			Email: mail.Address{Address: uuid.NewString() + "@example.com"},
+			Roles: []string{user.RoleUser},
+		}
+		r.ids = append(r.ids, usr.ID)
+		r.users[usr.ID] = usr
+	}
+
+	return &r
+}
+
+func (r *userRepo) QueryByID(ctx context.Context, userID uuid.UUID) (user.User, error) {
+	r.calls[userID]++
+
+	usr, ok := r.users[userID]
+	if !ok {
+		return user.User{}, user.ErrNotFound
+	}
+	return usr, nil
+}
@@ -1,6 +1,7 @@
 package userdb
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/halilylm/micro/business/core/user"
@@ -10,6 +11,25 @@ import (
 	"time"
 )
 
+// NotifyChannel is the channel a Notification is sent on whenever a user
+// is created, updated or deleted.
+const NotifyChannel = "user_changes"
+
+// Notification identifies the user that changed.
+type Notification struct {
+	UserID uuid.UUID `json:"user_id"`
+	Email  string    `json:"email"`
+}
+
+// ParseNotification decodes the payload of a notification.
+func ParseNotification(payload string) (Notification, error) {
+	var n Notification
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		return Notification{}, fmt.Errorf("unmarshal notification: %w", err)
+	}
+	return n, nil
+}
+
 // dbUser represent the structure we need for moving data
 // between the app and the database.
 type dbUser struct {
@@ -19,6 +39,10 @@ type dbUser struct {
 	Roles        pq.StringArray `db:"roles"`
 	PasswordHash []byte         `db:"password_hash"`
 	Enabled      bool           `db:"enabled"`
+	MFAEnabled   bool           `db:"mfa_enabled"`
+	MFASecret    string         `db:"mfa_secret"`
+	MFARecovery  pq.StringArray `db:"mfa_recovery_hashes"`
+	MFALastStep  int64          `db:"mfa_last_step"`
 	DateCreated  time.Time      `db:"date_created"`
 	DateUpdated  time.Time      `db:"date_updated"`
 }
@@ -31,6 +55,10 @@ func toDBUser(usr user.User) dbUser {
 		Roles:        usr.Roles,
 		PasswordHash: usr.PasswordHash,
 		Enabled:      usr.Enabled,
+		MFAEnabled:   usr.MFA.Enabled,
+		MFASecret:    usr.MFA.Secret,
+		MFARecovery:  usr.MFA.RecoveryCodeHashes,
+		MFALastStep:  usr.MFA.LastStep,
 		DateCreated:  usr.DateCreated.UTC(),
 		DateUpdated:  usr.DateUpdated.UTC(),
 	}
@@ -48,8 +76,14 @@ func toCoreUser(dbUsr dbUser) user.User {
 		Roles:        dbUsr.Roles,
 		PasswordHash: dbUsr.PasswordHash,
 		Enabled:      dbUsr.Enabled,
-		DateCreated:  dbUsr.DateCreated.In(time.Local),
-		DateUpdated:  dbUsr.DateUpdated.In(time.Local),
+		MFA: user.MFA{
+			Enabled:            dbUsr.MFAEnabled,
+			Secret:             dbUsr.MFASecret,
+			RecoveryCodeHashes: dbUsr.MFARecovery,
+			LastStep:           dbUsr.MFALastStep,
+		},
+		DateCreated: dbUsr.DateCreated.In(time.Local),
+		DateUpdated: dbUsr.DateUpdated.In(time.Local),
 	}
 
 	return usr
@@ -63,6 +97,36 @@ func toCoreUserSlice(dbUsers []dbUser) []user.User {
 	return usrs
 }
 
+// dbIdentity represent the structure we need for moving identities
+// between the app and the database.
+type dbIdentity struct {
+	Issuer      string    `db:"issuer"`
+	Subject     string    `db:"subject"`
+	UserID      uuid.UUID `db:"user_id"`
+	Provisioned bool      `db:"provisioned"`
+	DateCreated time.Time `db:"date_created"`
+}
+
+func toDBIdentity(ident user.Identity) dbIdentity {
+	return dbIdentity{
+		Issuer:      ident.Issuer,
+		Subject:     ident.Subject,
+		UserID:      ident.UserID,
+		Provisioned: ident.Provisioned,
+		DateCreated: ident.DateCreated.UTC(),
+	}
+}
+
+func toCoreIdentity(dbIdent dbIdentity) user.Identity {
+	return user.Identity{
+		Issuer:      dbIdent.Issuer,
+		Subject:     dbIdent.Subject,
+		UserID:      dbIdent.UserID,
+		Provisioned: dbIdent.Provisioned,
+		DateCreated: dbIdent.DateCreated.In(time.Local),
+	}
+}
+
 // orderByFields is the map of fields that is used to translate between the
 // application layer names and the database.
 var orderByFields = map[string]string{
@@ -75,6 +139,16 @@ var orderByFields = map[string]string{
 
 // orderByClause validates the order by for correct fields and sql injection.
 func orderByClause(orderBy order.By) (string, error) {
+	by, err := orderByColumn(orderBy)
+	if err != nil {
+		return "", err
+	}
+
+	return by + " " + orderBy.Direction, nil
+}
+
+// orderByColumn validates the order by and returns the column it is for.
+func orderByColumn(orderBy order.By) (string, error) {
 	if err := order.Validate(orderBy.Field, orderBy.Direction); err != nil {
 		return "", err
 	}
@@ -84,5 +158,5 @@ func orderByClause(orderBy order.By) (string, error) {
 		return "", fmt.Errorf("field %q does not exist", orderBy.Field)
 	}
 
-	return by + " " + orderBy.Direction, nil
+	return by, nil
 }
@@ -4,6 +4,7 @@ package userdb
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
@@ -14,6 +15,7 @@ import (
 	"go.uber.org/zap"
 	"net/mail"
 	"strings"
+	"time"
 )
 
 // Repository manages the set of APIs for user database accesr.
@@ -32,18 +34,18 @@ func NewRepository(log *zap.SugaredLogger, db *sqlx.DB) *Repository {
 }
 
 // WithinTran runs passed function and do commit/rollback at the end.
-func (r *Repository) WithinTran(ctx context.Context, fn func(s user.Repository) error) error {
+func (r *Repository) WithinTran(ctx context.Context, fn func(ctx context.Context, s user.Repository) error) error {
 	if r.inTran {
-		return fn(r)
+		return fn(ctx, r)
 	}
 
-	f := func(tx *sqlx.Tx) error {
+	f := func(ctx context.Context, tx *sqlx.Tx) error {
 		s := &Repository{
 			log:    r.log,
 			db:     tx,
 			inTran: true,
 		}
-		return fn(s)
+		return fn(ctx, s)
 	}
 
 	return database.WithinTran(ctx, r.log, r.db.(*sqlx.DB), f)
@@ -53,9 +55,9 @@ func (r *Repository) WithinTran(ctx context.Context, fn func(s user.Repository)
 func (r *Repository) Create(ctx context.Context, usr user.User) error {
 	const q = `
 	INSERT INTO users
-		(user_id, name, email, password_hash, roles, enabled, date_created, date_updated)
+		(user_id, name, email, password_hash, roles, enabled, mfa_enabled, mfa_secret, mfa_recovery_hashes, date_created, date_updated)
 	VALUES
-		(:user_id, :name, :email, :password_hash, :roles, :enabled, :date_created, :date_updated)`
+		(:user_id, :name, :email, :password_hash, :roles, :enabled, :mfa_enabled, :mfa_secret, :mfa_recovery_hashes, :date_created, :date_updated)`
 
 	if err := database.NamedExecContext(ctx, r.log, r.db, q, toDBUser(usr)); err != nil {
 		if errors.Is(err, database.ErrDBDuplicatedEntry) {
@@ -64,7 +66,7 @@ func (r *Repository) Create(ctx context.Context, usr user.User) error {
 		return fmt.Errorf("inserting user: %w", err)
 	}
 
-	return nil
+	return r.notify(ctx, usr)
 }
 
 // Update replaces a user document in the database.
@@ -77,6 +79,10 @@ func (r *Repository) Update(ctx context.Context, usr user.User) error {
 		"email" = :email,
 		"roles" = :roles,
 		"password_hash" = :password_hash,
+		"enabled" = :enabled,
+		"mfa_enabled" = :mfa_enabled,
+		"mfa_secret" = :mfa_secret,
+		"mfa_recovery_hashes" = :mfa_recovery_hashes,
 		"date_updated" = :date_updated
 	WHERE
 		user_id = :user_id`
@@ -88,7 +94,7 @@ func (r *Repository) Update(ctx context.Context, usr user.User) error {
 		return fmt.Errorf("updating userID[%s]: %w", usr.ID, err)
 	}
 
-	return nil
+	return r.notify(ctx, usr)
 }
 
 // Delete removes a user from the database.
@@ -106,30 +112,89 @@ func (r *Repository) Delete(ctx context.Context, usr user.User) error {
 		user_id = :user_id`
 
 	if err := database.NamedExecContext(ctx, r.log, r.db, q, data); err != nil {
+		if errors.Is(err, database.ErrDBReferenced) {
+			return user.ErrInUse
+		}
 		return fmt.Errorf("deleting userID[%s]: %w", usr.ID, err)
 	}
 
-	return nil
+	return r.notify(ctx, usr)
 }
 
 // Query retrieves a list of existing users from the database.
 func (r *Repository) Query(ctx context.Context, filter user.QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) ([]user.User, error) {
-	data := struct {
-		ID          string `db:"id"`
-		Name        string `db:"name"`
-		Email       string `db:"email"`
-		Offset      int    `db:"offset"`
-		RowsPerPage int    `db:"rows_per_page"`
-	}{
-		Offset:      (pageNumber - 1) * rowsPerPage,
-		RowsPerPage: rowsPerPage,
+	orderByClause, err := orderByClause(orderBy)
+	if err != nil {
+		return nil, err
 	}
 
-	orderByClause, err := orderByClause(orderBy)
+	buf, data := filteredQuery(filter)
+	data.Offset = (pageNumber - 1) * rowsPerPage
+	data.RowsPerPage = rowsPerPage
+
+	buf.WriteString(" ORDER BY ")
+	buf.WriteString(orderByClause)
+	buf.WriteString(" OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY")
+
+	var usrs []dbUser
+	if err := database.NamedQuerySlice(ctx, r.log, r.db, buf.String(), data, &usrs); err != nil {
+		return nil, fmt.Errorf("selecting users: %w", err)
+	}
+
+	return toCoreUserSlice(usrs), nil
+}
+
+// QueryAfter retrieves up to limit users that come after the key in the
+// order. The user id breaks ties so no user is skipped or repeated between
+// pages.
+func (r *Repository) QueryAfter(ctx context.Context, filter user.QueryFilter, orderBy order.By, after *order.Key, limit int) ([]user.User, error) {
+	column, err := orderByColumn(orderBy)
 	if err != nil {
 		return nil, err
 	}
 
+	var conds []string
+	if after != nil {
+		op := ">"
+		if orderBy.Direction == order.DESC {
+			op = "<"
+		}
+		conds = append(conds, fmt.Sprintf("(%s, user_id) %s (:after_value, :after_id)", column, op))
+	}
+
+	buf, data := filteredQuery(filter, conds...)
+	data.RowsPerPage = limit
+	if after != nil {
+		data.AfterValue = after.Value
+		data.AfterID = after.ID
+	}
+
+	fmt.Fprintf(buf, " ORDER BY %[1]s %[2]s, user_id %[2]s", column, orderBy.Direction)
+	buf.WriteString(" FETCH FIRST :rows_per_page ROWS ONLY")
+
+	var usrs []dbUser
+	if err := database.NamedQuerySlice(ctx, r.log, r.db, buf.String(), data, &usrs); err != nil {
+		return nil, fmt.Errorf("selecting users: %w", err)
+	}
+
+	return toCoreUserSlice(usrs), nil
+}
+
+// filterData holds the values of the named parameters of a filtered query.
+type filterData struct {
+	ID          string `db:"id"`
+	Name        string `db:"name"`
+	Email       string `db:"email"`
+	Offset      int    `db:"offset"`
+	RowsPerPage int    `db:"rows_per_page"`
+	AfterValue  string `db:"after_value"`
+	AfterID     string `db:"after_id"`
+}
+
+// filteredQuery builds the query selecting the users that match the filter
+// and the extra conditions.
+func filteredQuery(filter user.QueryFilter, conds ...string) (*bytes.Buffer, filterData) {
+	var data filterData
 	var wc []string
 	if filter.ID != nil {
 		data.ID = (*filter.ID).String()
@@ -143,6 +208,7 @@ func (r *Repository) Query(ctx context.Context, filter user.QueryFilter, orderBy
 		data.Email = (*filter.Email).String()
 		wc = append(wc, "email = :email")
 	}
+	wc = append(wc, conds...)
 
 	const q = `
 	SELECT
@@ -156,16 +222,8 @@ func (r *Repository) Query(ctx context.Context, filter user.QueryFilter, orderBy
 		buf.WriteString("WHERE ")
 		buf.WriteString(strings.Join(wc, " AND "))
 	}
-	buf.WriteString(" ORDER BY ")
-	buf.WriteString(orderByClause)
-	buf.WriteString(" OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY")
-
-	var usrs []dbUser
-	if err := database.NamedQuerySlice(ctx, r.log, r.db, buf.String(), data, &usrs); err != nil {
-		return nil, fmt.Errorf("selecting users: %w", err)
-	}
 
-	return toCoreUserSlice(usrs), nil
+	return buf, data
 }
 
 // QueryByID gets the specified user from the database.
@@ -221,3 +279,224 @@ func (r *Repository) QueryByEmail(ctx context.Context, email mail.Address) (user
 
 	return toCoreUser(usr), nil
 }
+
+// QueryIdentity gets the link of a user to the subject of an external
+// identity provider.
+func (r *Repository) QueryIdentity(ctx context.Context, issuer string, subject string) (user.Identity, error) {
+	data := struct {
+		Issuer  string `db:"issuer"`
+		Subject string `db:"subject"`
+	}{
+		Issuer:  issuer,
+		Subject: subject,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		user_identities
+	WHERE
+		issuer = :issuer AND
+		subject = :subject`
+
+	var ident dbIdentity
+	if err := database.NamedQueryStruct(ctx, r.log, r.db, q, data, &ident); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return user.Identity{}, user.ErrNotFound
+		}
+		return user.Identity{}, fmt.Errorf("selecting issuer[%q] subject[%q]: %w", issuer, subject, err)
+	}
+
+	return toCoreIdentity(ident), nil
+}
+
+// CreateIdentity links a user to the subject of an external identity
+// provider.
+func (r *Repository) CreateIdentity(ctx context.Context, ident user.Identity) error {
+	const q = `
+	INSERT INTO user_identities
+		(issuer, subject, user_id, provisioned, date_created)
+	VALUES
+		(:issuer, :subject, :user_id, :provisioned, :date_created)`
+
+	if err := database.NamedExecContext(ctx, r.log, r.db, q, toDBIdentity(ident)); err != nil {
+		return fmt.Errorf("inserting identity: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteIdentities removes the links of a user to external identity
+// providers.
+func (r *Repository) DeleteIdentities(ctx context.Context, userID uuid.UUID) error {
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID.String(),
+	}
+
+	const q = `
+	DELETE FROM
+		user_identities
+	WHERE
+		user_id = :user_id`
+
+	if err := database.NamedExecContext(ctx, r.log, r.db, q, data); err != nil {
+		return fmt.Errorf("deleting identities userID[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// UpdateMFAStep records the time step of the last TOTP code accepted for
+// the user. A step that isn't later than the recorded one means the code
+// was used already, which the condition catches even for concurrent logins.
+func (r *Repository) UpdateMFAStep(ctx context.Context, usr user.User, step int64) error {
+	data := struct {
+		UserID string `db:"user_id"`
+		Step   int64  `db:"mfa_last_step"`
+	}{
+		UserID: usr.ID.String(),
+		Step:   step,
+	}
+
+	const q = `
+	UPDATE
+		users
+	SET
+		"mfa_last_step" = :mfa_last_step
+	WHERE
+		user_id = :user_id AND
+		mfa_last_step < :mfa_last_step
+	RETURNING
+		user_id`
+
+	var updated struct {
+		UserID string `db:"user_id"`
+	}
+	if err := database.NamedQueryStruct(ctx, r.log, r.db, q, data, &updated); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return fmt.Errorf("step[%d] already used: %w", step, user.ErrInvalidMFACode)
+		}
+		return fmt.Errorf("updating mfa step userID[%s]: %w", usr.ID, err)
+	}
+
+	return r.notify(ctx, usr)
+}
+
+// RemoveRecoveryCode removes the hash of a used recovery code from the
+// user. A hash that is gone already means the code was used by another
+// request, which the condition catches.
+func (r *Repository) RemoveRecoveryCode(ctx context.Context, usr user.User, hash string) error {
+	data := struct {
+		UserID      string    `db:"user_id"`
+		Hash        string    `db:"hash"`
+		DateUpdated time.Time `db:"date_updated"`
+	}{
+		UserID:      usr.ID.String(),
+		Hash:        hash,
+		DateUpdated: usr.DateUpdated.UTC(),
+	}
+
+	const q = `
+	UPDATE
+		users
+	SET
+		"mfa_recovery_hashes" = array_remove(mfa_recovery_hashes, :hash),
+		"date_updated" = :date_updated
+	WHERE
+		user_id = :user_id AND
+		:hash = ANY(mfa_recovery_hashes)
+	RETURNING
+		user_id`
+
+	var updated struct {
+		UserID string `db:"user_id"`
+	}
+	if err := database.NamedQueryStruct(ctx, r.log, r.db, q, data, &updated); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return fmt.Errorf("recovery code already used: %w", user.ErrInvalidMFACode)
+		}
+		return fmt.Errorf("removing recovery code userID[%s]: %w", usr.ID, err)
+	}
+
+	return r.notify(ctx, usr)
+}
+
+// AddMFAAttempt counts an attempt of the MFA step made with the challenge
+// and returns the number of attempts so far. The challenges of the user
+// that expired are removed along the way.
+func (r *Repository) AddMFAAttempt(ctx context.Context, ch user.MFAChallenge) (int, error) {
+	data := struct {
+		ChallengeID string    `db:"challenge_id"`
+		UserID      string    `db:"user_id"`
+		DateExpires time.Time `db:"date_expires"`
+		Now         time.Time `db:"now"`
+	}{
+		ChallengeID: ch.ID,
+		UserID:      ch.UserID.String(),
+		DateExpires: ch.DateExpires.UTC(),
+		Now:         time.Now().UTC(),
+	}
+
+	const del = `
+	DELETE FROM
+		mfa_challenges
+	WHERE
+		user_id = :user_id AND
+		date_expires < :now`
+
+	if err := database.NamedExecContext(ctx, r.log, r.db, del, data); err != nil {
+		return 0, fmt.Errorf("deleting expired challenges userID[%s]: %w", ch.UserID, err)
+	}
+
+	const q = `
+	INSERT INTO mfa_challenges
+		(challenge_id, user_id, attempts, date_expires)
+	VALUES
+		(:challenge_id, :user_id, 1, :date_expires)
+	ON CONFLICT (challenge_id) DO UPDATE SET
+		attempts = mfa_challenges.attempts + 1
+	RETURNING
+		attempts`
+
+	var counted struct {
+		Attempts int `db:"attempts"`
+	}
+	if err := database.NamedQueryStruct(ctx, r.log, r.db, q, data, &counted); err != nil {
+		return 0, fmt.Errorf("counting attempt challengeID[%s]: %w", ch.ID, err)
+	}
+
+	return counted.Attempts, nil
+}
+
+// =============================================================================
+
+// notify tells every instance that the user changed so cached copies are
+// evicted. Within a transaction the notification is delivered on commit.
+func (r *Repository) notify(ctx context.Context, usr user.User) error {
+	payload, err := json.Marshal(Notification{
+		UserID: usr.ID,
+		Email:  usr.Email.Address,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal notification: %w", err)
+	}
+
+	data := struct {
+		Channel string `db:"channel"`
+		Payload string `db:"payload"`
+	}{
+		Channel: NotifyChannel,
+		Payload: string(payload),
+	}
+
+	const q = `SELECT pg_notify(:channel, :payload)`
+
+	if err := database.NamedExecContext(ctx, r.log, r.db, q, data); err != nil {
+		return fmt.Errorf("notifying userID[%s]: %w", usr.ID, err)
+	}
+
+	return nil
+}
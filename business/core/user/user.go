@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/audit"
 	"github.com/halilylm/micro/business/data/order"
 	"github.com/halilylm/micro/business/sys/validate"
 	"golang.org/x/crypto/bcrypt"
@@ -19,28 +20,40 @@ var (
 	ErrUniqueEmail           = errors.New("email is not unique")
 	ErrInvalidOrder          = errors.New("validating order by")
 	ErrAuthenticationFailure = errors.New("authentication failed")
+	ErrInUse                 = errors.New("user is referenced by sales and can't be deleted")
 )
 
 // Repository interface declares the behaviour this package needs to
 // persists and retrieve data.
 type Repository interface {
-	WithinTran(ctx context.Context, fn func(r Repository) error) error
+	WithinTran(ctx context.Context, fn func(ctx context.Context, r Repository) error) error
 	Create(ctx context.Context, usr User) error
 	Update(ctx context.Context, usr User) error
 	Delete(ctx context.Context, usr User) error
 	Query(ctx context.Context, filter QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) ([]User, error)
+	QueryAfter(ctx context.Context, filter QueryFilter, orderBy order.By, after *order.Key, limit int) ([]User, error)
 	QueryByID(ctx context.Context, userID uuid.UUID) (User, error)
 	QueryByEmail(ctx context.Context, email mail.Address) (User, error)
+	QueryIdentity(ctx context.Context, issuer string, subject string) (Identity, error)
+	CreateIdentity(ctx context.Context, ident Identity) error
+	DeleteIdentities(ctx context.Context, userID uuid.UUID) error
+	UpdateMFAStep(ctx context.Context, usr User, step int64) error
+	RemoveRecoveryCode(ctx context.Context, usr User, hash string) error
+	AddMFAAttempt(ctx context.Context, ch MFAChallenge) (int, error)
 }
 
 // Core manages the set of APIs for user access.
 type Core struct {
-	repo Repository
+	repo  Repository
+	audit *audit.Core
 }
 
 // NewCore constructs a core for user api access.
-func NewCore(repo Repository) *Core {
-	return &Core{repo: repo}
+func NewCore(repo Repository, audCore *audit.Core) *Core {
+	return &Core{
+		repo:  repo,
+		audit: audCore,
+	}
 }
 
 // Create inserts a new user into the database.
@@ -66,17 +79,31 @@ func (c *Core) Create(ctx context.Context, nu NewUser) (User, error) {
 		DateCreated:  now,
 		DateUpdated:  now,
 	}
-	if err := c.repo.Create(ctx, user); err != nil {
-		return User{}, fmt.Errorf("create: %w", err)
+	tran := func(ctx context.Context, r Repository) error {
+		if err := r.Create(ctx, user); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+		if err := c.audit.Record(ctx, audit.ActionCreate, audit.EntityUser, user.ID.String(), nil, user); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+		return nil
+	}
+
+	if err := c.repo.WithinTran(ctx, tran); err != nil {
+		return User{}, err
 	}
+
 	return user, nil
 }
 
+// Update modifies data about a user.
 func (c *Core) Update(ctx context.Context, usr User, uu UpdateUser) (User, error) {
 	if err := validate.Check(uu); err != nil {
 		return User{}, fmt.Errorf("validating data: %w", err)
 	}
 
+	before := usr
+
 	if uu.Name != nil {
 		usr.Name = *uu.Name
 	}
@@ -98,8 +125,8 @@ func (c *Core) Update(ctx context.Context, usr User, uu UpdateUser) (User, error
 	}
 	usr.DateUpdated = time.Now()
 
-	if err := c.repo.Update(ctx, usr); err != nil {
-		return User{}, fmt.Errorf("update: %w", err)
+	if err := c.update(ctx, before, usr); err != nil {
+		return User{}, err
 	}
 
 	return usr, nil
@@ -107,10 +134,54 @@ func (c *Core) Update(ctx context.Context, usr User, uu UpdateUser) (User, error
 
 // Delete removes a user from the database
 func (c *Core) Delete(ctx context.Context, usr User) error {
-	if err := c.repo.Delete(ctx, usr); err != nil {
-		return fmt.Errorf("delete: %w", err)
+	tran := func(ctx context.Context, r Repository) error {
+		if err := r.Delete(ctx, usr); err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+		if err := c.audit.Record(ctx, audit.ActionDelete, audit.EntityUser, usr.ID.String(), usr, nil); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+		return nil
 	}
-	return nil
+
+	return c.repo.WithinTran(ctx, tran)
+}
+
+// Anonymize removes the personal data of a user while keeping the row, so
+// the sales that reference it stay intact. The user can't authenticate
+// afterwards. The values recorded in the audit log for the user are redacted
+// as well.
+func (c *Core) Anonymize(ctx context.Context, usr User) (User, error) {
+	usr.Name = "Deleted User"
+	usr.Email = mail.Address{Address: usr.ID.String() + "@erased.invalid"}
+	usr.Roles = []string{}
+	usr.PasswordHash = []byte{}
+	usr.Enabled = false
+	usr.MFA = MFA{}
+	usr.DateUpdated = time.Now()
+
+	// The links to external identities would let the user sign in again.
+	tran := func(ctx context.Context, r Repository) error {
+		if err := r.Update(ctx, usr); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+		if err := r.DeleteIdentities(ctx, usr.ID); err != nil {
+			return fmt.Errorf("delete identities: %w", err)
+		}
+		if err := c.audit.Redact(ctx, audit.EntityUser, usr.ID.String()); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+		if err := c.audit.Record(ctx, audit.ActionAnonymize, audit.EntityUser, usr.ID.String(), nil, nil); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+		return nil
+	}
+
+	if err := c.repo.WithinTran(ctx, tran); err != nil {
+		return User{}, err
+	}
+
+	return usr, nil
 }
 
 // Query retrieves a list of existing users from the database.
@@ -131,6 +202,25 @@ func (c *Core) Query(ctx context.Context, filter QueryFilter, orderBy order.By,
 	return users, nil
 }
 
+// QueryAfter retrieves up to limit users that come after the key in the
+// order, or the first ones when there is no key.
+func (c *Core) QueryAfter(ctx context.Context, filter QueryFilter, orderBy order.By, after *order.Key, limit int) ([]User, error) {
+	if err := validate.Check(filter); err != nil {
+		return nil, fmt.Errorf("validating filter: %w", err)
+	}
+
+	if err := ordering.Check(orderBy); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidOrder, err.Error())
+	}
+
+	users, err := c.repo.QueryAfter(ctx, filter, orderBy, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return users, nil
+}
+
 // QueryByID gets the specified user from the database.
 func (c *Core) QueryByID(ctx context.Context, userID uuid.UUID) (User, error) {
 	user, err := c.repo.QueryByID(ctx, userID)
@@ -166,3 +256,21 @@ func (c *Core) Authenticate(ctx context.Context, email mail.Address, password st
 
 	return usr, nil
 }
+
+// =============================================================================
+
+// update stores the modified user and records the change in the same
+// transaction.
+func (c *Core) update(ctx context.Context, before User, after User) error {
+	tran := func(ctx context.Context, r Repository) error {
+		if err := r.Update(ctx, after); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+		if err := c.audit.Record(ctx, audit.ActionUpdate, audit.EntityUser, after.ID.String(), before, after); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+		return nil
+	}
+
+	return c.repo.WithinTran(ctx, tran)
+}
@@ -1,3 +1,9 @@
+DELETE FROM audit_log;
+DELETE FROM revoked_tokens;
+DELETE FROM privacy_jobs;
+DELETE FROM api_keys;
+DELETE FROM mfa_challenges;
+DELETE FROM user_identities;
 DELETE FROM sales;
 DELETE FROM products;
 DELETE FROM users;
@@ -1,3 +1,5 @@
+-- Version: 1.01
+-- Description: Create table users
 CREATE TABLE users (
     user_id UUID,
     name TEXT,
@@ -11,6 +13,8 @@ CREATE TABLE users (
     PRIMARY KEY (user_id)
 );
 
+-- Version: 1.02
+-- Description: Create table products
 CREATE TABLE products (
     product_id UUID,
     name TEXT,
@@ -24,6 +28,8 @@ CREATE TABLE products (
     FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
 );
 
+-- Version: 1.03
+-- Description: Create table sales
 CREATE TABLE sales (
     sale_id UUID,
     user_id UUID,
@@ -35,4 +41,178 @@ CREATE TABLE sales (
     PRIMARY KEY (sale_id),
     FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
     FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
-);
\ No newline at end of file
+);
+
+-- Version: 1.04
+-- Description: Create table api_keys
+CREATE TABLE api_keys (
+    api_key_id UUID,
+    user_id UUID,
+    name TEXT,
+    prefix TEXT,
+    key_hash TEXT UNIQUE,
+    roles TEXT[],
+    date_expires TIMESTAMP NULL,
+    date_created TIMESTAMP,
+
+    PRIMARY KEY (api_key_id),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Version: 1.05
+-- Description: Add multi-factor authentication to users
+ALTER TABLE users
+    ADD COLUMN mfa_enabled BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN mfa_secret TEXT NOT NULL DEFAULT '',
+    ADD COLUMN mfa_recovery_hashes TEXT[] NOT NULL DEFAULT '{}';
+
+-- Version: 1.06
+-- Description: Create tables for roles and permissions
+CREATE TABLE permissions (
+    name TEXT,
+    description TEXT,
+    date_created TIMESTAMP,
+
+    PRIMARY KEY (name)
+);
+
+CREATE TABLE roles (
+    name TEXT,
+    description TEXT,
+    date_created TIMESTAMP,
+    date_updated TIMESTAMP,
+
+    PRIMARY KEY (name)
+);
+
+CREATE TABLE role_permissions (
+    role_name TEXT,
+    permission_name TEXT,
+
+    PRIMARY KEY (role_name, permission_name),
+    FOREIGN KEY (role_name) REFERENCES roles(name) ON DELETE CASCADE,
+    FOREIGN KEY (permission_name) REFERENCES permissions(name) ON DELETE CASCADE
+);
+
+INSERT INTO permissions (name, description, date_created) VALUES
+('admin', 'Full access to administrative routes', NOW()),
+('user', 'Access to routes for regular users', NOW());
+
+INSERT INTO roles (name, description, date_created, date_updated) VALUES
+('ADMIN', 'Administrator', NOW(), NOW()),
+('USER', 'Regular user', NOW(), NOW());
+
+INSERT INTO role_permissions (role_name, permission_name) VALUES
+('ADMIN', 'admin'),
+('ADMIN', 'user'),
+('USER', 'user');
+
+-- Version: 1.07
+-- Description: Protect the sales ledger and create table privacy_jobs
+ALTER TABLE sales
+    DROP CONSTRAINT sales_user_id_fkey,
+    DROP CONSTRAINT sales_product_id_fkey,
+    ADD CONSTRAINT sales_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE RESTRICT,
+    ADD CONSTRAINT sales_product_id_fkey FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE RESTRICT;
+
+CREATE TABLE privacy_jobs (
+    job_id UUID,
+    user_id UUID,
+    kind TEXT,
+    status TEXT,
+    error TEXT,
+    archive JSONB NULL,
+    date_created TIMESTAMP,
+    date_updated TIMESTAMP,
+
+    PRIMARY KEY (job_id),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Version: 1.08
+-- Description: Create table audit_log
+CREATE TABLE audit_log (
+    audit_id UUID,
+    actor TEXT,
+    action TEXT,
+    entity TEXT,
+    entity_id TEXT,
+    diff JSONB NULL,
+    trace_id TEXT,
+    date_created TIMESTAMP,
+
+    PRIMARY KEY (audit_id)
+);
+
+CREATE INDEX audit_log_entity_idx ON audit_log (entity, entity_id);
+CREATE INDEX audit_log_actor_idx ON audit_log (actor);
+CREATE INDEX audit_log_date_created_idx ON audit_log (date_created);
+
+-- Version: 1.09
+-- Description: Create table user_identities
+CREATE TABLE user_identities (
+    issuer TEXT,
+    subject TEXT,
+    user_id UUID,
+    date_created TIMESTAMP,
+
+    PRIMARY KEY (issuer, subject),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Version: 1.10
+-- Description: Add scopes to api_keys
+ALTER TABLE api_keys
+    ADD COLUMN scopes TEXT[] NOT NULL DEFAULT '{}';
+
+-- Version: 1.11
+-- Description: Create table revoked_tokens and the introspect permission
+CREATE TABLE revoked_tokens (
+    token_id TEXT,
+    subject TEXT,
+    date_expires TIMESTAMP NULL,
+    date_revoked TIMESTAMP,
+
+    PRIMARY KEY (token_id)
+);
+
+INSERT INTO permissions (name, description, date_created) VALUES
+('introspect', 'Introspect tokens presented by other clients', NOW());
+
+INSERT INTO role_permissions (role_name, permission_name) VALUES
+('ADMIN', 'introspect');
+
+-- Version: 1.12
+-- Description: Record impersonation in the audit log and add the impersonate permission
+ALTER TABLE audit_log ADD COLUMN on_behalf_of TEXT NULL;
+
+CREATE INDEX audit_log_on_behalf_of_idx ON audit_log (on_behalf_of);
+
+INSERT INTO permissions (name, description, date_created) VALUES
+('impersonate', 'Act as another user to see the api as they do', NOW());
+
+INSERT INTO role_permissions (role_name, permission_name) VALUES
+('ADMIN', 'impersonate');
+
+-- Version: 1.13
+-- Description: Refuse reused TOTP codes and count the attempts of MFA challenges
+ALTER TABLE users
+    ADD COLUMN mfa_last_step BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE mfa_challenges (
+    challenge_id TEXT,
+    user_id UUID,
+    attempts INT,
+    date_expires TIMESTAMP,
+
+    PRIMARY KEY (challenge_id),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Version: 1.14
+-- Description: Tell the users created for an external identity from the ones linked to it
+ALTER TABLE user_identities
+    ADD COLUMN provisioned BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE user_identities SET provisioned = true
+WHERE user_id IN (SELECT user_id FROM users WHERE length(password_hash) = 0);
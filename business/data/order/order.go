@@ -103,4 +103,13 @@ func (o *Order) By(field string, direction string) (By, error) {
 	}
 
 	return By{Field: field, Direction: direction}, nil
-}
\ No newline at end of file
+}
+
+// Key is the position of an item in an ordering, the value of the field the
+// items are ordered by and the id of the item, which breaks ties. The items
+// after a key are read with keyset paging, which unlike an offset isn't
+// thrown off by items added or removed before it.
+type Key struct {
+	Value string
+	ID    string
+}
@@ -17,14 +17,16 @@ import (
 
 // lib/pg errorCodeNames
 const (
-	uniqueViolation = "23505"
-	undefinedTable  = "42P01"
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+	undefinedTable      = "42P01"
 )
 
 // Set of error variables for CRUD operations
 var (
 	ErrDBNotFound        = sql.ErrNoRows
 	ErrDBDuplicatedEntry = errors.New("duplicated entry")
+	ErrDBReferenced      = errors.New("referenced by other rows")
 	ErrUndefinedTable    = errors.New("undefined table")
 )
 
@@ -42,24 +44,7 @@ type Config struct {
 
 // Open knows how to open a database connection based on the configuration
 func Open(cfg Config) (*sqlx.DB, error) {
-	sslMode := "require"
-	if cfg.DisableTLS {
-		sslMode = "disable"
-	}
-	q := make(url.Values)
-	q.Set("sslmode", sslMode)
-	q.Set("timezone", "utc")
-	if cfg.Schema != "" {
-		q.Set("search_path", cfg.Schema)
-	}
-	u := url.URL{
-		Scheme:   "postgres",
-		User:     url.UserPassword(cfg.User, cfg.Password),
-		Host:     cfg.Host,
-		Path:     cfg.Name,
-		RawQuery: q.Encode(),
-	}
-	db, err := sqlx.Open("postgres", u.String())
+	db, err := sqlx.Open("postgres", connString(cfg))
 	if err != nil {
 		return nil, err
 	}
@@ -95,8 +80,18 @@ func StatusCheck(ctx context.Context, db *sqlx.DB) error {
 	return db.QueryRowContext(ctx, q).Scan(&tmp)
 }
 
-// WithinTran runs passed function and do commit/rollback at the end
-func WithinTran(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+// txKey is how the transaction of a context is stored.
+type txKey struct{}
+
+// WithinTran runs passed function and do commit/rollback at the end. The
+// context passed to the function carries the transaction so repositories
+// using Conn take part in it, and a WithinTran inside it joins it instead of
+// starting another.
+func WithinTran(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, fn func(ctx context.Context, tx *sqlx.Tx) error) error {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return fn(ctx, tx)
+	}
+
 	traceID := web.GetTraceID(ctx)
 
 	log.Infow("begin tran")
@@ -117,7 +112,7 @@ func WithinTran(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, fn fun
 		log.Infow("rollback tran", "trace_id", traceID)
 	}()
 
-	if err := fn(tx); err != nil {
+	if err := fn(context.WithValue(ctx, txKey{}, tx), tx); err != nil {
 		if pqerr, ok := err.(*pq.Error); ok && pqerr.Code == uniqueViolation {
 			return ErrDBDuplicatedEntry
 		}
@@ -131,6 +126,15 @@ func WithinTran(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, fn fun
 	return nil
 }
 
+// Conn returns the transaction carried by the context, or the database when
+// there is none.
+func Conn(ctx context.Context, db sqlx.ExtContext) sqlx.ExtContext {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return tx
+	}
+	return db
+}
+
 // ExecContext is a helper function to execute a CUD operation with logging and tracing
 func ExecContext(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string) error {
 	return NamedExecContext(ctx, log, db, query, struct{}{})
@@ -157,6 +161,8 @@ func NamedExecContext(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtCo
 				return ErrUndefinedTable
 			case uniqueViolation:
 				return ErrDBDuplicatedEntry
+			case foreignKeyViolation:
+				return ErrDBReferenced
 			}
 		}
 		return err
@@ -239,6 +245,43 @@ func namedQuerySlice[T any](ctx context.Context, log *zap.SugaredLogger, db sqlx
 	return nil
 }
 
+// NamedQueryStream is a helper function for executing queries that return a
+// collection of data too large to be held in memory. Each row is scanned
+// into a struct type and passed to fn as it is read from the cursor. An
+// error returned by fn stops the query and is returned.
+func NamedQueryStream[T any](ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data any, fn func(T) error) error {
+	q := queryString(query, data)
+
+	log.WithOptions(zap.AddCallerSkip(1)).Infow("database.NamedQueryStream", "trace_id", web.GetTraceID(ctx), "query", q)
+
+	ctx, span := web.AddSpan(ctx, "business.sys.database.querystream", attribute.String("query", q))
+	defer span.End()
+
+	rows, err := sqlx.NamedQueryContext(ctx, db, query, data)
+	if err != nil {
+		if pqerr, ok := err.(*pq.Error); ok && pqerr.Code == undefinedTable {
+			return ErrUndefinedTable
+		}
+		return err
+	}
+	defer rows.Close()
+
+	var count int
+	for rows.Next() {
+		v := new(T)
+		if err := rows.StructScan(v); err != nil {
+			return err
+		}
+		if err := fn(*v); err != nil {
+			return err
+		}
+		count++
+	}
+	span.SetAttributes(attribute.Int("rows", count))
+
+	return rows.Err()
+}
+
 // QueryStruct is a helper function for executing queries that return a
 // single value to be unmarshalled into a struct type where field replacement is necessary.
 func QueryStruct(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, dest any) error {
@@ -337,3 +380,25 @@ func queryString(query string, args any) string {
 
 	return strings.Trim(query, " ")
 }
+
+// connString builds the connection string for the configuration.
+func connString(cfg Config) string {
+	sslMode := "require"
+	if cfg.DisableTLS {
+		sslMode = "disable"
+	}
+	q := make(url.Values)
+	q.Set("sslmode", sslMode)
+	q.Set("timezone", "utc")
+	if cfg.Schema != "" {
+		q.Set("search_path", cfg.Schema)
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     cfg.Host,
+		Path:     cfg.Name,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
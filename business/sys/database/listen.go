@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Settings for the connection used to listen for notifications.
+const (
+	listenMinReconnect = 10 * time.Second
+	listenMaxReconnect = time.Minute
+	listenPingInterval = 90 * time.Second
+)
+
+// Listen subscribes to the channel and calls notify with the payload of every
+// notification until the context is canceled. The connection is reestablished
+// when it's lost. Since notifications sent in the meantime are gone, resync is
+// called after every reconnect so the caller can recover from the gap.
+func Listen(ctx context.Context, log *zap.SugaredLogger, cfg Config, channel string, notify func(payload string), resync func()) error {
+	event := func(ev pq.ListenerEventType, err error) {
+		switch ev {
+		case pq.ListenerEventConnected:
+			log.Infow("database.Listen", "status", "connected", "channel", channel)
+		case pq.ListenerEventDisconnected:
+			log.Errorw("database.Listen", "status", "disconnected", "channel", channel, "ERROR", err)
+		case pq.ListenerEventReconnected:
+			log.Infow("database.Listen", "status", "reconnected", "channel", channel)
+		case pq.ListenerEventConnectionAttemptFailed:
+			log.Errorw("database.Listen", "status", "connection attempt failed", "channel", channel, "ERROR", err)
+		}
+	}
+
+	listener := pq.NewListener(connString(cfg), listenMinReconnect, listenMaxReconnect, event)
+	defer listener.Close()
+
+	if err := listener.Listen(channel); err != nil {
+		return fmt.Errorf("listen channel[%s]: %w", channel, err)
+	}
+
+	ticker := time.NewTicker(listenPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case n := <-listener.Notify:
+
+			// A nil notification is sent after the connection has been
+			// reestablished.
+			if n == nil {
+				resync()
+				continue
+			}
+			notify(n.Extra)
+
+		case <-ticker.C:
+
+			// Make sure a connection that silently died is noticed.
+			go listener.Ping()
+		}
+	}
+}
@@ -7,30 +7,68 @@ import (
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/apikey"
+	"github.com/halilylm/micro/business/core/apikey/repository/apikeydb"
+	"github.com/halilylm/micro/business/core/audit"
+	"github.com/halilylm/micro/business/core/audit/repository/auditdb"
+	"github.com/halilylm/micro/business/core/revocation"
+	"github.com/halilylm/micro/business/core/revocation/repository/revocationdb"
+	"github.com/halilylm/micro/business/core/role"
+	"github.com/halilylm/micro/business/core/role/repository/roledb"
 	"github.com/halilylm/micro/business/core/user"
 	"github.com/halilylm/micro/business/core/user/repository/userdb"
+	"github.com/halilylm/micro/foundation/decisionlog"
+	"github.com/halilylm/micro/foundation/web"
 	"github.com/jmoiron/sqlx"
 	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/storage"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// policyDataTTL is how long the roles loaded from the database are used
+// before they are loaded again. Changes made through this instance are
+// picked up right away with InvalidatePolicyData.
+const policyDataTTL = 30 * time.Second
+
+// keySetTTL is how long the kids loaded from the key lookup are used before
+// they are loaded again. A token with an unknown kid triggers a reload
+// sooner, at most once every keySetMinRefresh so bogus kids can't be used to
+// flood the key store.
+const (
+	keySetTTL        = time.Minute
+	keySetMinRefresh = 5 * time.Second
 )
 
 // ErrForbidden is returned when an auth issue is identified.
 var ErrForbidden = errors.New("attempted action is not allowed")
 
 // KeyLookup declares a method set of behaviour for looking up
-// private and public keys for JWT use.
+// private and public keys for JWT use. ActiveKIDs lists the keys tokens can
+// be verified with and SigningKID is the one of them new tokens are signed
+// with.
 type KeyLookup interface {
 	PrivateKeyPEM(kid string) (string, error)
 	PublicKeyPEM(kid string) (string, error)
+	ActiveKIDs() ([]string, error)
+	SigningKID() (string, error)
 }
 
-// Config represents information required to initialize auth.
+// Config represents information required to initialize auth. UserRepo is
+// how users are looked up for every request, like the cached repository of
+// the service; it defaults to the database.
 type Config struct {
-	Log       *zap.SugaredLogger
-	DB        *sqlx.DB
-	KeyLookup KeyLookup
+	Log         *zap.SugaredLogger
+	DB          *sqlx.DB
+	UserRepo    user.Repository
+	KeyLookup   KeyLookup
+	PolicyPath  string
+	OIDC        OIDCConfig
+	DecisionLog decisionlog.Logger
 }
 
 // Auth is used to authenticate clients. It can generate a token for a
@@ -39,50 +77,125 @@ type Auth struct {
 	log       *zap.SugaredLogger
 	keyLookup KeyLookup
 	user      *user.Core
-	method    jwt.SigningMethod
+	apiKey    *apikey.Core
+	role      *role.Core
+	revoked   *revocation.Core
+	audit     *audit.Core
 	parser    *jwt.Parser
-
-	mu    sync.RWMutex
-	cache map[string]string
+	oidc      *oidcProvider
+	decisions decisionlog.Logger
+
+	mu         sync.RWMutex
+	cache      map[string]string
+	kids       map[string]bool
+	signingKID string
+	keysLoaded time.Time
+
+	policyPath string
+	policy     atomic.Pointer[policySet]
+	policyMu   sync.Mutex
+	policyErr  error
+
+	dataLoaded  atomic.Int64
+	dataLoading atomic.Bool
 }
 
 // New creates an Auth to support authentication/authorization.
 func New(cfg Config) (*Auth, error) {
 
 	// If a database connection is not provided, we won't perform the
-	// user enabled check.
+	// user enabled and revocation checks, api keys can't be used and the
+	// default roles are used for authorization.
 	var usr *user.Core
+	var key *apikey.Core
+	var rol *role.Core
+	var rev *revocation.Core
+	var aud *audit.Core
 	if cfg.DB != nil {
-		usr = user.NewCore(userdb.NewRepository(cfg.Log, cfg.DB))
+		aud = audit.NewCore(auditdb.NewRepository(cfg.Log, cfg.DB))
+		usrRepo := cfg.UserRepo
+		if usrRepo == nil {
+			usrRepo = userdb.NewRepository(cfg.Log, cfg.DB)
+		}
+		usr = user.NewCore(usrRepo, aud)
+		key = apikey.NewCore(usr, aud, apikeydb.NewRepository(cfg.Log, cfg.DB))
+		rol = role.NewCore(roledb.NewRepository(cfg.Log, cfg.DB), aud)
+		rev = revocation.NewCore(aud, revocationdb.NewRepository(cfg.Log, cfg.DB))
 	}
 
 	a := Auth{
-		log:       cfg.Log,
-		keyLookup: cfg.KeyLookup,
-		user:      usr,
-		method:    jwt.GetSigningMethod("RS256"),
-		parser:    jwt.NewParser(jwt.WithValidMethods([]string{"RS256"})),
-		cache:     make(map[string]string),
+		log:        cfg.Log,
+		keyLookup:  cfg.KeyLookup,
+		user:       usr,
+		apiKey:     key,
+		role:       rol,
+		revoked:    rev,
+		audit:      aud,
+		decisions:  cfg.DecisionLog,
+		parser:     jwt.NewParser(jwt.WithValidMethods(signingMethods)),
+		cache:      make(map[string]string),
+		policyPath: cfg.PolicyPath,
+	}
+
+	// Tokens of an external issuer are linked to local users, which
+	// requires the database.
+	if cfg.OIDC.Issuer != "" {
+		if usr == nil {
+			return nil, errors.New("oidc requires a database connection")
+		}
+
+		p, err := newOIDCProvider(cfg.Log, cfg.OIDC)
+		if err != nil {
+			return nil, fmt.Errorf("constructing oidc provider: %w", err)
+		}
+		a.oidc = p
+	}
+
+	// The policies built into the binary are used unless a directory or
+	// bundle is configured, which can then be reloaded while running.
+	if cfg.PolicyPath == "" {
+		ps, err := embeddedPolicySet()
+		if err != nil {
+			return nil, fmt.Errorf("preparing embedded policy: %w", err)
+		}
+		a.policy.Store(ps)
+		return &a, nil
+	}
+
+	a.policy.Store(&policySet{})
+	if err := a.ReloadPolicy(); err != nil {
+		return nil, fmt.Errorf("loading policy: %w", err)
 	}
 
 	return &a, nil
 }
 
-// GenerateToken generates a signed JWT token string representing the user Claims.
-func (a *Auth) GenerateToken(kid string, claims Claims) (string, error) {
-	token := jwt.NewWithClaims(a.method, claims)
-	token.Header["kid"] = kid
+// GenerateToken generates a JWT token string representing the user Claims
+// signed with the active key. The algorithm follows the type of the key.
+// Tokens get an id so they can be revoked.
+func (a *Auth) GenerateToken(claims Claims) (string, error) {
+	if claims.ID == "" {
+		claims.ID = uuid.NewString()
+	}
+
+	kid, err := a.activeKID()
+	if err != nil {
+		return "", fmt.Errorf("signing key: %w", err)
+	}
 
 	privateKeyPEM, err := a.keyLookup.PrivateKeyPEM(kid)
 	if err != nil {
 		return "", fmt.Errorf("private key: %w", err)
 	}
 
-	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(privateKeyPEM))
+	method, privateKey, err := signingKey(privateKeyPEM)
 	if err != nil {
 		return "", fmt.Errorf("parsing private pem: %w", err)
 	}
 
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+
 	str, err := token.SignedString(privateKey)
 	if err != nil {
 		return "", fmt.Errorf("signing token: %w", err)
@@ -94,17 +207,205 @@ func (a *Auth) GenerateToken(kid string, claims Claims) (string, error) {
 // Authenticate processes the token to validate the sender's token is valid.
 func (a *Auth) Authenticate(ctx context.Context, bearerToken string) (Claims, error) {
 	parts := strings.Split(bearerToken, " ")
-	if len(parts) != 2 || parts[1] != "Bearer" {
+	if len(parts) != 2 || parts[0] != "Bearer" {
 		return Claims{}, fmt.Errorf("invalid bearer token, format: Bearer <token>")
 	}
 
+	return a.authenticate(ctx, parts[1])
+}
+
+// AuthenticateMFAChallenge validates a token issued for the password step of
+// a login that still requires the MFA step.
+func (a *Auth) AuthenticateMFAChallenge(ctx context.Context, token string) (Claims, error) {
+	claims, err := a.verify(ctx, token)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	if !claims.VerifyAudience(AudienceMFAChallenge, true) {
+		return Claims{}, errors.New("not an mfa challenge token")
+	}
+
+	if !a.isUserEnabled(ctx, claims) {
+		return Claims{}, errors.New("user not enabled")
+	}
+
+	return claims, nil
+}
+
+// AuthenticateAPIKey validates an api key sent in the form "ApiKey <key>"
+// and returns claims for the key's user limited to the key's roles. A role
+// the user lost since the key was created isn't granted by the key either.
+func (a *Auth) AuthenticateAPIKey(ctx context.Context, authorization string) (Claims, error) {
+	if a.apiKey == nil {
+		return Claims{}, errors.New("api keys are not supported")
+	}
+
+	parts := strings.Split(authorization, " ")
+	if len(parts) != 2 || parts[0] != "ApiKey" {
+		return Claims{}, fmt.Errorf("invalid api key, format: ApiKey <key>")
+	}
+
+	key, err := a.apiKey.Authenticate(ctx, parts[1])
+	if err != nil {
+		return Claims{}, fmt.Errorf("authenticating api key: %w", err)
+	}
+
+	usr, err := a.user.QueryByID(ctx, key.UserID)
+	if err != nil {
+		return Claims{}, fmt.Errorf("querying user: %w", err)
+	}
+
+	if !usr.Enabled {
+		return Claims{}, errors.New("user not enabled")
+	}
+
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       key.ID.String(),
+			Subject:  key.UserID.String(),
+			IssuedAt: jwt.NewNumericDate(key.DateCreated),
+		},
+		Roles:  intersect(key.Roles, usr.Roles),
+		Scopes: key.Scopes,
+	}
+	if !key.DateExpires.IsZero() {
+		claims.ExpiresAt = jwt.NewNumericDate(key.DateExpires)
+	}
+
+	return claims, nil
+}
+
+// Authorize attempts to authorize the user with the provided input roles, if
+// none of the input roles are within user's claims, we return an error
+// otherwise the user is authorized. The resource lets the rule take the
+// ownership of what's being accessed into account.
+func (a *Auth) Authorize(ctx context.Context, claims Claims, rule string, res Resource) error {
+	input := map[string]any{
+		"Subject": claims.Subject,
+		"Actor":   claims.actor(),
+		"Roles":   claims.Roles,
+		"AMR":     claims.AMR,
+		"Resource": map[string]any{
+			"Type":    res.Type,
+			"OwnerID": res.OwnerID,
+			"Action":  res.Action,
+		},
+	}
+
+	a.refreshPolicyData(ctx)
+
+	if err := a.opaPolicyEvaluation(ctx, rule, input); err != nil {
+		return fmt.Errorf("rego evaluation failed: %w", err)
+	}
+
+	return nil
+}
+
+// AuthorizePermission checks that one of the user's roles grants the
+// specified permission.
+func (a *Auth) AuthorizePermission(ctx context.Context, claims Claims, permission string) error {
+	input := map[string]any{
+		"Subject":    claims.Subject,
+		"Actor":      claims.actor(),
+		"Roles":      claims.Roles,
+		"AMR":        claims.AMR,
+		"Permission": permission,
+	}
+
+	a.refreshPolicyData(ctx)
+
+	if err := a.opaPolicyEvaluation(ctx, RulePermission, input); err != nil {
+		return fmt.Errorf("rego evaluation failed: %w", err)
+	}
+
+	return nil
+}
+
+// AuthorizeScope checks that the scopes of the token allow the scope the
+// route requires. A token impersonating a user must also be allowed to use
+// the scope while impersonating.
+func (a *Auth) AuthorizeScope(ctx context.Context, claims Claims, scope string) error {
+	scopes := claims.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	input := map[string]any{
+		"Subject": claims.Subject,
+		"Actor":   claims.actor(),
+		"Scopes":  scopes,
+		"Scope":   scope,
+	}
+
+	if err := a.opaPolicyEvaluation(ctx, RuleScope, input); err != nil {
+		return fmt.Errorf("rego evaluation failed: %w", err)
+	}
+
+	if claims.Impersonated() {
+		if err := a.opaPolicyEvaluation(ctx, RuleImpersonated, input); err != nil {
+			return fmt.Errorf("rego evaluation failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// InvalidatePolicyData forces the roles to be loaded from the database on
+// the next authorization.
+func (a *Auth) InvalidatePolicyData() {
+	a.dataLoaded.Store(0)
+}
+
+// authenticate validates a token issued by this service or the external
+// issuer. The token must not be revoked and its user must be enabled.
+func (a *Auth) authenticate(ctx context.Context, token string) (Claims, error) {
+	if a.oidc != nil && a.oidc.issued(a.parser, token) {
+		return a.authenticateOIDC(ctx, token)
+	}
+
+	claims, err := a.verify(ctx, token)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	if claims.VerifyAudience(AudienceMFAChallenge, true) {
+		return Claims{}, errors.New("mfa challenge token can't be used for access")
+	}
+
+	revoked, err := a.isRevoked(ctx, claims)
+	if err != nil {
+		return Claims{}, fmt.Errorf("checking revocation: %w", err)
+	}
+	if revoked {
+		return Claims{}, errors.New("token revoked")
+	}
+
+	if !a.isUserEnabled(ctx, claims) {
+		return Claims{}, errors.New("user not enabled")
+	}
+
+	// An impersonation ends as soon as the admin is disabled.
+	if claims.Impersonated() {
+		actor := Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: claims.Act.Subject}}
+		if !a.isUserEnabled(ctx, actor) {
+			return Claims{}, errors.New("actor not enabled")
+		}
+	}
+
+	return claims, nil
+}
+
+// verify checks the signature and registered claims of the token using the
+// public key identified by its kid.
+func (a *Auth) verify(ctx context.Context, token string) (Claims, error) {
 	var claims Claims
-	token, _, err := a.parser.ParseUnverified(parts[1], &claims)
+	tkn, _, err := a.parser.ParseUnverified(token, &claims)
 	if err != nil {
 		return Claims{}, fmt.Errorf("parsing token: %w", err)
 	}
 
-	kidRaw, exists := token.Header["kid"]
+	kidRaw, exists := tkn.Header["kid"]
 	if !exists {
 		return Claims{}, fmt.Errorf("kid missing from header")
 	}
@@ -119,39 +420,49 @@ func (a *Auth) Authenticate(ctx context.Context, bearerToken string) (Claims, er
 		return Claims{}, fmt.Errorf("failed to fetch public key: %w", err)
 	}
 
+	method, publicKey, err := verificationKey(pem)
+	if err != nil {
+		return Claims{}, fmt.Errorf("parsing public pem: %w", err)
+	}
+
+	if tkn.Method.Alg() != method.Alg() {
+		return Claims{}, fmt.Errorf("token alg %q doesn't match key alg %q", tkn.Method.Alg(), method.Alg())
+	}
+
 	input := map[string]any{
 		"Key":   pem,
-		"Token": parts[1],
+		"Token": token,
+		"Alg":   method.Alg(),
 	}
 
-	if err := a.opaPolicyEvaluation(ctx, opaAuthentication, RuleAuthenticate, input); err != nil {
-		return Claims{}, fmt.Errorf("authentication failed: %w", err)
+	// OPA can't verify EdDSA signatures so it's done here and the policy
+	// only checks the claims.
+	if method == jwt.SigningMethodEdDSA {
+		parts := strings.Split(token, ".")
+		if err := method.Verify(strings.Join(parts[:2], "."), parts[2], publicKey); err != nil {
+			return Claims{}, fmt.Errorf("authentication failed: %w", err)
+		}
+		input["SignatureVerified"] = true
 	}
 
-	if !a.isUserEnabled(ctx, claims) {
-		return Claims{}, fmt.Errorf("user not enabled: %w", err)
+	if err := a.opaPolicyEvaluation(ctx, RuleAuthenticate, input); err != nil {
+		return Claims{}, fmt.Errorf("authentication failed: %w", err)
 	}
 
 	return claims, nil
 }
 
-// Authorize attempts to authorize the user with the provided input roles, if
-// none of the input roles are within user's claims, we return an error
-// otherwise the user is authorized.
-func (a *Auth) Authorize(ctx context.Context, claims Claims, rule string) error {
-	input := map[string]any{
-		"Roles": claims.Roles,
+// publicKeyLookup performs a lookup for the public pem for the specified
+// key. Only keys that are still in the key set can be used.
+func (a *Auth) publicKeyLookup(kid string) (string, error) {
+	known, err := a.knownKID(kid)
+	if err != nil {
+		return "", err
 	}
-
-	if err := a.opaPolicyEvaluation(ctx, opaAuthorization, rule, input); err != nil {
-		return fmt.Errorf("rego evaluation failed: %w", err)
+	if !known {
+		return "", fmt.Errorf("kid %q is not active", kid)
 	}
 
-	return nil
-}
-
-// publicKeyLookup performs a lookup for the public pem for the specified key.
-func (a *Auth) publicKeyLookup(kid string) (string, error) {
 	pem, err := func() (string, error) {
 		a.mu.RLock()
 		defer a.mu.RUnlock()
@@ -178,31 +489,272 @@ func (a *Auth) publicKeyLookup(kid string) (string, error) {
 	return pem, nil
 }
 
-// opaPolicyEvaluation asks opa to evaluate the token against the specified token
-// policy and public key.
-func (a *Auth) opaPolicyEvaluation(ctx context.Context, opaPolicy string, rule string, input any) error {
-	query := fmt.Sprintf("x = data.%s.%s", opaPackage, rule)
+// activeKID returns the kid new tokens are signed with.
+func (a *Auth) activeKID() (string, error) {
+	if err := a.loadKeys(false); err != nil {
+		return "", err
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.signingKID == "" {
+		return "", errors.New("no active signing key")
+	}
+	return a.signingKID, nil
+}
+
+// verificationKIDs returns the kids tokens can be verified with.
+func (a *Auth) verificationKIDs() ([]string, error) {
+	if err := a.loadKeys(false); err != nil {
+		return nil, err
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	kids := make([]string, 0, len(a.kids))
+	for kid := range a.kids {
+		kids = append(kids, kid)
+	}
+	return kids, nil
+}
+
+// knownKID reports whether tokens can be verified with the kid. A kid that
+// isn't known causes the key set to be loaded again since it may have been
+// rotated in by another instance.
+func (a *Auth) knownKID(kid string) (bool, error) {
+	if err := a.loadKeys(false); err != nil {
+		return false, err
+	}
+
+	a.mu.RLock()
+	known := a.kids[kid]
+	a.mu.RUnlock()
+
+	if known {
+		return true, nil
+	}
+
+	if err := a.loadKeys(true); err != nil {
+		return false, err
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.kids[kid], nil
+}
+
+// loadKeys loads the key set from the key lookup once it's older than
+// keySetTTL, or keySetMinRefresh when forced. If the key set can't be loaded
+// the last one is kept. The public keys of the kids that left the set are
+// dropped from the cache.
+func (a *Auth) loadKeys(force bool) error {
+	ttl := keySetTTL
+	if force {
+		ttl = keySetMinRefresh
+	}
+
+	a.mu.RLock()
+	loaded, hasKeys := a.keysLoaded, a.kids != nil
+	a.mu.RUnlock()
+
+	if time.Since(loaded) < ttl {
+		return nil
+	}
+
+	kids, err := a.keyLookup.ActiveKIDs()
+	if err == nil {
+		var signing string
+		signing, err = a.keyLookup.SigningKID()
+
+		if err == nil {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+
+			a.kids = make(map[string]bool, len(kids))
+			for _, kid := range kids {
+				a.kids[kid] = true
+			}
+			for kid := range a.cache {
+				if !a.kids[kid] {
+					delete(a.cache, kid)
+				}
+			}
+			a.signingKID = signing
+			a.keysLoaded = time.Now()
+
+			return nil
+		}
+	}
+
+	if !hasKeys {
+		return fmt.Errorf("loading key set: %w", err)
+	}
+	a.log.Errorw("auth", "status", "loading key set", "ERROR", err)
+
+	// Back off until the next refresh instead of hitting the key store on
+	// every request.
+	a.mu.Lock()
+	a.keysLoaded = time.Now()
+	a.mu.Unlock()
+
+	return nil
+}
+
+// refreshPolicyData replaces the roles and permissions in the data document
+// used by the authorization policy once they're older than policyDataTTL.
+// If the roles can't be loaded, the last good ones are kept. Only one
+// request loads them at a time, the others carry on with the current ones.
+func (a *Auth) refreshPolicyData(ctx context.Context) {
+	if a.role == nil || a.policyDataFresh() {
+		return
+	}
+
+	if !a.dataLoading.CompareAndSwap(false, true) {
+		return
+	}
+	defer a.dataLoading.Store(false)
 
-	q, err := rego.New(
-		rego.Query(query),
-		rego.Module("policy.rego", opaPolicy),
-	).PrepareForEval(ctx)
+	if a.policyDataFresh() {
+		return
+	}
+
+	// Back off until the next refresh even on failure instead of hitting
+	// the database on every request.
+	a.dataLoaded.Store(time.Now().UnixNano())
+
+	data, err := a.role.PolicyData(ctx)
+	if err != nil {
+		a.log.Errorw("auth", "status", "loading policy data", "ERROR", err)
+		return
+	}
+
+	// Only the roles are replaced so the rest of the data document that
+	// came with the policy stays.
+	store := a.policy.Load().store
+	if err := storage.WriteOne(ctx, store, storage.AddOp, storage.MustParsePath("/roles"), data["roles"]); err != nil {
+		a.log.Errorw("auth", "status", "storing policy data", "ERROR", err)
+	}
+}
+
+// policyDataFresh reports whether the roles were loaded less than
+// policyDataTTL ago.
+func (a *Auth) policyDataFresh() bool {
+	return time.Since(time.Unix(0, a.dataLoaded.Load())) < policyDataTTL
+}
+
+// opaPolicyEvaluation asks opa to evaluate the input against the prepared
+// query for the specified rule. Every decision is recorded on a span and in
+// the decision log.
+func (a *Auth) opaPolicyEvaluation(ctx context.Context, rule string, input map[string]any) error {
+	ps := a.policy.Load()
+
+	ctx, span := web.AddSpan(ctx, "business.web.auth.decision", attribute.String("rule", rule), attribute.String("revision", ps.status.Revision))
+	defer span.End()
+
+	start := time.Now()
+	allowed, err := evaluate(ctx, ps, rule, input)
+	duration := time.Since(start)
+
+	span.SetAttributes(attribute.Bool("allowed", allowed))
+
+	if a.decisions != nil {
+		d := decisionlog.Decision{
+			ID:       uuid.NewString(),
+			Time:     start,
+			TraceID:  web.GetTraceID(ctx),
+			Rule:     rule,
+			Revision: ps.status.Revision,
+			Input:    redact(input),
+			Allowed:  allowed,
+			Duration: duration,
+		}
+		if err != nil {
+			d.Error = err.Error()
+		}
+		a.decisions.Log(ctx, d)
+	}
+
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return fmt.Errorf("rule %s denied: %w", rule, ErrForbidden)
+	}
+
+	return nil
+}
+
+// evaluate runs the prepared query for the rule of the policy and reports
+// whether it allows the input. An error means no decision could be made.
+func evaluate(ctx context.Context, ps *policySet, rule string, input map[string]any) (bool, error) {
+	q, ok := ps.queries[rule]
+	if !ok {
+		return false, fmt.Errorf("unknown rule %q", rule)
+	}
 
 	results, err := q.Eval(ctx, rego.EvalInput(input))
 	if err != nil {
-		return fmt.Errorf("query: %w", err)
+		return false, fmt.Errorf("query: %w", err)
 	}
 
 	if len(results) == 0 {
-		return errors.New("no results")
+		return false, errors.New("no results")
 	}
 
 	result, ok := results[0].Bindings["x"].(bool)
-	if !ok || !result {
-		return fmt.Errorf("bindings results[%v] ok[%v]", result, ok)
+	if !ok {
+		return false, fmt.Errorf("bindings results[%v] ok[%v]", results[0].Bindings["x"], ok)
 	}
 
-	return nil
+	return result, nil
+}
+
+// redactedInputs are the input fields left out of the decision log. The
+// token is a credential and the key is only noise.
+var redactedInputs = map[string]bool{
+	"Token": true,
+	"Key":   true,
+}
+
+// redact returns a copy of the input safe to log.
+func redact(input map[string]any) map[string]any {
+	out := make(map[string]any, len(input))
+	for k, v := range input {
+		if redactedInputs[k] {
+			v = "[REDACTED]"
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// isRevoked hits the database and checks the token wasn't revoked. If there
+// is no database connection or the token has no id, this check is skipped.
+func (a *Auth) isRevoked(ctx context.Context, claims Claims) (bool, error) {
+	if a.revoked == nil || claims.ID == "" {
+		return false, nil
+	}
+
+	return a.revoked.IsRevoked(ctx, claims.ID)
+}
+
+// intersect returns the roles in a that are also in b, in the order of a.
+func intersect(a []string, b []string) []string {
+	m := make(map[string]bool, len(b))
+	for _, role := range b {
+		m[role] = true
+	}
+
+	roles := []string{}
+	for _, role := range a {
+		if m[role] {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles
 }
 
 // isUserEnabled hits the database and checks the user is not disabled. If there is
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/halilylm/micro/foundation/keystore"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"go.uber.org/zap"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// BenchmarkAuthenticate compares verifying a token with a query prepared
+// for every evaluation, the way it was done before the policies were
+// prepared once, against the prepared query.
+func BenchmarkAuthenticate(b *testing.B) {
+	a := newTestAuth(b)
+	ctx := context.Background()
+
+	token, err := a.GenerateToken(benchClaims())
+	if err != nil {
+		b.Fatalf("generating token: %s", err)
+	}
+
+	b.Run("old", func(b *testing.B) {
+		pem, err := a.publicKeyLookup(benchKID)
+		if err != nil {
+			b.Fatalf("public key: %s", err)
+		}
+		input := map[string]any{
+			"Key":   pem,
+			"Token": token,
+			"Alg":   "RS256",
+		}
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := oldPolicyEvaluation(ctx, RuleAuthenticate, input); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("prepared", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := a.Authenticate(ctx, "Bearer "+token); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkAuthorize compares authorizing an admin with a query prepared
+// for every evaluation against the prepared query.
+func BenchmarkAuthorize(b *testing.B) {
+	a := newTestAuth(b)
+	ctx := context.Background()
+	claims := benchClaims()
+
+	b.Run("old", func(b *testing.B) {
+		input := map[string]any{
+			"Subject": claims.Subject,
+			"Roles":   claims.Roles,
+			"AMR":     claims.AMR,
+		}
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := oldPolicyEvaluation(ctx, RuleAdminOnly, input); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("prepared", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := a.Authorize(ctx, claims, RuleAdminOnly, Resource{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// =============================================================================
+
+const benchKID = "bench"
+
+// newTestAuth constructs an Auth without a database using the embedded
+// policies and a freshly generated key.
+func newTestAuth(b testing.TB) *Auth {
+	b.Helper()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		b.Fatalf("generating key: %s", err)
+	}
+
+	block := pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	}
+
+	fsys := fstest.MapFS{
+		benchKID + ".pem": &fstest.MapFile{Data: pem.EncodeToMemory(&block)},
+	}
+
+	ks, err := keystore.NewFS(fsys)
+	if err != nil {
+		b.Fatalf("constructing key store: %s", err)
+	}
+
+	a, err := New(Config{
+		Log:       zap.NewNop().Sugar(),
+		KeyLookup: ks,
+	})
+	if err != nil {
+		b.Fatalf("constructing auth: %s", err)
+	}
+
+	return a
+}
+
+// benchClaims returns the claims of an admin valid for the benchmark.
+func benchClaims() Claims {
+	return Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "5cf37266-3473-4006-984f-9325122678b7",
+			Issuer:    "micro",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		Roles: []string{"ADMIN"},
+		AMR:   []string{"pwd"},
+	}
+}
+
+// oldPolicyEvaluation compiles the policies and prepares the query on every
+// call, which is what every evaluation cost before the policy set.
+func oldPolicyEvaluation(ctx context.Context, rule string, input map[string]any) error {
+	modules, data, _, err := readPolicy("")
+	if err != nil {
+		return err
+	}
+
+	opts := []func(*rego.Rego){
+		rego.Query(fmt.Sprintf("x = data.%s.%s", opaPackage, rule)),
+		rego.Store(inmem.NewFromObject(data)),
+	}
+	for path, module := range modules {
+		opts = append(opts, rego.Module(path, module))
+	}
+
+	q, err := rego.New(opts...).PrepareForEval(ctx)
+	if err != nil {
+		return fmt.Errorf("preparing: %w", err)
+	}
+
+	results, err := q.Eval(ctx, rego.EvalInput(input))
+	if err != nil {
+		return fmt.Errorf("query: %w", err)
+	}
+
+	if len(results) == 0 {
+		return fmt.Errorf("no results")
+	}
+
+	result, ok := results[0].Bindings["x"].(bool)
+	if !ok || !result {
+		return fmt.Errorf("bindings results[%v] ok[%v]", result, ok)
+	}
+
+	return nil
+}
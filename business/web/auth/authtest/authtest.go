@@ -0,0 +1,45 @@
+// Package authtest provides support for testing the auth policies from Go
+// tests.
+package authtest
+
+import (
+	"context"
+	"github.com/halilylm/micro/business/web/auth"
+	"testing"
+)
+
+// PolicyFixtures runs the cases of the fixtures, a file or a directory,
+// against the policy at the path or the embedded policy when the path is
+// empty. Every case is a subtest and rules missing an allowed or a denied
+// case are logged.
+func PolicyFixtures(t *testing.T, policyPath string, fixtures string) {
+	t.Helper()
+
+	suites, err := auth.LoadPolicySuites(fixtures)
+	if err != nil {
+		t.Fatalf("loading fixtures: %s", err)
+	}
+
+	report, err := auth.RunPolicyTests(context.Background(), policyPath, suites)
+	if err != nil {
+		t.Fatalf("running fixtures: %s", err)
+	}
+
+	for _, res := range report.Results {
+		res := res
+		t.Run(res.Case.Name, func(t *testing.T) {
+			if res.Err != nil {
+				t.Fatalf("%s: rule %s: %s", res.File, res.Case.Rule, res.Err)
+			}
+			if !res.Passed() {
+				t.Errorf("%s: rule %s: allowed %v, expected %v", res.File, res.Case.Rule, res.Allowed, res.Case.Allow)
+			}
+		})
+	}
+
+	for _, cov := range report.Coverage {
+		if !cov.Covered() {
+			t.Logf("rule %s not covered: %d allowed, %d denied cases", cov.Rule, cov.Allow, cov.Deny)
+		}
+	}
+}
@@ -5,10 +5,46 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
-// Claims represents the authorization claims transmitted via a JWT.
+// AudienceMFAChallenge marks a token that only proves the password step of
+// a login. It can be exchanged for a real token by completing the MFA step
+// but can't be used to access the API.
+const AudienceMFAChallenge = "mfa-challenge"
+
+// Set of authentication methods recorded in the amr claim (RFC 8176).
+const (
+	AMRPassword = "pwd"
+	AMRMFA      = "mfa"
+)
+
+// Claims represents the authorization claims transmitted via a JWT. Act is
+// set when the token was issued to someone impersonating the subject.
 type Claims struct {
 	jwt.RegisteredClaims
-	Roles []string `json:"roles"`
+	Roles  []string `json:"roles"`
+	AMR    []string `json:"amr,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+	Act    *Actor   `json:"act,omitempty"`
+}
+
+// Actor identifies who is acting on behalf of the subject of a token, as
+// the act claim of RFC 8693.
+type Actor struct {
+	Subject string `json:"sub"`
+}
+
+// Impersonated reports whether the token was issued to someone acting as
+// the subject.
+func (c Claims) Impersonated() bool {
+	return c.Act != nil && c.Act.Subject != ""
+}
+
+// actor returns the subject of the actor or an empty string when the token
+// isn't impersonating anyone.
+func (c Claims) actor() string {
+	if !c.Impersonated() {
+		return ""
+	}
+	return c.Act.Subject
 }
 
 // ctxKey represents the type of value for the context key.
@@ -24,8 +60,9 @@ func SetClaims(ctx context.Context, claims Claims) context.Context {
 
 // GetClaims returns the claims from the context.
 func GetClaims(ctx context.Context) Claims {
-	if v, ok := ctx.Value(key).(Claims); !ok {
-		return v
+	v, ok := ctx.Value(key).(Claims)
+	if !ok {
+		return Claims{}
 	}
-	return Claims{}
+	return v
 }
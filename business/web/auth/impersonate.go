@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/audit"
+	"github.com/halilylm/micro/business/core/user"
+	"time"
+)
+
+// PermissionImpersonate allows acting as another user.
+const PermissionImpersonate = "impersonate"
+
+// ImpersonationTTL is how long an impersonation token can be used. It's
+// kept short since the token carries the rights of someone else.
+const ImpersonationTTL = 15 * time.Minute
+
+// Set of error variables for impersonation.
+var (
+	ErrImpersonateSelf     = errors.New("can't impersonate yourself")
+	ErrImpersonateDisabled = errors.New("can't impersonate a disabled user")
+	ErrNestedImpersonation = errors.New("can't impersonate while impersonating")
+	ErrNotImpersonating    = errors.New("token isn't impersonating a user")
+	ErrImpersonateElevated = errors.New("can't impersonate a user with permissions you don't have")
+)
+
+// Impersonation describes a token issued to act as a user. It's recorded
+// in the audit log when it starts and ends.
+type Impersonation struct {
+	Token       string    `json:"token,omitempty"`
+	TokenID     string    `json:"token_id"`
+	Actor       string    `json:"actor"`
+	Subject     string    `json:"subject"`
+	DateExpires time.Time `json:"date_expires"`
+}
+
+// Impersonate issues a short lived token for the user on behalf of the
+// actor, who is named in the act claim. The token has the roles of the user
+// and the authentication methods and scopes of the actor, so it can't do
+// more than the actor's own token.
+func (a *Auth) Impersonate(ctx context.Context, actor Claims, usr user.User) (Impersonation, error) {
+	if a.audit == nil || a.revoked == nil {
+		return Impersonation{}, errors.New("impersonation requires a database connection")
+	}
+
+	switch {
+	case actor.Impersonated():
+		return Impersonation{}, ErrNestedImpersonation
+	case actor.Subject == usr.ID.String():
+		return Impersonation{}, ErrImpersonateSelf
+	case !usr.Enabled:
+		return Impersonation{}, ErrImpersonateDisabled
+	}
+
+	// The token gets the roles of the user, they can't grant more than the
+	// roles of the actor.
+	input := map[string]any{
+		"Subject":     actor.Subject,
+		"Roles":       actor.Roles,
+		"AMR":         actor.AMR,
+		"TargetRoles": usr.Roles,
+	}
+
+	a.refreshPolicyData(ctx)
+
+	if err := a.opaPolicyEvaluation(ctx, RuleImpersonate, input); err != nil {
+		if errors.Is(err, ErrForbidden) {
+			return Impersonation{}, ErrImpersonateElevated
+		}
+		return Impersonation{}, fmt.Errorf("rego evaluation failed: %w", err)
+	}
+
+	now := time.Now().UTC()
+
+	// The id is set here rather than by GenerateToken so it can be recorded.
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   usr.ID.String(),
+			Issuer:    "micro",
+			ExpiresAt: jwt.NewNumericDate(now.Add(ImpersonationTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		Roles:  usr.Roles,
+		AMR:    actor.AMR,
+		Scopes: actor.Scopes,
+		Act:    &Actor{Subject: actor.Subject},
+	}
+
+	token, err := a.GenerateToken(claims)
+	if err != nil {
+		return Impersonation{}, fmt.Errorf("generating token: %w", err)
+	}
+
+	imp := Impersonation{
+		TokenID:     claims.ID,
+		Actor:       actor.Subject,
+		Subject:     claims.Subject,
+		DateExpires: claims.ExpiresAt.Time,
+	}
+
+	ctx = audit.SetActor(ctx, actor.Subject)
+	if err := a.audit.Record(ctx, audit.ActionImpersonationStart, audit.EntityUser, imp.Subject, nil, imp); err != nil {
+		return Impersonation{}, fmt.Errorf("audit: %w", err)
+	}
+
+	imp.Token = token
+
+	return imp, nil
+}
+
+// EndImpersonation revokes the impersonation token the claims came from
+// and records the end of the impersonation. A token that expires instead
+// only has the expiry recorded at the start.
+func (a *Auth) EndImpersonation(ctx context.Context, claims Claims) error {
+	if !claims.Impersonated() {
+		return ErrNotImpersonating
+	}
+
+	if a.audit == nil || a.revoked == nil {
+		return errors.New("impersonation requires a database connection")
+	}
+
+	imp := Impersonation{
+		TokenID: claims.ID,
+		Actor:   claims.Act.Subject,
+		Subject: claims.Subject,
+	}
+	if claims.ExpiresAt != nil {
+		imp.DateExpires = claims.ExpiresAt.Time
+	}
+
+	ctx = audit.SetActor(ctx, imp.Actor)
+	ctx = audit.SetOnBehalfOf(ctx, imp.Subject)
+
+	if _, err := a.revoked.Revoke(ctx, imp.TokenID, imp.Subject, imp.DateExpires); err != nil {
+		return fmt.Errorf("revoking token: %w", err)
+	}
+
+	if err := a.audit.Record(ctx, audit.ActionImpersonationEnd, audit.EntityUser, imp.Subject, imp, nil); err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	return nil
+}
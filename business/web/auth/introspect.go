@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/halilylm/micro/business/core/apikey"
+	"github.com/halilylm/micro/business/core/audit"
+	"strings"
+	"time"
+)
+
+// PermissionIntrospect allows looking into the tokens other clients present.
+const PermissionIntrospect = "introspect"
+
+// ErrUnsupportedTokenType is returned when a token can't be revoked. The
+// message is the error code of RFC 7009.
+var ErrUnsupportedTokenType = errors.New("unsupported_token_type")
+
+// Introspection describes a token in the form of an RFC 7662 response. Only
+// Active is set for a token that can't be used. Roles and KeyID are
+// additions to the registered fields, Act is the actor of RFC 8693.
+type Introspection struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Nbf       int64    `json:"nbf,omitempty"`
+	Sub       string   `json:"sub,omitempty"`
+	Aud       []string `json:"aud,omitempty"`
+	Iss       string   `json:"iss,omitempty"`
+	Jti       string   `json:"jti,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	KeyID     string   `json:"kid,omitempty"`
+	Act       *Actor   `json:"act,omitempty"`
+}
+
+// Introspect reports whether the token or api key can be used and for whom.
+// It goes through the same checks as a request carrying it, so a revoked
+// token or one of a disabled user isn't active. Why a token isn't active is
+// only logged.
+func (a *Auth) Introspect(ctx context.Context, token string) Introspection {
+	var claims Claims
+	var err error
+	var kid string
+	typ := "Bearer"
+
+	switch {
+	case apikey.IsAPIKey(token):
+		typ = "ApiKey"
+		claims, err = a.AuthenticateAPIKey(ctx, "ApiKey "+token)
+
+	default:
+		claims, err = a.authenticate(ctx, token)
+		if tkn, _, perr := a.parser.ParseUnverified(token, &Claims{}); perr == nil {
+			kid, _ = tkn.Header["kid"].(string)
+		}
+	}
+
+	if err != nil {
+		a.log.Infow("auth", "status", "introspection inactive", "ERROR", err)
+		return Introspection{}
+	}
+
+	intro := Introspection{
+		Active:    true,
+		Scope:     strings.Join(claims.Scopes, " "),
+		TokenType: typ,
+		Sub:       claims.Subject,
+		Aud:       claims.Audience,
+		Iss:       claims.Issuer,
+		Jti:       claims.ID,
+		Roles:     claims.Roles,
+		KeyID:     kid,
+		Act:       claims.Act,
+	}
+	if claims.ExpiresAt != nil {
+		intro.Exp = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		intro.Iat = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		intro.Nbf = claims.NotBefore.Unix()
+	}
+
+	return intro
+}
+
+// Revoke makes sure the token or api key can't be used anymore, following
+// RFC 7009. Holding the token is enough to revoke it. A token that is
+// invalid or expired already isn't an error. Tokens of the external issuer
+// and ones without an id can't be revoked.
+func (a *Auth) Revoke(ctx context.Context, token string) error {
+	if apikey.IsAPIKey(token) {
+		if a.apiKey == nil {
+			return ErrUnsupportedTokenType
+		}
+
+		key, err := a.apiKey.Authenticate(ctx, token)
+		if err != nil {
+			return nil
+		}
+
+		ctx = audit.SetActor(ctx, key.UserID.String())
+		if err := a.apiKey.Delete(ctx, key); err != nil {
+			return fmt.Errorf("revoking api key: %w", err)
+		}
+
+		return nil
+	}
+
+	if a.revoked == nil || (a.oidc != nil && a.oidc.issued(a.parser, token)) {
+		return ErrUnsupportedTokenType
+	}
+
+	claims, err := a.verify(ctx, token)
+	if err != nil {
+		return nil
+	}
+
+	// Tokens issued before they got an id can only expire.
+	if claims.ID == "" {
+		return ErrUnsupportedTokenType
+	}
+
+	// Revoking an impersonation token ends the impersonation.
+	if claims.Impersonated() {
+		if err := a.EndImpersonation(ctx, claims); err != nil {
+			return fmt.Errorf("revoking token: %w", err)
+		}
+		return nil
+	}
+
+	ctx = audit.SetActor(ctx, claims.Subject)
+
+	var expires time.Time
+	if claims.ExpiresAt != nil {
+		expires = claims.ExpiresAt.Time
+	}
+
+	if _, err := a.revoked.Revoke(ctx, claims.ID, claims.Subject, expires); err != nil {
+		return fmt.Errorf("revoking token: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+)
+
+// JWK represents a public key in the JSON Web Key format (RFC 7517). The
+// fields used depend on the key type (RFC 7518, RFC 8037).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKSet represents the set of public keys other services use to verify
+// the tokens issued by this service.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys tokens can be verified with. A key that
+// can't be loaded or converted is logged and left out so it doesn't hide
+// the rest.
+func (a *Auth) JWKS() (JWKSet, error) {
+	kids, err := a.verificationKIDs()
+	if err != nil {
+		return JWKSet{}, fmt.Errorf("active kids: %w", err)
+	}
+	sort.Strings(kids)
+
+	set := JWKSet{
+		Keys: make([]JWK, 0, len(kids)),
+	}
+
+	for _, kid := range kids {
+		pem, err := a.publicKeyLookup(kid)
+		if err != nil {
+			a.log.Errorw("auth", "status", "jwks public key", "kid", kid, "ERROR", err)
+			continue
+		}
+
+		jwk, err := ToJWK(kid, pem)
+		if err != nil {
+			a.log.Errorw("auth", "status", "jwks conversion", "kid", kid, "ERROR", err)
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set, nil
+}
+
+// ToJWK converts a PEM encoded public key into a JWK for signature
+// verification.
+func ToJWK(kid string, publicPEM string) (JWK, error) {
+	method, key, err := verificationKey(publicPEM)
+	if err != nil {
+		return JWK{}, fmt.Errorf("parsing public pem: %w", err)
+	}
+
+	jwk := JWK{
+		Use:       "sig",
+		KeyID:     kid,
+		Algorithm: method.Alg(),
+	}
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if key.E <= 0 {
+			return JWK{}, errors.New("invalid rsa exponent")
+		}
+		jwk.KeyType = "RSA"
+		jwk.N = encode(key.N.Bytes())
+		jwk.E = encode(big.NewInt(int64(key.E)).Bytes())
+
+	case *ecdsa.PublicKey:
+		// The coordinates are padded to the size of the curve.
+		size := (key.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = key.Curve.Params().Name
+		jwk.X = encode(key.X.FillBytes(make([]byte, size)))
+		jwk.Y = encode(key.Y.FillBytes(make([]byte, size)))
+
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = encode(key)
+
+	default:
+		return JWK{}, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	return jwk, nil
+}
+
+// PublicKeyPEM converts the JWK back into a PEM encoded public key.
+func (jwk JWK) PublicKeyPEM() (string, error) {
+	var key any
+
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return "", fmt.Errorf("decoding n: %w", err)
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return "", fmt.Errorf("decoding e: %w", err)
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() <= 0 || exp.Int64() > math.MaxInt32 {
+			return "", errors.New("invalid rsa exponent")
+		}
+		key = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(exp.Int64()),
+		}
+
+	case "EC":
+		if jwk.Curve != elliptic.P256().Params().Name {
+			return "", fmt.Errorf("unsupported curve %s, must be P-256", jwk.Curve)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return "", fmt.Errorf("decoding x: %w", err)
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return "", fmt.Errorf("decoding y: %w", err)
+		}
+		pub := ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return "", errors.New("invalid ec point")
+		}
+		key = &pub
+
+	case "OKP":
+		if jwk.Curve != "Ed25519" {
+			return "", fmt.Errorf("unsupported curve %s, must be Ed25519", jwk.Curve)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return "", fmt.Errorf("decoding x: %w", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return "", errors.New("invalid ed25519 key size")
+		}
+		key = ed25519.PublicKey(x)
+
+	default:
+		return "", fmt.Errorf("unsupported key type %q", jwk.KeyType)
+	}
+
+	asn1Bytes, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", fmt.Errorf("marshaling public key: %w", err)
+	}
+
+	block := pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: asn1Bytes,
+	}
+
+	return string(pem.EncodeToMemory(&block)), nil
+}
+
+// encode encodes the value as base64url without padding as required for
+// the key parameters.
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decode decodes a base64url key parameter without padding.
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// signingMethods are the algorithms tokens can be signed with. The one used
+// follows the type of the key.
+var signingMethods = []string{
+	jwt.SigningMethodRS256.Alg(),
+	jwt.SigningMethodES256.Alg(),
+	jwt.SigningMethodEdDSA.Alg(),
+}
+
+// signingKey parses a PEM encoded RSA, ECDSA P-256 or Ed25519 private key
+// and returns it with the signing method for its type.
+func signingKey(privatePEM string) (jwt.SigningMethod, any, error) {
+	b := []byte(privatePEM)
+
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(b); err == nil {
+		return jwt.SigningMethodRS256, key, nil
+	}
+
+	if key, err := jwt.ParseECPrivateKeyFromPEM(b); err == nil {
+		if err := checkCurve(&key.PublicKey); err != nil {
+			return nil, nil, err
+		}
+		return jwt.SigningMethodES256, key, nil
+	}
+
+	if key, err := jwt.ParseEdPrivateKeyFromPEM(b); err == nil {
+		return jwt.SigningMethodEdDSA, key, nil
+	}
+
+	return nil, nil, errors.New("unsupported private key, must be RSA, ECDSA P-256 or Ed25519")
+}
+
+// verificationKey parses a PEM encoded RSA, ECDSA P-256 or Ed25519 public
+// key and returns it with the signing method for its type.
+func verificationKey(publicPEM string) (jwt.SigningMethod, any, error) {
+	b := []byte(publicPEM)
+
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(b); err == nil {
+		return jwt.SigningMethodRS256, key, nil
+	}
+
+	if key, err := jwt.ParseECPublicKeyFromPEM(b); err == nil {
+		if err := checkCurve(key); err != nil {
+			return nil, nil, err
+		}
+		return jwt.SigningMethodES256, key, nil
+	}
+
+	if key, err := jwt.ParseEdPublicKeyFromPEM(b); err == nil {
+		return jwt.SigningMethodEdDSA, key, nil
+	}
+
+	return nil, nil, errors.New("unsupported public key, must be RSA, ECDSA P-256 or Ed25519")
+}
+
+// checkCurve makes sure the ECDSA key can be used with ES256.
+func checkCurve(key *ecdsa.PublicKey) error {
+	if key.Curve != elliptic.P256() {
+		return fmt.Errorf("unsupported curve %s, must be P-256", key.Curve.Params().Name)
+	}
+	return nil
+}
@@ -0,0 +1,441 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/halilylm/micro/business/core/user"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// oidcKeysTTL is how long the keys of the external issuer are used before
+// they are loaded again. A token with an unknown kid triggers a reload
+// sooner, at most once every keySetMinRefresh.
+const oidcKeysTTL = time.Hour
+
+// defaultGroupsClaim is the claim the groups of a user are read from when
+// OIDCConfig doesn't specify one.
+const defaultGroupsClaim = "groups"
+
+// OIDCConfig represents an external OpenID Connect issuer whose tokens are
+// accepted next to the ones issued by this service. Its keys are found
+// through the discovery document of the issuer. The groups of a user are
+// mapped onto roles with GroupRoles, DefaultRoles are granted when none of
+// the groups match. The roles are only applied to the users created for the
+// issuer, unless SyncLinked is set they aren't applied to local users that
+// were linked to it. An empty Issuer disables federation.
+type OIDCConfig struct {
+	Issuer       string
+	Audience     string
+	GroupsClaim  string
+	GroupRoles   map[string][]string
+	DefaultRoles []string
+	SyncLinked   bool
+	Client       *http.Client
+}
+
+// authenticateOIDC verifies a token of the external issuer and returns
+// claims for the local user linked to it, which is created on the first
+// login. The roles come from the groups in the token. Like a local token it
+// is refused when its id was revoked or the user is disabled; a token of an
+// issuer that doesn't set an id can't be revoked.
+func (a *Auth) authenticateOIDC(ctx context.Context, token string) (Claims, error) {
+	claims := jwt.MapClaims{}
+	tkn, _, err := a.parser.ParseUnverified(token, claims)
+	if err != nil {
+		return Claims{}, fmt.Errorf("parsing token: %w", err)
+	}
+
+	kid, ok := tkn.Header["kid"].(string)
+	if !ok {
+		return Claims{}, fmt.Errorf("kid missing from header")
+	}
+
+	pem, err := a.oidc.publicKey(ctx, kid)
+	if err != nil {
+		return Claims{}, fmt.Errorf("failed to fetch public key: %w", err)
+	}
+
+	method, _, err := verificationKey(pem)
+	if err != nil {
+		return Claims{}, fmt.Errorf("parsing public pem: %w", err)
+	}
+
+	// OPA can't verify EdDSA signatures and issuers don't use them.
+	if method == jwt.SigningMethodEdDSA {
+		return Claims{}, errors.New("EdDSA isn't supported for the external issuer")
+	}
+
+	if tkn.Method.Alg() != method.Alg() {
+		return Claims{}, fmt.Errorf("token alg %q doesn't match key alg %q", tkn.Method.Alg(), method.Alg())
+	}
+
+	input := map[string]any{
+		"Key":      pem,
+		"Token":    token,
+		"Alg":      method.Alg(),
+		"Issuer":   a.oidc.cfg.Issuer,
+		"Audience": a.oidc.cfg.Audience,
+	}
+
+	if err := a.opaPolicyEvaluation(ctx, RuleAuthenticateOIDC, input); err != nil {
+		return Claims{}, fmt.Errorf("authentication failed: %w", err)
+	}
+
+	eu, err := a.oidc.externalUser(claims)
+	if err != nil {
+		return Claims{}, fmt.Errorf("mapping identity: %w", err)
+	}
+
+	usr, err := a.user.Provision(ctx, eu)
+	if err != nil {
+		return Claims{}, fmt.Errorf("provisioning user: %w", err)
+	}
+
+	if !usr.Enabled {
+		return Claims{}, errors.New("user not enabled")
+	}
+
+	// The registered claims of the issuer are kept except for the subject,
+	// which becomes the local user.
+	var registered jwt.RegisteredClaims
+	if _, _, err := a.parser.ParseUnverified(token, &registered); err != nil {
+		return Claims{}, fmt.Errorf("parsing token: %w", err)
+	}
+	registered.Subject = usr.ID.String()
+
+	local := Claims{
+		RegisteredClaims: registered,
+		Roles:            usr.Roles,
+		AMR:              stringList(claims["amr"]),
+	}
+
+	// The token id of the issuer can be revoked here like the one of a
+	// token issued by this service.
+	revoked, err := a.isRevoked(ctx, local)
+	if err != nil {
+		return Claims{}, fmt.Errorf("checking revocation: %w", err)
+	}
+	if revoked {
+		return Claims{}, errors.New("token revoked")
+	}
+
+	return local, nil
+}
+
+// =============================================================================
+
+// oidcProvider loads and caches the keys of the external issuer.
+type oidcProvider struct {
+	log    *zap.SugaredLogger
+	cfg    OIDCConfig
+	client *http.Client
+
+	mu      sync.RWMutex
+	jwksURI string
+	keys    map[string]string
+	loaded  time.Time
+}
+
+// newOIDCProvider constructs a provider for the issuer. The issuer isn't
+// contacted until the first token arrives so the service can start while
+// it's unavailable.
+func newOIDCProvider(log *zap.SugaredLogger, cfg OIDCConfig) (*oidcProvider, error) {
+	if cfg.Audience == "" {
+		return nil, errors.New("oidc audience is required")
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = defaultGroupsClaim
+	}
+
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	p := oidcProvider{
+		log:    log,
+		cfg:    cfg,
+		client: client,
+	}
+
+	return &p, nil
+}
+
+// issued reports whether the token claims to come from the issuer. The
+// signature isn't checked here.
+func (p *oidcProvider) issued(parser *jwt.Parser, token string) bool {
+	var claims jwt.RegisteredClaims
+	if _, _, err := parser.ParseUnverified(token, &claims); err != nil {
+		return false
+	}
+	return claims.Issuer == p.cfg.Issuer
+}
+
+// publicKey returns the public pem of the issuer's key with the kid. A kid
+// that isn't known causes the keys to be loaded again since the issuer may
+// have rotated them.
+func (p *oidcProvider) publicKey(ctx context.Context, kid string) (string, error) {
+	if err := p.loadKeys(ctx, false); err != nil {
+		return "", err
+	}
+
+	p.mu.RLock()
+	pem, ok := p.keys[kid]
+	p.mu.RUnlock()
+
+	if ok {
+		return pem, nil
+	}
+
+	if err := p.loadKeys(ctx, true); err != nil {
+		return "", err
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	pem, ok = p.keys[kid]
+	if !ok {
+		return "", fmt.Errorf("kid %q is not known by the issuer", kid)
+	}
+	return pem, nil
+}
+
+// loadKeys loads the keys of the issuer once they're older than oidcKeysTTL,
+// or keySetMinRefresh when forced. If the keys can't be loaded the last
+// ones are kept.
+func (p *oidcProvider) loadKeys(ctx context.Context, force bool) error {
+	ttl := oidcKeysTTL
+	if force {
+		ttl = keySetMinRefresh
+	}
+
+	p.mu.RLock()
+	loaded, jwksURI, hasKeys := p.loaded, p.jwksURI, p.keys != nil
+	p.mu.RUnlock()
+
+	if time.Since(loaded) < ttl {
+		return nil
+	}
+
+	keys, jwksURI, err := p.fetchKeys(ctx, jwksURI)
+	if err == nil {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.jwksURI = jwksURI
+		p.keys = keys
+		p.loaded = time.Now()
+
+		return nil
+	}
+
+	if !hasKeys {
+		return fmt.Errorf("loading issuer keys: %w", err)
+	}
+	p.log.Errorw("auth", "status", "loading issuer keys", "issuer", p.cfg.Issuer, "ERROR", err)
+
+	// Back off until the next refresh instead of hitting the issuer on
+	// every request.
+	p.mu.Lock()
+	p.loaded = time.Now()
+	p.mu.Unlock()
+
+	return nil
+}
+
+// fetchKeys retrieves the key set of the issuer. The location of the key
+// set is looked up in the discovery document the first time, or again when
+// the key set can't be retrieved from where it was.
+func (p *oidcProvider) fetchKeys(ctx context.Context, jwksURI string) (map[string]string, string, error) {
+	if jwksURI != "" {
+		keys, err := p.fetchJWKS(ctx, jwksURI)
+		if err == nil {
+			return keys, jwksURI, nil
+		}
+		p.log.Errorw("auth", "status", "loading issuer keys", "jwks_uri", jwksURI, "ERROR", err)
+	}
+
+	jwksURI, err := p.discover(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keys, err := p.fetchJWKS(ctx, jwksURI)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return keys, jwksURI, nil
+}
+
+// discover reads the location of the key set from the discovery document
+// of the issuer.
+func (p *oidcProvider) discover(ctx context.Context) (string, error) {
+	var doc struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+
+	url := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.get(ctx, url, &doc); err != nil {
+		return "", fmt.Errorf("discovery: %w", err)
+	}
+
+	if doc.Issuer != p.cfg.Issuer {
+		return "", fmt.Errorf("discovery: issuer %q doesn't match %q", doc.Issuer, p.cfg.Issuer)
+	}
+	if doc.JWKSURI == "" {
+		return "", errors.New("discovery: jwks_uri missing")
+	}
+
+	return doc.JWKSURI, nil
+}
+
+// fetchJWKS retrieves the signing keys of the issuer as public pems by kid.
+// Keys that can't be used are left out.
+func (p *oidcProvider) fetchJWKS(ctx context.Context, jwksURI string) (map[string]string, error) {
+	var set JWKSet
+	if err := p.get(ctx, jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make(map[string]string, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.KeyID == "" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+
+		pem, err := jwk.PublicKeyPEM()
+		if err != nil {
+			p.log.Infow("auth", "status", "skipping issuer key", "kid", jwk.KeyID, "ERROR", err)
+			continue
+		}
+		keys[jwk.KeyID] = pem
+	}
+
+	return keys, nil
+}
+
+// get retrieves the JSON document at the url.
+func (p *oidcProvider) get(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d from %s", resp.StatusCode, url)
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(v); err != nil {
+		return fmt.Errorf("decoding %s: %w", url, err)
+	}
+
+	return nil
+}
+
+// externalUser reads what the verified claims assert about the user.
+func (p *oidcProvider) externalUser(claims jwt.MapClaims) (user.ExternalUser, error) {
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return user.ExternalUser{}, errors.New("sub claim missing")
+	}
+
+	email, _ := claims["email"].(string)
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		return user.ExternalUser{}, fmt.Errorf("email claim: %w", err)
+	}
+
+	name, _ := claims["name"].(string)
+	if name == "" {
+		name = addr.Address
+	}
+
+	// Some issuers send the flag as a string.
+	var verified bool
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		verified = v
+	case string:
+		verified = v == "true"
+	}
+
+	roles := p.roles(stringList(claims[p.cfg.GroupsClaim]))
+	if len(roles) == 0 {
+		return user.ExternalUser{}, errors.New("no roles are granted to the groups of the user")
+	}
+
+	eu := user.ExternalUser{
+		Issuer:        p.cfg.Issuer,
+		Subject:       subject,
+		Name:          name,
+		Email:         mail.Address{Address: addr.Address},
+		EmailVerified: verified,
+		Roles:         roles,
+		SyncLinked:    p.cfg.SyncLinked,
+	}
+
+	return eu, nil
+}
+
+// roles maps the groups onto the roles they grant.
+func (p *oidcProvider) roles(groups []string) []string {
+	set := make(map[string]bool)
+	for _, group := range groups {
+		for _, role := range p.cfg.GroupRoles[group] {
+			set[role] = true
+		}
+	}
+
+	if len(set) == 0 {
+		for _, role := range p.cfg.DefaultRoles {
+			set[role] = true
+		}
+	}
+
+	roles := make([]string, 0, len(set))
+	for role := range set {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	return roles
+}
+
+// stringList reads a claim that holds a list of strings or a single one.
+func stringList(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+
+	return nil
+}
@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/halilylm/micro/business/core/audit"
+	"github.com/halilylm/micro/business/core/revocation"
+	"github.com/halilylm/micro/business/core/user"
+	"github.com/halilylm/micro/foundation/oidcstub"
+	"net/mail"
+	"testing"
+	"time"
+)
+
+// TestAuthenticateOIDC runs tokens of the stub issuer through discovery, the
+// key set and the provisioning of local users.
+func TestAuthenticateOIDC(t *testing.T) {
+	const audience = "sales-api"
+
+	stub, err := oidcstub.Start("127.0.0.1:0", audience)
+	if err != nil {
+		t.Fatalf("starting issuer: %s", err)
+	}
+	defer stub.Close()
+
+	a := newTestAuth(t)
+	usrRepo := newOIDCUserRepo()
+	revRepo := oidcRevocationRepo{}
+	aud := audit.NewCore(oidcAuditRepo{})
+
+	a.user = user.NewCore(usrRepo, aud)
+	a.revoked = revocation.NewCore(aud, revRepo)
+	a.oidc, err = newOIDCProvider(a.log, OIDCConfig{
+		Issuer:     stub.Issuer(),
+		Audience:   audience,
+		GroupRoles: map[string][]string{"admins": {user.RoleAdmin}},
+	})
+	if err != nil {
+		t.Fatalf("constructing provider: %s", err)
+	}
+
+	// A local user with a verified email is linked, but keeps its roles.
+	linked := user.User{
+		ID:      uuid.New(),
+		Email:   mail.Address{Address: "linked@example.com"},
+		Roles:   []string{user.RoleUser},
+		Enabled: true,
+	}
+	usrRepo.users[linked.ID] = linked
+
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		claims  map[string]any
+		roles   []string
+		subject uuid.UUID
+		err     bool
+	}{
+		{
+			name:   "provisioned",
+			claims: map[string]any{"jti": "t1", "sub": "new", "email": "new@example.com", "email_verified": true, "groups": []string{"admins"}},
+			roles:  []string{user.RoleAdmin},
+		},
+		{
+			name:    "linked",
+			claims:  map[string]any{"jti": "t2", "sub": "local", "email": "linked@example.com", "email_verified": true, "groups": []string{"admins"}},
+			roles:   []string{user.RoleUser},
+			subject: linked.ID,
+		},
+		{
+			name:   "unverified email of a local user",
+			claims: map[string]any{"jti": "t3", "sub": "other", "email": "linked@example.com", "groups": []string{"admins"}},
+			err:    true,
+		},
+		{
+			name:   "no mapped group",
+			claims: map[string]any{"jti": "t4", "sub": "nogroup", "email": "nogroup@example.com", "email_verified": true, "groups": []string{"staff"}},
+			err:    true,
+		},
+		{
+			name:   "wrong audience",
+			claims: map[string]any{"jti": "t5", "sub": "new", "aud": "other", "email": "new@example.com", "email_verified": true, "groups": []string{"admins"}},
+			err:    true,
+		},
+		{
+			name:   "revoked",
+			claims: map[string]any{"jti": "revoked", "sub": "new", "email": "new@example.com", "email_verified": true, "groups": []string{"admins"}},
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		token, err := stub.Token(tt.claims)
+		if err != nil {
+			t.Fatalf("%s: signing token: %s", tt.name, err)
+		}
+
+		claims, err := a.Authenticate(ctx, "Bearer "+token)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: token was accepted", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+
+		if tt.subject != uuid.Nil && claims.Subject != tt.subject.String() {
+			t.Errorf("%s: got subject %s, want %s", tt.name, claims.Subject, tt.subject)
+		}
+		if !sameStrings(claims.Roles, tt.roles) {
+			t.Errorf("%s: got roles %v, want %v", tt.name, claims.Roles, tt.roles)
+		}
+	}
+
+	// The next login of the provisioned user finds it through the link and
+	// doesn't create another one.
+	token, err := stub.Token(map[string]any{"sub": "new", "email": "new@example.com", "email_verified": true, "groups": []string{"admins"}})
+	if err != nil {
+		t.Fatalf("signing token: %s", err)
+	}
+	if _, err := a.Authenticate(ctx, "Bearer "+token); err != nil {
+		t.Fatalf("second login: %s", err)
+	}
+	if n := len(usrRepo.users); n != 2 {
+		t.Fatalf("got %d users, want 2", n)
+	}
+
+	// A key the issuer rotated in is found by loading the key set again,
+	// though not more often than keySetMinRefresh.
+	if err := stub.Rotate(); err != nil {
+		t.Fatalf("rotating: %s", err)
+	}
+	token, err = stub.Token(map[string]any{"sub": "new", "email": "new@example.com", "email_verified": true, "groups": []string{"admins"}})
+	if err != nil {
+		t.Fatalf("signing token: %s", err)
+	}
+	if _, err := a.Authenticate(ctx, "Bearer "+token); err == nil {
+		t.Fatal("key set was loaded again within keySetMinRefresh")
+	}
+
+	a.oidc.mu.Lock()
+	a.oidc.loaded = time.Now().Add(-keySetMinRefresh)
+	a.oidc.mu.Unlock()
+
+	if _, err := a.Authenticate(ctx, "Bearer "+token); err != nil {
+		t.Fatalf("token of a rotated key: %s", err)
+	}
+
+	// A disabled user is refused.
+	for id, usr := range usrRepo.users {
+		usr.Enabled = false
+		usrRepo.users[id] = usr
+	}
+	if _, err := a.Authenticate(ctx, "Bearer "+token); err == nil {
+		t.Fatal("token of a disabled user was accepted")
+	}
+}
+
+// =============================================================================
+
+func sameStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// oidcUserRepo keeps users and their identities in memory.
+type oidcUserRepo struct {
+	user.Repository
+	users      map[uuid.UUID]user.User
+	identities map[string]user.Identity
+}
+
+func newOIDCUserRepo() *oidcUserRepo {
+	return &oidcUserRepo{
+		users:      make(map[uuid.UUID]user.User),
+		identities: make(map[string]user.Identity),
+	}
+}
+
+func (r *oidcUserRepo) WithinTran(ctx context.Context, fn func(ctx context.Context, r user.Repository) error) error {
+	return fn(ctx, r)
+}
+
+func (r *oidcUserRepo) Create(ctx context.Context, usr user.User) error {
+	r.users[usr.ID] = usr
+	return nil
+}
+
+func (r *oidcUserRepo) Update(ctx context.Context, usr user.User) error {
+	r.users[usr.ID] = usr
+	return nil
+}
+
+func (r *oidcUserRepo) QueryByID(ctx context.Context, userID uuid.UUID) (user.User, error) {
+	usr, ok := r.users[userID]
+	if !ok {
+		return user.User{}, user.ErrNotFound
+	}
+	return usr, nil
+}
+
+func (r *oidcUserRepo) QueryByEmail(ctx context.Context, email mail.Address) (user.User, error) {
+	for _, usr := range r.users {
+		if usr.Email.Address == email.Address {
+			return usr, nil
+		}
+	}
+	return user.User{}, user.ErrNotFound
+}
+
+func (r *oidcUserRepo) QueryIdentity(ctx context.Context, issuer string, subject string) (user.Identity, error) {
+	ident, ok := r.identities[issuer+" "+subject]
+	if !ok {
+		return user.Identity{}, user.ErrNotFound
+	}
+	return ident, nil
+}
+
+func (r *oidcUserRepo) CreateIdentity(ctx context.Context, ident user.Identity) error {
+	r.identities[ident.Issuer+" "+ident.Subject] = ident
+	return nil
+}
+
+// oidcRevocationRepo has the token with the id "revoked" revoked.
+type oidcRevocationRepo struct {
+	revocation.Repository
+}
+
+func (oidcRevocationRepo) QueryByTokenID(ctx context.Context, tokenID string) (revocation.Revocation, error) {
+	if tokenID != "revoked" {
+		return revocation.Revocation{}, revocation.ErrNotFound
+	}
+	return revocation.Revocation{TokenID: tokenID, DateRevoked: time.Now()}, nil
+}
+
+// oidcAuditRepo drops the entries it's given.
+type oidcAuditRepo struct {
+	audit.Repository
+}
+
+func (oidcAuditRepo) Create(ctx context.Context, entry audit.Entry) error {
+	return nil
+}
@@ -0,0 +1,275 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/loader"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/storage"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"sort"
+	"time"
+)
+
+// PolicyStatus describes the policy in use. LastError is set when the last
+// attempt to load new policies failed and the previous ones were kept.
+type PolicyStatus struct {
+	Revision   string    `json:"revision"`
+	Source     string    `json:"source"`
+	DateLoaded time.Time `json:"date_loaded"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// policySet is the compiled policy with the data document it's evaluated
+// against. It's replaced as a whole when new policies are loaded so an
+// evaluation never sees a mix of old and new rules.
+type policySet struct {
+	status      PolicyStatus
+	fingerprint string
+	store       storage.Store
+	queries     map[string]rego.PreparedEvalQuery
+}
+
+// PolicyStatus returns the status of the policy in use.
+func (a *Auth) PolicyStatus() PolicyStatus {
+	status := a.policy.Load().status
+
+	a.policyMu.Lock()
+	defer a.policyMu.Unlock()
+	if a.policyErr != nil {
+		status.LastError = a.policyErr.Error()
+	}
+
+	return status
+}
+
+// ReloadPolicy loads the policies and data from the configured path. They
+// are compiled and validated before replacing the ones in use, which are
+// kept if anything fails. Nothing happens when the content hasn't changed.
+func (a *Auth) ReloadPolicy() error {
+	if a.policyPath == "" {
+		return errors.New("no policy path configured")
+	}
+
+	err := func() error {
+		modules, data, revision, err := readBundle(a.policyPath)
+		if err != nil {
+			return err
+		}
+
+		fp, err := fingerprint(modules, data, revision)
+		if err != nil {
+			return err
+		}
+
+		if fp == a.policy.Load().fingerprint {
+			return nil
+		}
+
+		ps, err := newPolicySet(a.policyPath, revision, modules, data)
+		if err != nil {
+			return err
+		}
+
+		// The roles from the database are carried over so requests made
+		// before the next refresh aren't authorized with the default roles
+		// of the bundle.
+		if a.role != nil {
+			if err := copyRoles(a.policy.Load().store, ps.store); err != nil {
+				return err
+			}
+		}
+
+		a.policy.Store(ps)
+		a.log.Infow("auth", "status", "policy loaded", "source", ps.status.Source, "revision", ps.status.Revision)
+
+		// The roles are reloaded from the database into the new store soon
+		// after in case they changed.
+		a.InvalidatePolicyData()
+
+		return nil
+	}()
+
+	a.policyMu.Lock()
+	a.policyErr = err
+	a.policyMu.Unlock()
+
+	return err
+}
+
+// WatchPolicy reloads the policy from the configured path every interval
+// until the context is cancelled. Failures are logged and the last good
+// policy stays in use.
+func (a *Auth) WatchPolicy(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.ReloadPolicy(); err != nil {
+				a.log.Errorw("auth", "status", "reloading policy", "source", a.policyPath, "ERROR", err)
+			}
+		}
+	}
+}
+
+// =============================================================================
+
+// embeddedPolicySet compiles the policies built into the binary.
+func embeddedPolicySet() (*policySet, error) {
+	modules, data, _, err := readPolicy("")
+	if err != nil {
+		return nil, err
+	}
+
+	return newPolicySet("embedded", "", modules, data)
+}
+
+// readPolicy returns the embedded modules and data when the path is empty
+// and reads the bundle at the path otherwise.
+func readPolicy(path string) (map[string]string, map[string]any, string, error) {
+	if path != "" {
+		return readBundle(path)
+	}
+
+	modules := map[string]string{
+		"authentication.rego": opaAuthentication,
+		"authorization.rego":  opaAuthorization,
+	}
+
+	var data map[string]any
+	if err := json.Unmarshal(opaDefaultData, &data); err != nil {
+		return nil, nil, "", fmt.Errorf("parsing default policy data: %w", err)
+	}
+
+	return modules, data, "", nil
+}
+
+// readBundle loads the rego modules and data from a directory or a bundle
+// tarball. Without any data the default data document is used.
+func readBundle(path string) (map[string]string, map[string]any, string, error) {
+	b, err := loader.NewFileLoader().AsBundle(path)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("loading bundle: %w", err)
+	}
+
+	modules := make(map[string]string, len(b.Modules))
+	for _, m := range b.Modules {
+		modules[m.Path] = string(m.Raw)
+	}
+
+	data := b.Data
+	if len(data) == 0 {
+		if err := json.Unmarshal(opaDefaultData, &data); err != nil {
+			return nil, nil, "", fmt.Errorf("parsing default policy data: %w", err)
+		}
+	}
+
+	return modules, data, b.Manifest.Revision, nil
+}
+
+// newPolicySet compiles the modules and prepares a query for every rule the
+// service evaluates. A revision is derived from the content when the bundle
+// doesn't declare one.
+func newPolicySet(source string, revision string, modules map[string]string, data map[string]any) (*policySet, error) {
+	fp, err := fingerprint(modules, data, revision)
+	if err != nil {
+		return nil, err
+	}
+	if revision == "" {
+		revision = "sha256:" + fp[:12]
+	}
+
+	compiler, err := ast.CompileModules(modules)
+	if err != nil {
+		return nil, fmt.Errorf("compiling policy: %w", err)
+	}
+
+	store := inmem.NewFromObject(data)
+
+	rules := append(append([]string{}, authenticationRules...), authorizationRules...)
+	queries := make(map[string]rego.PreparedEvalQuery, len(rules))
+
+	for _, rule := range rules {
+		ref := ast.MustParseRef(fmt.Sprintf("data.%s.%s", opaPackage, rule))
+		if len(compiler.GetRulesExact(ref)) == 0 {
+			return nil, fmt.Errorf("rule %s is not defined", rule)
+		}
+
+		q, err := rego.New(
+			rego.Query(fmt.Sprintf("x = %s", ref)),
+			rego.Compiler(compiler),
+			rego.Store(store),
+		).PrepareForEval(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("preparing rule %s: %w", rule, err)
+		}
+
+		queries[rule] = q
+	}
+
+	ps := policySet{
+		status: PolicyStatus{
+			Revision:   revision,
+			Source:     source,
+			DateLoaded: time.Now().UTC(),
+		},
+		fingerprint: fp,
+		store:       store,
+		queries:     queries,
+	}
+
+	return &ps, nil
+}
+
+// copyRoles writes the roles of one store into another.
+func copyRoles(from storage.Store, to storage.Store) error {
+	ctx := context.Background()
+	path := storage.MustParsePath("/roles")
+
+	roles, err := storage.ReadOne(ctx, from, path)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("reading roles: %w", err)
+	}
+
+	if err := storage.WriteOne(ctx, to, storage.AddOp, path, roles); err != nil {
+		return fmt.Errorf("writing roles: %w", err)
+	}
+
+	return nil
+}
+
+// fingerprint hashes the content of a policy to detect changes.
+func fingerprint(modules map[string]string, data map[string]any, revision string) (string, error) {
+	paths := make([]string, 0, len(modules))
+	for path := range modules {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "revision:%s\n", revision)
+	for _, path := range paths {
+		fmt.Fprintf(h, "module:%s:%d\n%s\n", path, len(modules[path]), modules[path])
+	}
+
+	// Map keys are sorted when encoded so the same data hashes the same.
+	b, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("encoding policy data: %w", err)
+	}
+	h.Write(b)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
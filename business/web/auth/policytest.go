@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/ghodss/yaml"
+	"github.com/golang-jwt/jwt/v4"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PolicyCase is an expectation on the decision of a rule for an input.
+// When claims are given a token is signed with them and set in the input
+// as Token, Key and Alg, so authentication rules can be exercised without
+// real keys. The iat and exp claims are filled in unless they are set.
+type PolicyCase struct {
+	Name   string         `json:"name"`
+	Rule   string         `json:"rule"`
+	Input  map[string]any `json:"input"`
+	Claims map[string]any `json:"claims"`
+	Allow  bool           `json:"allow"`
+}
+
+// PolicySuite is the content of a fixture file. When data is given it
+// replaces the data document of the policy for the cases of the file.
+type PolicySuite struct {
+	File  string         `json:"-"`
+	Data  map[string]any `json:"data"`
+	Cases []PolicyCase   `json:"cases"`
+}
+
+// PolicyResult is the outcome of a case. Err is set when no decision could
+// be made, which fails the case whatever was expected.
+type PolicyResult struct {
+	File    string
+	Case    PolicyCase
+	Allowed bool
+	Err     error
+}
+
+// Passed reports whether the rule decided as the case expected.
+func (r PolicyResult) Passed() bool {
+	return r.Err == nil && r.Allowed == r.Case.Allow
+}
+
+// RuleCoverage counts the cases expecting a rule to allow and to deny.
+type RuleCoverage struct {
+	Rule  string
+	Allow int
+	Deny  int
+}
+
+// Covered reports whether both decisions of the rule are tested.
+func (c RuleCoverage) Covered() bool {
+	return c.Allow > 0 && c.Deny > 0
+}
+
+// PolicyReport is the outcome of running the suites against a policy.
+// Coverage has an entry for every rule the service evaluates.
+type PolicyReport struct {
+	Source   string
+	Revision string
+	Results  []PolicyResult
+	Coverage []RuleCoverage
+}
+
+// Failed returns the results of the cases that didn't pass.
+func (r PolicyReport) Failed() []PolicyResult {
+	var failed []PolicyResult
+	for _, res := range r.Results {
+		if !res.Passed() {
+			failed = append(failed, res)
+		}
+	}
+
+	return failed
+}
+
+// LoadPolicySuites reads a fixture file or every yaml and json file of a
+// directory. Unknown fields are rejected so a typo can't silently turn an
+// expectation into a default.
+func LoadPolicySuites(path string) ([]PolicySuite, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading fixtures: %w", err)
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading fixtures: %w", err)
+		}
+
+		files = files[:0]
+		for _, entry := range entries {
+			switch filepath.Ext(entry.Name()) {
+			case ".yaml", ".yml", ".json":
+				if !entry.IsDir() {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+		sort.Strings(files)
+	}
+
+	suites := make([]PolicySuite, 0, len(files))
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading fixture: %w", err)
+		}
+
+		// Json is yaml as well so every file goes through the conversion.
+		js, err := yaml.YAMLToJSON(b)
+		if err != nil {
+			return nil, fmt.Errorf("parsing fixture %s: %w", file, err)
+		}
+
+		var suite PolicySuite
+		dec := json.NewDecoder(bytes.NewReader(js))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&suite); err != nil {
+			return nil, fmt.Errorf("parsing fixture %s: %w", file, err)
+		}
+		suite.File = file
+
+		suites = append(suites, suite)
+	}
+
+	return suites, nil
+}
+
+// RunPolicyTests evaluates the cases of the suites against the policy at
+// the path, or the embedded policy when the path is empty. The rules are
+// prepared and evaluated the same way as for the requests the service
+// handles.
+func RunPolicyTests(ctx context.Context, policyPath string, suites []PolicySuite) (PolicyReport, error) {
+	source := policyPath
+	modules, data, revision, err := readPolicy(policyPath)
+	if err != nil {
+		return PolicyReport{}, err
+	}
+	if source == "" {
+		source = "embedded"
+	}
+
+	ps, err := newPolicySet(source, revision, modules, data)
+	if err != nil {
+		return PolicyReport{}, err
+	}
+
+	signer, err := newCaseSigner()
+	if err != nil {
+		return PolicyReport{}, err
+	}
+
+	rules := append(append([]string{}, authenticationRules...), authorizationRules...)
+	coverage := make(map[string]*RuleCoverage, len(rules))
+	for _, rule := range rules {
+		coverage[rule] = &RuleCoverage{Rule: rule}
+	}
+
+	report := PolicyReport{
+		Source:   ps.status.Source,
+		Revision: ps.status.Revision,
+	}
+
+	for _, suite := range suites {
+		suitePS := ps
+		if suite.Data != nil {
+			if suitePS, err = newPolicySet(source, revision, modules, suite.Data); err != nil {
+				return PolicyReport{}, fmt.Errorf("fixture %s: %w", suite.File, err)
+			}
+		}
+
+		for i, c := range suite.Cases {
+			if c.Name == "" {
+				c.Name = fmt.Sprintf("case %d", i+1)
+			}
+
+			res := PolicyResult{
+				File: suite.File,
+				Case: c,
+			}
+
+			input, err := signer.input(c)
+			if err != nil {
+				res.Err = err
+			} else {
+				res.Allowed, res.Err = evaluate(ctx, suitePS, c.Rule, input)
+			}
+			report.Results = append(report.Results, res)
+
+			if cov, ok := coverage[c.Rule]; ok {
+				if c.Allow {
+					cov.Allow++
+				} else {
+					cov.Deny++
+				}
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		report.Coverage = append(report.Coverage, *coverage[rule])
+	}
+
+	return report, nil
+}
+
+// =============================================================================
+
+// caseSigner signs the tokens of the cases with a key generated for the run.
+type caseSigner struct {
+	private   *rsa.PrivateKey
+	publicPEM string
+}
+
+func newCaseSigner() (*caseSigner, error) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("generating key: %w", err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling public key: %w", err)
+	}
+
+	var b strings.Builder
+	if err := pem.Encode(&b, &pem.Block{Type: "PUBLIC KEY", Bytes: der}); err != nil {
+		return nil, fmt.Errorf("encoding public key: %w", err)
+	}
+
+	cs := caseSigner{
+		private:   private,
+		publicPEM: b.String(),
+	}
+
+	return &cs, nil
+}
+
+// input returns the input of the case with the token signed from its claims.
+func (cs *caseSigner) input(c PolicyCase) (map[string]any, error) {
+	input := make(map[string]any, len(c.Input)+3)
+	for k, v := range c.Input {
+		input[k] = v
+	}
+
+	if c.Claims == nil {
+		return input, nil
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for k, v := range c.Claims {
+		claims[k] = v
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(cs.private)
+	if err != nil {
+		return nil, fmt.Errorf("signing token: %w", err)
+	}
+
+	input["Token"] = token
+	input["Key"] = cs.publicPEM
+	input["Alg"] = "RS256"
+
+	return input, nil
+}
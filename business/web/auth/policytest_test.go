@@ -0,0 +1,33 @@
+package auth_test
+
+import (
+	"context"
+	"github.com/halilylm/micro/business/web/auth"
+	"testing"
+)
+
+// TestPolicyFixtures runs the fixtures of the embedded policy, the same ones
+// the policy test command of sales-admin runs.
+func TestPolicyFixtures(t *testing.T) {
+	suites, err := auth.LoadPolicySuites("rego/testdata")
+	if err != nil {
+		t.Fatalf("loading fixtures: %s", err)
+	}
+
+	report, err := auth.RunPolicyTests(context.Background(), "", suites)
+	if err != nil {
+		t.Fatalf("running fixtures: %s", err)
+	}
+
+	if len(report.Results) == 0 {
+		t.Fatal("no cases were run")
+	}
+
+	for _, res := range report.Failed() {
+		if res.Err != nil {
+			t.Errorf("%s: %s: rule %s: %s", res.File, res.Case.Name, res.Case.Rule, res.Err)
+			continue
+		}
+		t.Errorf("%s: %s: rule %s allowed %v, expected %v", res.File, res.Case.Name, res.Case.Rule, res.Allowed, res.Case.Allow)
+	}
+}
@@ -2,6 +2,8 @@ package micro.rego
 
 default auth = false
 
+default authOIDC = false
+
 # This function decodes and verifies the JWT, it also makes sure that it hasn't expired etc.
 auth {
     jwt_valid
@@ -11,10 +13,50 @@ jwt_valid := valid {
     [valid, header, payload] := decoded_jwt
 }
 
+# The algorithm follows the type of the key that signed the token.
 decoded_jwt := [valid, header, payload] {
+    input.Alg != "EdDSA"
     [valid, header, payload] = io.jwt.decode_verify(input.Token, {
         "cert": input.Key,
-        "alg": "RS256",
-        "iss": "micro app",
+        "alg": input.Alg,
+        "iss": "micro",
+    })
+}
+
+# EdDSA signatures can't be verified here, the caller does it and only the
+# claims are checked.
+decoded_jwt := [valid, header, payload] {
+    input.Alg == "EdDSA"
+    [header, payload, _] := io.jwt.decode(input.Token)
+    valid := claims_valid(payload)
+}
+
+default claims_valid(_) = false
+
+claims_valid(payload) {
+    input.SignatureVerified == true
+    payload.iss == "micro"
+    now := time.now_ns() / 1000000000
+    not expired(payload, now)
+    not premature(payload, now)
+}
+
+# Tokens of the external OpenID Connect issuer are checked against its
+# issuer and our client id.
+authOIDC {
+    [valid, _, _] := io.jwt.decode_verify(input.Token, {
+        "cert": input.Key,
+        "alg": input.Alg,
+        "iss": input.Issuer,
+        "aud": input.Audience,
     })
-}
\ No newline at end of file
+    valid
+}
+
+expired(payload, now) {
+    payload.exp <= now
+}
+
+premature(payload, now) {
+    payload.nbf > now
+}
@@ -3,25 +3,93 @@ package micro.rego
 default allowAny = false
 default allowOnlyUser = false
 default allowOnlyAdmin = false
+default allowOnlyAdminWithMFA = false
+default allowPermission = false
+default allowOwnerOrAdmin = false
+default allowScope = false
+default allowImpersonated = false
+default allowImpersonate = false
 
-roleUser := "USER"
-roleAdmin := "ADMIN"
-roleAll := {roleAdmin, roleUser}
+# The roles and the permissions they grant are not defined here. They are
+# provided as the data document {"roles": {<name>: {"permissions": [...]}}}
+# which is loaded from the database.
+roles_from_claims := {role | role := input.Roles[_]; data.roles[role]}
+
+permissions_from_claims := {perm | role := roles_from_claims[_]; perm := data.roles[role].permissions[_]}
 
 allowAny {
-    roles_from_claims := {role | role := input.Roles[_]}
-    input_role_is_in_claim := roleAll & roles_from_claims
-    count(input_role_is_in_claim) > 0
+    count(roles_from_claims) > 0
 }
 
 allowOnlyUser {
-    roles_from_claims := {role | role := input.Roles[_]}
-    input_role_is_in_claim := {roleUser} & roles_from_claims
-    count(input_role_is_in_claim) > 0
+    permissions_from_claims["user"]
 }
 
 allowOnlyAdmin {
-    roles_from_claims := {role | role := input.Roles[_]}
-    input_role_is_in_claim := {roleAdmin} & roles_from_claims
-    count(input_role_is_in_claim) > 0
-}
\ No newline at end of file
+    permissions_from_claims["admin"]
+}
+
+allowOnlyAdminWithMFA {
+    allowOnlyAdmin
+    amr_from_claims := {method | method := input.AMR[_]}
+    amr_from_claims["mfa"]
+}
+
+allowPermission {
+    permissions_from_claims[input.Permission]
+}
+
+# The resource describes what the action is performed on with its Type,
+# OwnerID and Action. Only the owner is checked here but policies loaded
+# from outside can decide on the type and action as well.
+is_owner {
+    input.Resource.OwnerID != ""
+    input.Resource.OwnerID == input.Subject
+}
+
+allowOwnerOrAdmin {
+    is_owner
+}
+
+allowOwnerOrAdmin {
+    allowOnlyAdmin
+}
+
+# A token without scopes has the full power of its roles. Otherwise one of
+# its scopes must be the one the route requires, a write scope grants
+# reading the same resource as well.
+allowScope {
+    count(input.Scopes) == 0
+}
+
+allowScope {
+    input.Scopes[_] == input.Scope
+}
+
+allowScope {
+    parts := split(input.Scope, ":")
+    parts[1] == "read"
+    input.Scopes[_] == concat(":", [parts[0], "write"])
+}
+
+# A token impersonating a user carries the admin in input.Actor. It can do
+# what the user can except for the scopes listed in the data document as
+# {"impersonation": {"deny_scopes": [...]}}.
+allowImpersonated {
+    input.Actor != ""
+    not impersonation_denied
+}
+
+impersonation_denied {
+    data.impersonation.deny_scopes[_] == input.Scope
+}
+
+# Starting an impersonation is only allowed when the roles of the user in
+# input.TargetRoles grant no permission the roles of the actor don't, so
+# it can't be used to gain rights.
+target_permissions := {perm | role := input.TargetRoles[_]; data.roles[role]; perm := data.roles[role].permissions[_]}
+
+allowImpersonate {
+    permissions_from_claims["impersonate"]
+    count(target_permissions - permissions_from_claims) == 0
+}
@@ -0,0 +1,28 @@
+package auth
+
+// Set of resource types passed to the policy.
+const (
+	ResourceUser       = "user"
+	ResourceProduct    = "product"
+	ResourceAPIKey     = "apikey"
+	ResourcePrivacyJob = "privacy_job"
+)
+
+// Set of actions passed to the policy.
+const (
+	ActionCreate = "create"
+	ActionRead   = "read"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	ActionExport = "export"
+	ActionErase  = "erase"
+)
+
+// Resource describes what an action is performed on so the policy can
+// decide based on ownership. The zero value is used for rules that only
+// look at the claims.
+type Resource struct {
+	Type    string
+	OwnerID string
+	Action  string
+}
@@ -6,10 +6,23 @@ import (
 
 // These the current set of rules we have for auth.
 const (
-	RuleAuthenticate = "auth"
-	RuleAny          = "allowAny"
-	RuleAdminOnly    = "allowOnlyAdmin"
-	RuleUserOnly     = "allowOnlyUser"
+	RuleAuthenticate     = "auth"
+	RuleAuthenticateOIDC = "authOIDC"
+	RuleAny              = "allowAny"
+	RuleAdminOnly        = "allowOnlyAdmin"
+	RuleAdminOnlyMFA     = "allowOnlyAdminWithMFA"
+	RuleUserOnly         = "allowOnlyUser"
+	RulePermission       = "allowPermission"
+	RuleOwnerOrAdmin     = "allowOwnerOrAdmin"
+	RuleScope            = "allowScope"
+	RuleImpersonated     = "allowImpersonated"
+	RuleImpersonate      = "allowImpersonate"
+)
+
+// The rules are prepared once for each of the policies they're defined in.
+var (
+	authenticationRules = []string{RuleAuthenticate, RuleAuthenticateOIDC}
+	authorizationRules  = []string{RuleAny, RuleAdminOnly, RuleAdminOnlyMFA, RuleUserOnly, RulePermission, RuleOwnerOrAdmin, RuleScope, RuleImpersonated, RuleImpersonate}
 )
 
 // Package name of our rego code.
@@ -24,4 +37,9 @@ var (
 
 	//go:embed rego/authorization.rego
 	opaAuthorization string
+
+	// The data document used for authorization when roles can't be loaded
+	// from the database.
+	//go:embed rego/data.json
+	opaDefaultData []byte
 )
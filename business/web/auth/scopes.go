@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Set of scopes a token or api key can be limited to. A write scope grants
+// reading the same resource as well. A token without scopes has the full
+// power of the user's roles.
+const (
+	ScopeAccountWrite  = "account:write"
+	ScopeAPIKeysRead   = "apikeys:read"
+	ScopeAPIKeysWrite  = "apikeys:write"
+	ScopeAuditRead     = "audit:read"
+	ScopePrivacyRead   = "privacy:read"
+	ScopePrivacyWrite  = "privacy:write"
+	ScopeProductsRead  = "products:read"
+	ScopeProductsWrite = "products:write"
+	ScopeRolesRead     = "roles:read"
+	ScopeRolesWrite    = "roles:write"
+	ScopeTokensRead    = "tokens:read"
+	ScopeUsersRead     = "users:read"
+	ScopeUsersWrite    = "users:write"
+)
+
+// scopes is the set of known scopes.
+var scopes = map[string]bool{
+	ScopeAccountWrite:  true,
+	ScopeAPIKeysRead:   true,
+	ScopeAPIKeysWrite:  true,
+	ScopeAuditRead:     true,
+	ScopePrivacyRead:   true,
+	ScopePrivacyWrite:  true,
+	ScopeProductsRead:  true,
+	ScopeProductsWrite: true,
+	ScopeRolesRead:     true,
+	ScopeRolesWrite:    true,
+	ScopeTokensRead:    true,
+	ScopeUsersRead:     true,
+	ScopeUsersWrite:    true,
+}
+
+// ParseScopes parses a space separated list of scopes like the scope
+// parameter of OAuth 2.0 (RFC 6749).
+func ParseScopes(s string) ([]string, error) {
+	list := strings.Fields(s)
+	if err := CheckScopes(list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// CheckScopes makes sure every scope in the list is known.
+func CheckScopes(list []string) error {
+	for _, scope := range list {
+		if !scopes[scope] {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return nil
+}
+
+// GrantsScopes reports whether a credential with the granted scopes can
+// hand out one with the requested scopes, so a limited token can't be used
+// to create a more powerful api key. An empty list means every scope.
+func GrantsScopes(granted []string, requested []string) bool {
+	if len(granted) == 0 {
+		return true
+	}
+	if len(requested) == 0 {
+		return false
+	}
+
+	set := make(map[string]bool, len(granted))
+	for _, scope := range granted {
+		set[scope] = true
+		if strings.HasSuffix(scope, ":write") {
+			set[strings.TrimSuffix(scope, ":write")+":read"] = true
+		}
+	}
+
+	for _, scope := range requested {
+		if !set[scope] {
+			return false
+		}
+	}
+	return true
+}
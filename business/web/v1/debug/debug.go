@@ -2,7 +2,9 @@
 package debug
 
 import (
+	"github.com/halilylm/micro/business/web/auth"
 	"github.com/halilylm/micro/business/web/v1/debug/checkgrp"
+	"github.com/halilylm/micro/business/web/v1/debug/policygrp"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 	"net/http"
@@ -30,7 +32,7 @@ func StandardLibraryMux() *http.ServeMux {
 // the DefaultServerMux. Using the DefaultServerMux would be a security
 // risk since a dependency could inject a handler into our service
 // without us knowing it.
-func Mux(build string, log *zap.SugaredLogger, db *sqlx.DB) http.Handler {
+func Mux(build string, log *zap.SugaredLogger, db *sqlx.DB, a *auth.Auth) http.Handler {
 	mux := StandardLibraryMux()
 
 	cgh := checkgrp.Handlers{
@@ -42,5 +44,12 @@ func Mux(build string, log *zap.SugaredLogger, db *sqlx.DB) http.Handler {
 	mux.HandleFunc("/debug/readiness", cgh.Readiness)
 	mux.HandleFunc("/debug/liveness", cgh.Liveness)
 
+	pgh := policygrp.Handlers{
+		Log:  log,
+		Auth: a,
+	}
+
+	mux.HandleFunc("/debug/policy", pgh.Status)
+
 	return mux
 }
@@ -0,0 +1,34 @@
+// Package policygrp maintains the group of handlers for inspecting the
+// authorization policy.
+package policygrp
+
+import (
+	"encoding/json"
+	"github.com/halilylm/micro/business/web/auth"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// Handlers manages the set of policy endpoints.
+type Handlers struct {
+	Log  *zap.SugaredLogger
+	Auth *auth.Auth
+}
+
+// Status returns the revision and source of the policy in use and the
+// error of the last failed reload, if any.
+func (h Handlers) Status(w http.ResponseWriter, r *http.Request) {
+	jsonData, err := json.Marshal(h.Auth.PolicyStatus())
+	if err != nil {
+		h.Log.Errorw("policy", "ERROR", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(jsonData); err != nil {
+		h.Log.Errorw("policy", "ERROR", err)
+	}
+}
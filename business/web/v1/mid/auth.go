@@ -2,20 +2,54 @@ package mid
 
 import (
 	"context"
+	"github.com/halilylm/micro/business/core/audit"
 	"github.com/halilylm/micro/business/web/auth"
 	"github.com/halilylm/micro/foundation/web"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 	"net/http"
+	"strings"
 )
 
-// Authenticate validates a JWT from the `Authorization` header.
-func Authenticate(a *auth.Auth) web.Middleware {
+// Authenticate validates a JWT or an api key from the `Authorization` header.
+// Requests made with an impersonation token are flagged on the span and
+// mutations made with it are logged and audited with the admin as actor.
+func Authenticate(log *zap.SugaredLogger, a *auth.Auth) web.Middleware {
 	m := func(handler web.Handler) web.Handler {
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-			claims, err := a.Authenticate(ctx, r.Header.Get("authorization"))
+			authorization := r.Header.Get("authorization")
+
+			var claims auth.Claims
+			var err error
+			switch {
+			case strings.HasPrefix(authorization, "ApiKey "):
+				claims, err = a.AuthenticateAPIKey(ctx, authorization)
+			default:
+				claims, err = a.Authenticate(ctx, authorization)
+			}
 			if err != nil {
 				return auth.NewAuthError("authenticate: failed: %s", err)
 			}
 			ctx = auth.SetClaims(ctx, claims)
+			ctx = audit.SetActor(ctx, claims.Subject)
+
+			if claims.Impersonated() {
+				trace.SpanFromContext(ctx).SetAttributes(
+					attribute.Bool("impersonated", true),
+					attribute.String("actor", claims.Act.Subject),
+				)
+
+				ctx = audit.SetActor(ctx, claims.Act.Subject)
+				ctx = audit.SetOnBehalfOf(ctx, claims.Subject)
+
+				switch r.Method {
+				case http.MethodGet, http.MethodHead, http.MethodOptions:
+				default:
+					log.Infow("impersonation", "trace_id", web.GetTraceID(ctx), "actor", claims.Act.Subject, "subject", claims.Subject,
+						"method", r.Method, "path", r.URL.Path)
+				}
+			}
 
 			return handler(ctx, w, r)
 		}
@@ -33,7 +67,7 @@ func Authorize(a *auth.Auth, rule string) web.Middleware {
 			if claims.Subject == "" {
 				return auth.NewAuthError("authorize: you are not authorized for that action, no claims")
 			}
-			if err := a.Authorize(ctx, claims, rule); err != nil {
+			if err := a.Authorize(ctx, claims, rule, auth.Resource{}); err != nil {
 				return auth.NewAuthError("authorize: you are not authorized for that action, claims[%v] rule[%v]: %s", claims.Roles, rule, err)
 			}
 			return handler(ctx, w, r)
@@ -42,3 +76,41 @@ func Authorize(a *auth.Auth, rule string) web.Middleware {
 	}
 	return m
 }
+
+// AuthorizePermission validates that one of the roles of an authenticated
+// user grants the specified permission.
+func AuthorizePermission(a *auth.Auth, permission string) web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			claims := auth.GetClaims(ctx)
+			if claims.Subject == "" {
+				return auth.NewAuthError("authorize: you are not authorized for that action, no claims")
+			}
+			if err := a.AuthorizePermission(ctx, claims, permission); err != nil {
+				return auth.NewAuthError("authorize: you are not authorized for that action, claims[%v] permission[%v]: %s", claims.Roles, permission, err)
+			}
+			return handler(ctx, w, r)
+		}
+		return h
+	}
+	return m
+}
+
+// RequireScope validates that the scopes of the token allow the specified
+// scope. Tokens without scopes are only limited by their roles.
+func RequireScope(a *auth.Auth, scope string) web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			claims := auth.GetClaims(ctx)
+			if claims.Subject == "" {
+				return auth.NewAuthError("authorize: you are not authorized for that action, no claims")
+			}
+			if err := a.AuthorizeScope(ctx, claims, scope); err != nil {
+				return auth.NewAuthError("authorize: you are not authorized for that action, scopes[%v] scope[%v]: %s", claims.Scopes, scope, err)
+			}
+			return handler(ctx, w, r)
+		}
+		return h
+	}
+	return m
+}
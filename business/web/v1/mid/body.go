@@ -0,0 +1,22 @@
+package mid
+
+import (
+	"context"
+	"github.com/halilylm/micro/foundation/web"
+	"net/http"
+)
+
+// BodyLimit caps the size of request bodies. Reading past the limit fails
+// and web.Decode reports it as web.ErrBodyTooLarge.
+func BodyLimit(maxBytes int64) web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			if maxBytes > 0 && r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			}
+			return handler(ctx, w, r)
+		}
+		return h
+	}
+	return m
+}
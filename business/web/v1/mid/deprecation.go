@@ -0,0 +1,29 @@
+package mid
+
+import (
+	"context"
+	"fmt"
+	"github.com/halilylm/micro/foundation/web"
+	"net/http"
+	"time"
+)
+
+// Deprecation marks the responses of a deprecated api with the date it was
+// deprecated (RFC 9745) and, when known, the date it stops being served
+// (RFC 8594). The successor is linked so clients can find what replaces it.
+func Deprecation(deprecated time.Time, sunset time.Time, successor string) web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", deprecated.Unix()))
+			if !sunset.IsZero() {
+				w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+			if successor != "" {
+				w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+			}
+			return handler(ctx, w, r)
+		}
+		return h
+	}
+	return m
+}
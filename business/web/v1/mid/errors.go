@@ -2,6 +2,7 @@ package mid
 
 import (
 	"context"
+	"errors"
 	"github.com/halilylm/micro/business/sys/validate"
 	"github.com/halilylm/micro/business/web/auth"
 	v1 "github.com/halilylm/micro/business/web/v1"
@@ -23,6 +24,12 @@ func Errors(log *zap.SugaredLogger) web.Middleware {
 				span.RecordError(err)
 				span.End()
 
+				// A stream that failed after it started has sent its status
+				// already, there is nothing left to respond with.
+				if web.IsStreamAborted(err) {
+					return nil
+				}
+
 				var er v1.ErrorResponse
 				var status int
 				switch {
@@ -33,12 +40,44 @@ func Errors(log *zap.SugaredLogger) web.Middleware {
 						Fields: fieldErrors.Fields(),
 					}
 					status = http.StatusBadRequest
+				case web.IsDecodeError(err):
+					decodeErr := web.GetDecodeError(err)
+					if decodeErr.Field == "" {
+						er = v1.ErrorResponse{
+							Error: decodeErr.Error(),
+						}
+					} else {
+						fieldErrors := validate.FieldErrors{{
+							Field: decodeErr.Field,
+							Error: decodeErr.Err.Error(),
+						}}
+						er = v1.ErrorResponse{
+							Error:  "data validation error",
+							Fields: fieldErrors.Fields(),
+						}
+					}
+					status = http.StatusBadRequest
+				case errors.Is(err, web.ErrBodyTooLarge):
+					er = v1.ErrorResponse{
+						Error: web.ErrBodyTooLarge.Error(),
+					}
+					status = http.StatusRequestEntityTooLarge
 				case v1.IsRequestError(err):
 					reqErr := v1.GetRequestError(err)
 					er = v1.ErrorResponse{
 						Error: reqErr.Error(),
 					}
 					status = reqErr.Status
+				case errors.Is(err, web.ErrNotAcceptable):
+					er = v1.ErrorResponse{
+						Error: err.Error(),
+					}
+					status = http.StatusNotAcceptable
+				case errors.Is(err, web.ErrUnsupportedMediaType):
+					er = v1.ErrorResponse{
+						Error: err.Error(),
+					}
+					status = http.StatusUnsupportedMediaType
 				case auth.IsAuthError(err):
 					er = v1.ErrorResponse{
 						Error: http.StatusText(http.StatusUnauthorized),
@@ -0,0 +1,172 @@
+// Package v2 represents types used by the web application for v2.
+package v2
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/halilylm/micro/business/data/order"
+	"github.com/halilylm/micro/foundation/web"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// Limits on the number of items in a page.
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Set of errors for cursors that can't be used.
+var (
+	ErrInvalidCursor  = errors.New("invalid cursor")
+	ErrCursorMismatch = errors.New("cursor was issued for different query parameters")
+)
+
+// Response is the envelope every successful v2 response is sent in. A
+// failure is sent as a v1.ErrorResponse, which has an error key in place
+// of data.
+type Response struct {
+	Data any   `json:"data"`
+	Meta *Meta `json:"meta,omitempty"`
+}
+
+// Meta describes the page of items in a response. NextCursor is empty on
+// the last page.
+type Meta struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Respond sends the data in the response envelope.
+func Respond(ctx context.Context, w http.ResponseWriter, data any, statusCode int) error {
+	if statusCode == http.StatusNoContent {
+		return web.Respond(ctx, w, nil, statusCode)
+	}
+
+	return web.Respond(ctx, w, Response{Data: data}, statusCode)
+}
+
+// RespondPage sends a page of items in the response envelope with the
+// cursor of the page after it, which starts after the sort key of the last
+// item. A page that isn't full is the last one.
+func RespondPage[T any](ctx context.Context, w http.ResponseWriter, items []T, page Page, sortKey func(T) order.Key) error {
+	if items == nil {
+		items = []T{}
+	}
+
+	meta := Meta{
+		Limit: page.Limit,
+	}
+	if len(items) == page.Limit {
+		meta.NextCursor = page.next(sortKey(items[len(items)-1])).Cursor()
+	}
+
+	return web.Respond(ctx, w, Response{Data: items, Meta: &meta}, http.StatusOK)
+}
+
+// =============================================================================
+
+// Page is the position of a page of items in a query. It's sent to clients
+// as an opaque cursor so how it's kept can change without breaking them.
+// The cursor holds a hash of the filter and order query parameters it was
+// issued for, along with the sort key of the last item of the page before.
+type Page struct {
+	Params string     `json:"p"`
+	Limit  int        `json:"l"`
+	After  *order.Key `json:"a,omitempty"`
+}
+
+// GetPage reads the page from the cursor query parameter, or starts at the
+// first page with the limit query parameter when there is no cursor. A
+// cursor is only valid with the query parameters it was issued for.
+func GetPage(r *http.Request) (Page, error) {
+	values := r.URL.Query()
+	params := paramsHash(values)
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		page, err := parseCursor(cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		if page.Params != params {
+			return Page{}, ErrCursorMismatch
+		}
+		return page, nil
+	}
+
+	page := Page{
+		Params: params,
+		Limit:  DefaultLimit,
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > MaxLimit {
+			return Page{}, errors.New("limit must be a number between 1 and " + strconv.Itoa(MaxLimit))
+		}
+		page.Limit = n
+	}
+
+	return page, nil
+}
+
+// Cursor returns the cursor of the page.
+func (p Page) Cursor() string {
+	data, _ := json.Marshal(p)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// next returns the page after the item with the sort key.
+func (p Page) next(key order.Key) Page {
+	return Page{
+		Params: p.Params,
+		Limit:  p.Limit,
+		After:  &key,
+	}
+}
+
+// parseCursor reads the page from a cursor.
+func parseCursor(cursor string) (Page, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Page{}, ErrInvalidCursor
+	}
+
+	var p Page
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Page{}, ErrInvalidCursor
+	}
+
+	if p.Params == "" || p.After == nil || p.Limit < 1 || p.Limit > MaxLimit {
+		return Page{}, ErrInvalidCursor
+	}
+
+	return p, nil
+}
+
+// paramsHash hashes the query parameters other than the cursor, in a stable
+// order, so a cursor can be matched with the query it was issued for.
+func paramsHash(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if key != "cursor" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, key := range keys {
+		for _, v := range values[key] {
+			fmt.Fprintf(h, "%q=%q&", key, v)
+		}
+	}
+
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:12])
+}
@@ -0,0 +1,37 @@
+// Package cache provides support for caching values in memory or in a
+// server that speaks the Redis protocol.
+package cache
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"time"
+)
+
+// ErrNotFound is returned when a key isn't in the cache or has expired.
+var ErrNotFound = errors.New("cache: key not found")
+
+// Backend is the behavior a cache implementation has to provide. Values
+// are opaque bytes so callers decide how to encode them.
+type Backend interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	Flush(ctx context.Context) error
+}
+
+// newMetrics returns the expvar map the counters for the named cache are
+// published under. Caches sharing a name share their counters and an empty
+// name keeps them unpublished.
+func newMetrics(name string) *expvar.Map {
+	if name == "" {
+		return new(expvar.Map)
+	}
+
+	if v, ok := expvar.Get(name).(*expvar.Map); ok {
+		return v
+	}
+
+	return expvar.NewMap(name)
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"expvar"
+	"sync"
+	"time"
+)
+
+// defaultMaxEntries is used when MemoryConfig doesn't specify a size.
+const defaultMaxEntries = 10_000
+
+// MemoryConfig represents the settings for an in-memory cache.
+type MemoryConfig struct {
+	Name       string
+	MaxEntries int
+}
+
+// entry is an item in the memory cache.
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// Memory is a cache that lives in the process. It's bounded and evicts the
+// least recently used entry once full.
+type Memory struct {
+	maxEntries int
+	metrics    *expvar.Map
+
+	mu    sync.Mutex
+	lru   *list.List
+	items map[string]*list.Element
+}
+
+// NewMemory constructs an in-memory cache.
+func NewMemory(cfg MemoryConfig) *Memory {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = defaultMaxEntries
+	}
+
+	return &Memory{
+		maxEntries: cfg.MaxEntries,
+		metrics:    newMetrics(cfg.Name),
+		lru:        list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value for the key.
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, exists := m.items[key]
+	if !exists {
+		m.metrics.Add("misses", 1)
+		return nil, ErrNotFound
+	}
+
+	ent := elem.Value.(*entry)
+	if time.Now().After(ent.expires) {
+		m.remove(elem)
+		m.metrics.Add("expirations", 1)
+		m.metrics.Add("misses", 1)
+		return nil, ErrNotFound
+	}
+
+	m.lru.MoveToFront(elem)
+	m.metrics.Add("hits", 1)
+
+	return ent.value, nil
+}
+
+// Set stores the value for the key until the ttl passes.
+func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expires := time.Now().Add(ttl)
+
+	if elem, exists := m.items[key]; exists {
+		ent := elem.Value.(*entry)
+		ent.value = value
+		ent.expires = expires
+		m.lru.MoveToFront(elem)
+		return nil
+	}
+
+	m.items[key] = m.lru.PushFront(&entry{key: key, value: value, expires: expires})
+
+	for m.lru.Len() > m.maxEntries {
+		m.remove(m.lru.Back())
+		m.metrics.Add("evictions", 1)
+	}
+
+	return nil
+}
+
+// Delete removes the keys from the cache.
+func (m *Memory) Delete(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, exists := m.items[key]; exists {
+			m.remove(elem)
+			m.metrics.Add("deletes", 1)
+		}
+	}
+
+	return nil
+}
+
+// Flush removes every entry from the cache.
+func (m *Memory) Flush(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lru.Init()
+	m.items = make(map[string]*list.Element)
+	m.metrics.Add("flushes", 1)
+
+	return nil
+}
+
+// Len returns the number of entries in the cache.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
+
+// remove drops the element from the cache. The caller must hold the lock.
+func (m *Memory) remove(elem *list.Element) {
+	m.lru.Remove(elem)
+	delete(m.items, elem.Value.(*entry).key)
+}
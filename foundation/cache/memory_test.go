@@ -0,0 +1,130 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"github.com/halilylm/micro/foundation/cache"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMemoryEviction checks that the least recently used entry is evicted
+// once the cache is full. Each op is "set:key", "get:key", "del:key" or
+// "flush".
+func TestMemoryEviction(t *testing.T) {
+	tests := []struct {
+		name      string
+		max       int
+		ops       []string
+		present   []string
+		missing   []string
+		evictions int64
+	}{
+		{"within limit", 3, []string{"set:a", "set:b", "set:c"}, []string{"a", "b", "c"}, nil, 0},
+		{"oldest evicted", 2, []string{"set:a", "set:b", "set:c"}, []string{"b", "c"}, []string{"a"}, 1},
+		{"read keeps entry", 2, []string{"set:a", "set:b", "get:a", "set:c"}, []string{"a", "c"}, []string{"b"}, 1},
+		{"write keeps entry", 2, []string{"set:a", "set:b", "set:a", "set:c"}, []string{"a", "c"}, []string{"b"}, 1},
+		{"miss doesn't count", 2, []string{"set:a", "set:b", "get:x", "set:c"}, []string{"b", "c"}, []string{"a"}, 1},
+		{"delete makes room", 2, []string{"set:a", "set:b", "del:a", "set:c"}, []string{"b", "c"}, []string{"a"}, 0},
+		{"flush empties", 2, []string{"set:a", "set:b", "flush", "set:c"}, []string{"c"}, []string{"a", "b"}, 0},
+		{"several evicted", 1, []string{"set:a", "set:b", "set:c"}, []string{"c"}, []string{"a", "b"}, 2},
+	}
+
+	ctx := context.Background()
+
+	for i, tt := range tests {
+		name := "test_memory_eviction_" + string(rune('a'+i))
+		m := cache.NewMemory(cache.MemoryConfig{Name: name, MaxEntries: tt.max})
+
+		for _, op := range tt.ops {
+			action, key, _ := strings.Cut(op, ":")
+			switch action {
+			case "set":
+				if err := m.Set(ctx, key, []byte(key), time.Minute); err != nil {
+					t.Fatalf("%s: %s: %s", tt.name, op, err)
+				}
+			case "get":
+				m.Get(ctx, key)
+			case "del":
+				m.Delete(ctx, key)
+			case "flush":
+				m.Flush(ctx)
+			}
+		}
+
+		var evictions int64
+		if v, ok := expvar.Get(name).(*expvar.Map).Get("evictions").(*expvar.Int); ok {
+			evictions = v.Value()
+		}
+		if evictions != tt.evictions {
+			t.Errorf("%s: got %d evictions, want %d", tt.name, evictions, tt.evictions)
+		}
+
+		if n := m.Len(); n != len(tt.present) {
+			t.Errorf("%s: got %d entries, want %d", tt.name, n, len(tt.present))
+		}
+
+		for _, key := range tt.present {
+			if v, err := m.Get(ctx, key); err != nil || string(v) != key {
+				t.Errorf("%s: get %s: got %q %v", tt.name, key, v, err)
+			}
+		}
+		for _, key := range tt.missing {
+			if _, err := m.Get(ctx, key); !errors.Is(err, cache.ErrNotFound) {
+				t.Errorf("%s: get %s: got %v, want %v", tt.name, key, err, cache.ErrNotFound)
+			}
+		}
+	}
+}
+
+// TestMemoryTTL checks that an entry misses once its ttl has passed.
+func TestMemoryTTL(t *testing.T) {
+	tests := []struct {
+		name  string
+		ttl   time.Duration
+		reset time.Duration
+		found bool
+	}{
+		{"live", time.Minute, 0, true},
+		{"expired", 10 * time.Millisecond, 0, false},
+		{"no ttl", 0, 0, false},
+		{"extended by a write", 10 * time.Millisecond, time.Minute, true},
+		{"shortened by a write", time.Minute, 10 * time.Millisecond, false},
+	}
+
+	ctx := context.Background()
+
+	for _, tt := range tests {
+		m := cache.NewMemory(cache.MemoryConfig{})
+
+		if err := m.Set(ctx, "key", []byte("value"), tt.ttl); err != nil {
+			t.Fatalf("%s: set: %s", tt.name, err)
+		}
+		if tt.reset != 0 {
+			if err := m.Set(ctx, "key", []byte("value"), tt.reset); err != nil {
+				t.Fatalf("%s: set again: %s", tt.name, err)
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+
+		_, err := m.Get(ctx, "key")
+		switch {
+		case tt.found && err != nil:
+			t.Errorf("%s: got %v, want the value", tt.name, err)
+		case !tt.found && !errors.Is(err, cache.ErrNotFound):
+			t.Errorf("%s: got %v, want %v", tt.name, err, cache.ErrNotFound)
+		}
+
+		// An expired entry is dropped when it's read.
+		want := 0
+		if tt.found {
+			want = 1
+		}
+		if n := m.Len(); n != want {
+			t.Errorf("%s: got %d entries, want %d", tt.name, n, want)
+		}
+	}
+}
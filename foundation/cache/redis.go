@@ -0,0 +1,372 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Default settings used for zero values in the RedisConfig.
+const (
+	defaultMaxIdleConns = 4
+	defaultDialTimeout  = 5 * time.Second
+	defaultIOTimeout    = 3 * time.Second
+	scanCount           = 500
+)
+
+// RedisConfig represents the settings for a cache kept in a server that
+// speaks the Redis protocol. Every key is stored under the Prefix so
+// several caches can share a server and Flush only removes its own keys.
+type RedisConfig struct {
+	Name         string
+	Address      string
+	Password     string
+	DB           int
+	Prefix       string
+	MaxIdleConns int
+	DialTimeout  time.Duration
+	IOTimeout    time.Duration
+}
+
+// RedisError is an error reply sent by the server.
+type RedisError string
+
+// Error implements the error interface.
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// Redis is a cache that is kept in a server that speaks the Redis protocol,
+// so it's shared by every instance of the service.
+type Redis struct {
+	cfg     RedisConfig
+	metrics *expvar.Map
+
+	mu   sync.Mutex
+	idle []*redisConn
+}
+
+// NewRedis constructs a cache for the Redis server. Connections are opened
+// on demand.
+func NewRedis(cfg RedisConfig) *Redis {
+	if cfg.MaxIdleConns <= 0 {
+		cfg.MaxIdleConns = defaultMaxIdleConns
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = defaultDialTimeout
+	}
+	if cfg.IOTimeout <= 0 {
+		cfg.IOTimeout = defaultIOTimeout
+	}
+
+	return &Redis{
+		cfg:     cfg,
+		metrics: newMetrics(cfg.Name),
+	}
+}
+
+// Get returns the value for the key.
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	reply, err := r.do(ctx, "GET", r.cfg.Prefix+key)
+	if err != nil {
+		return nil, err
+	}
+
+	if reply == nil {
+		r.metrics.Add("misses", 1)
+		return nil, ErrNotFound
+	}
+
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected reply %T for GET", reply)
+	}
+	r.metrics.Add("hits", 1)
+
+	return value, nil
+}
+
+// Set stores the value for the key until the ttl passes.
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ms := ttl.Milliseconds()
+	if ms <= 0 {
+		ms = 1
+	}
+
+	if _, err := r.do(ctx, "SET", r.cfg.Prefix+key, string(value), "PX", strconv.FormatInt(ms, 10)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes the keys from the cache.
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	args := make([]string, 0, len(keys)+1)
+	args = append(args, "DEL")
+	for _, key := range keys {
+		args = append(args, r.cfg.Prefix+key)
+	}
+
+	if _, err := r.do(ctx, args...); err != nil {
+		return err
+	}
+	r.metrics.Add("deletes", int64(len(keys)))
+
+	return nil
+}
+
+// Flush removes every key under the prefix. Without a prefix the whole
+// database is flushed.
+func (r *Redis) Flush(ctx context.Context) error {
+	defer r.metrics.Add("flushes", 1)
+
+	if r.cfg.Prefix == "" {
+		_, err := r.do(ctx, "FLUSHDB")
+		return err
+	}
+
+	cursor := "0"
+	for {
+		reply, err := r.do(ctx, "SCAN", cursor, "MATCH", r.cfg.Prefix+"*", "COUNT", strconv.Itoa(scanCount))
+		if err != nil {
+			return err
+		}
+
+		parts, ok := reply.([]any)
+		if !ok || len(parts) != 2 {
+			return fmt.Errorf("redis: unexpected reply %T for SCAN", reply)
+		}
+
+		next, _ := parts[0].([]byte)
+		keys, _ := parts[1].([]any)
+
+		if len(keys) > 0 {
+			args := make([]string, 0, len(keys)+1)
+			args = append(args, "DEL")
+			for _, key := range keys {
+				if b, ok := key.([]byte); ok {
+					args = append(args, string(b))
+				}
+			}
+			if _, err := r.do(ctx, args...); err != nil {
+				return err
+			}
+		}
+
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			return nil
+		}
+	}
+}
+
+// Close closes the idle connections.
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, cn := range r.idle {
+		cn.Close()
+	}
+	r.idle = nil
+
+	return nil
+}
+
+// =============================================================================
+
+// do sends the command on a pooled connection and reads the reply.
+func (r *Redis) do(ctx context.Context, args ...string) (any, error) {
+	cn, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(r.cfg.IOTimeout)
+	}
+	cn.SetDeadline(deadline)
+
+	reply, err := cn.do(args...)
+	if err != nil {
+		var redisErr RedisError
+		if errors.As(err, &redisErr) {
+			r.release(cn)
+			return nil, err
+		}
+
+		// The connection is in an unknown state after a network error.
+		cn.Close()
+		return nil, fmt.Errorf("redis: %s: %w", args[0], err)
+	}
+
+	r.release(cn)
+
+	return reply, nil
+}
+
+// conn returns an idle connection or opens a new one.
+func (r *Redis) conn(ctx context.Context) (*redisConn, error) {
+	r.mu.Lock()
+	if n := len(r.idle); n > 0 {
+		cn := r.idle[n-1]
+		r.idle = r.idle[:n-1]
+		r.mu.Unlock()
+		return cn, nil
+	}
+	r.mu.Unlock()
+
+	d := net.Dialer{Timeout: r.cfg.DialTimeout}
+	nc, err := d.DialContext(ctx, "tcp", r.cfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("redis: dial: %w", err)
+	}
+
+	cn := &redisConn{
+		Conn: nc,
+		r:    bufio.NewReader(nc),
+		w:    bufio.NewWriter(nc),
+	}
+	cn.SetDeadline(time.Now().Add(r.cfg.IOTimeout))
+
+	if r.cfg.Password != "" {
+		if _, err := cn.do("AUTH", r.cfg.Password); err != nil {
+			cn.Close()
+			return nil, fmt.Errorf("redis: auth: %w", err)
+		}
+	}
+
+	if r.cfg.DB != 0 {
+		if _, err := cn.do("SELECT", strconv.Itoa(r.cfg.DB)); err != nil {
+			cn.Close()
+			return nil, fmt.Errorf("redis: select: %w", err)
+		}
+	}
+
+	return cn, nil
+}
+
+// release returns the connection to the pool or closes it when the pool
+// is full.
+func (r *Redis) release(cn *redisConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.idle) >= r.cfg.MaxIdleConns {
+		cn.Close()
+		return
+	}
+	r.idle = append(r.idle, cn)
+}
+
+// =============================================================================
+
+// redisConn is a connection that reads and writes the RESP protocol.
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// do writes the command as an array of bulk strings and reads the reply.
+func (cn *redisConn) do(args ...string) (any, error) {
+	fmt.Fprintf(cn.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(cn.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := cn.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	return ReadReply(cn.r)
+}
+
+// ReadReply reads a single RESP reply. Simple strings and bulk strings are
+// returned as []byte, integers as int64, arrays as []any and nil replies
+// as nil. An error reply is returned as a RedisError.
+func ReadReply(r *bufio.Reader) (any, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) == 0 {
+		return nil, errors.New("empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return []byte(line[1:]), nil
+
+	case '-':
+		return nil, RedisError(line[1:])
+
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer reply: %w", err)
+		}
+		return n, nil
+
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk length: %w", err)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid array length: %w", err)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			item, err := ReadReply(r)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("unknown reply type %q", line[0])
+}
+
+// readLine reads a line terminated by CRLF without the terminator.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", errors.New("invalid line terminator")
+	}
+
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,98 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"github.com/halilylm/micro/foundation/cache"
+	"github.com/halilylm/micro/foundation/cache/redisstub"
+	"testing"
+	"time"
+)
+
+// TestRedis runs the Redis backend against the stub server.
+func TestRedis(t *testing.T) {
+	const password = "secret"
+
+	srv, err := redisstub.Start("127.0.0.1:0", password)
+	if err != nil {
+		t.Fatalf("starting server: %s", err)
+	}
+	defer srv.Close()
+
+	newRedis := func(prefix string, pass string) *cache.Redis {
+		r := cache.NewRedis(cache.RedisConfig{
+			Address:  srv.Addr(),
+			Password: pass,
+			DB:       1,
+			Prefix:   prefix,
+		})
+		t.Cleanup(func() { r.Close() })
+		return r
+	}
+
+	ctx := context.Background()
+	r := newRedis("a:", password)
+	other := newRedis("b:", password)
+
+	// Values are stored under the prefix in the configured database.
+	if _, err := r.Get(ctx, "key"); !errors.Is(err, cache.ErrNotFound) {
+		t.Fatalf("get before set: got %v, want %v", err, cache.ErrNotFound)
+	}
+	if err := r.Set(ctx, "key", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("set: %s", err)
+	}
+	if v, err := r.Get(ctx, "key"); err != nil || string(v) != "value" {
+		t.Fatalf("get: got %q %v", v, err)
+	}
+	if _, err := other.Get(ctx, "key"); !errors.Is(err, cache.ErrNotFound) {
+		t.Fatalf("get under another prefix: got %v, want %v", err, cache.ErrNotFound)
+	}
+	if n := srv.Len(1); n != 1 {
+		t.Fatalf("got %d keys in db 1, want 1", n)
+	}
+
+	// Binary values come back unchanged.
+	binary := []byte{0, '\r', '\n', 0xff}
+	if err := r.Set(ctx, "binary", binary, time.Minute); err != nil {
+		t.Fatalf("set binary: %s", err)
+	}
+	if v, err := r.Get(ctx, "binary"); err != nil || string(v) != string(binary) {
+		t.Fatalf("get binary: got %q %v", v, err)
+	}
+
+	// An entry misses once its ttl has passed.
+	if err := r.Set(ctx, "short", []byte("value"), 10*time.Millisecond); err != nil {
+		t.Fatalf("set short: %s", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := r.Get(ctx, "short"); !errors.Is(err, cache.ErrNotFound) {
+		t.Fatalf("get expired: got %v, want %v", err, cache.ErrNotFound)
+	}
+
+	if err := r.Delete(ctx, "key", "missing"); err != nil {
+		t.Fatalf("delete: %s", err)
+	}
+	if _, err := r.Get(ctx, "key"); !errors.Is(err, cache.ErrNotFound) {
+		t.Fatalf("get deleted: got %v, want %v", err, cache.ErrNotFound)
+	}
+
+	// Flush only removes the keys under its own prefix.
+	if err := other.Set(ctx, "key", []byte("other"), time.Minute); err != nil {
+		t.Fatalf("set other: %s", err)
+	}
+	if err := r.Flush(ctx); err != nil {
+		t.Fatalf("flush: %s", err)
+	}
+	if _, err := r.Get(ctx, "binary"); !errors.Is(err, cache.ErrNotFound) {
+		t.Fatalf("get flushed: got %v, want %v", err, cache.ErrNotFound)
+	}
+	if v, err := other.Get(ctx, "key"); err != nil || string(v) != "other" {
+		t.Fatalf("get other after flush: got %q %v", v, err)
+	}
+
+	// A wrong password is reported as the error the server replied with.
+	var redisErr cache.RedisError
+	if _, err := newRedis("a:", "wrong").Get(ctx, "key"); !errors.As(err, &redisErr) {
+		t.Fatalf("get with wrong password: got %v, want a redis error", err)
+	}
+}
@@ -0,0 +1,423 @@
+// Package redisstub provides an in-memory stand-in for a Redis server. It
+// understands the subset of commands used by the cache package so the Redis
+// backend can be exercised without running Redis.
+package redisstub
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/halilylm/micro/foundation/cache"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// item is a stored value.
+type item struct {
+	value   []byte
+	expires time.Time
+}
+
+// Server is a Redis stand-in listening on a TCP address.
+type Server struct {
+	listener net.Listener
+	password string
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	conns   map[net.Conn]struct{}
+	dbs     map[int]map[string]item
+	cursors map[int]string
+	cursor  int
+}
+
+// Start listens on the address, like "127.0.0.1:0", and serves connections
+// until Close is called. When password isn't empty clients must AUTH.
+func Start(addr string, password string) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
+	s := Server{
+		listener: l,
+		password: password,
+		conns:    make(map[net.Conn]struct{}),
+		dbs:      make(map[int]map[string]item),
+		cursors:  make(map[int]string),
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.accept()
+	}()
+
+	return &s, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server and closes the open connections.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+
+	s.mu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+
+	return err
+}
+
+// Len returns the number of keys that haven't expired in the database.
+func (s *Server) Len(db int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	now := time.Now()
+	for _, it := range s.dbs[db] {
+		if it.expires.IsZero() || now.Before(it.expires) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// =============================================================================
+
+// accept handles incoming connections until the listener is closed.
+func (s *Server) accept() {
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serve(c)
+
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+			c.Close()
+		}()
+	}
+}
+
+// session is the state of a single connection.
+type session struct {
+	db     int
+	authed bool
+}
+
+// serve reads commands from the connection and writes the replies.
+func (s *Server) serve(c net.Conn) {
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+
+	ses := session{authed: s.password == ""}
+
+	for {
+		reply, err := cache.ReadReply(r)
+		if err != nil {
+			return
+		}
+
+		args, err := toArgs(reply)
+		if err != nil {
+			writeError(w, err.Error())
+			w.Flush()
+			continue
+		}
+
+		quit := s.exec(&ses, w, args)
+		if err := w.Flush(); err != nil || quit {
+			return
+		}
+	}
+}
+
+// exec runs a single command. It returns true when the client quits.
+func (s *Server) exec(ses *session, w *bufio.Writer, args []string) bool {
+	cmd := strings.ToUpper(args[0])
+
+	if !ses.authed && cmd != "AUTH" && cmd != "QUIT" {
+		writeError(w, "NOAUTH Authentication required.")
+		return false
+	}
+
+	switch cmd {
+	case "PING":
+		writeSimple(w, "PONG")
+
+	case "QUIT":
+		writeSimple(w, "OK")
+		return true
+
+	case "AUTH":
+		if len(args) != 2 {
+			writeError(w, "ERR wrong number of arguments for 'auth' command")
+			break
+		}
+		if args[1] != s.password {
+			writeError(w, "WRONGPASS invalid username-password pair")
+			break
+		}
+		ses.authed = true
+		writeSimple(w, "OK")
+
+	case "SELECT":
+		if len(args) != 2 {
+			writeError(w, "ERR wrong number of arguments for 'select' command")
+			break
+		}
+		db, err := strconv.Atoi(args[1])
+		if err != nil || db < 0 {
+			writeError(w, "ERR invalid DB index")
+			break
+		}
+		ses.db = db
+		writeSimple(w, "OK")
+
+	case "GET":
+		if len(args) != 2 {
+			writeError(w, "ERR wrong number of arguments for 'get' command")
+			break
+		}
+		value, ok := s.get(ses.db, args[1])
+		if !ok {
+			writeNil(w)
+			break
+		}
+		writeBulk(w, value)
+
+	case "SET":
+		s.set(ses.db, w, args)
+
+	case "DEL":
+		if len(args) < 2 {
+			writeError(w, "ERR wrong number of arguments for 'del' command")
+			break
+		}
+		writeInt(w, s.del(ses.db, args[1:]))
+
+	case "SCAN":
+		s.scan(ses.db, w, args)
+
+	case "FLUSHDB":
+		s.mu.Lock()
+		delete(s.dbs, ses.db)
+		s.mu.Unlock()
+		writeSimple(w, "OK")
+
+	default:
+		writeError(w, fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+
+	return false
+}
+
+// get returns the value for the key if it hasn't expired.
+func (s *Server) get(db int, key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	it, ok := s.dbs[db][key]
+	if !ok {
+		return nil, false
+	}
+
+	if !it.expires.IsZero() && !time.Now().Before(it.expires) {
+		delete(s.dbs[db], key)
+		return nil, false
+	}
+
+	return it.value, true
+}
+
+// set handles SET key value [EX seconds | PX milliseconds].
+func (s *Server) set(db int, w *bufio.Writer, args []string) {
+	if len(args) != 3 && len(args) != 5 {
+		writeError(w, "ERR syntax error")
+		return
+	}
+
+	var expires time.Time
+	if len(args) == 5 {
+		n, err := strconv.ParseInt(args[4], 10, 64)
+		if err != nil || n <= 0 {
+			writeError(w, "ERR invalid expire time in 'set' command")
+			return
+		}
+
+		switch strings.ToUpper(args[3]) {
+		case "EX":
+			expires = time.Now().Add(time.Duration(n) * time.Second)
+		case "PX":
+			expires = time.Now().Add(time.Duration(n) * time.Millisecond)
+		default:
+			writeError(w, "ERR syntax error")
+			return
+		}
+	}
+
+	s.mu.Lock()
+	if s.dbs[db] == nil {
+		s.dbs[db] = make(map[string]item)
+	}
+	s.dbs[db][args[1]] = item{value: []byte(args[2]), expires: expires}
+	s.mu.Unlock()
+
+	writeSimple(w, "OK")
+}
+
+// del removes the keys and returns how many existed.
+func (s *Server) del(db int, keys []string) int {
+	n := 0
+	for _, key := range keys {
+		if _, ok := s.get(db, key); ok {
+			n++
+		}
+	}
+
+	s.mu.Lock()
+	for _, key := range keys {
+		delete(s.dbs[db], key)
+	}
+	s.mu.Unlock()
+
+	return n
+}
+
+// scan handles SCAN cursor [MATCH pattern] [COUNT count]. Every cursor
+// remembers the last key it returned, so like Redis the keys that are
+// deleted while scanning don't cause others to be skipped.
+func (s *Server) scan(db int, w *bufio.Writer, args []string) {
+	if len(args) < 2 {
+		writeError(w, "ERR wrong number of arguments for 'scan' command")
+		return
+	}
+
+	cursor, err := strconv.Atoi(args[1])
+	if err != nil || cursor < 0 {
+		writeError(w, "ERR invalid cursor")
+		return
+	}
+
+	pattern := "*"
+	count := 10
+	for i := 2; i+1 < len(args); i += 2 {
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count <= 0 {
+				writeError(w, "ERR syntax error")
+				return
+			}
+		}
+	}
+
+	s.mu.Lock()
+	after, ok := s.cursors[cursor]
+	delete(s.cursors, cursor)
+	if cursor != 0 && !ok {
+		s.mu.Unlock()
+		writeError(w, "ERR invalid cursor")
+		return
+	}
+
+	keys := make([]string, 0, len(s.dbs[db]))
+	for key := range s.dbs[db] {
+		if cursor == 0 || key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	next := 0
+	if len(keys) > count {
+		keys = keys[:count]
+		s.cursor++
+		next = s.cursor
+		s.cursors[next] = keys[len(keys)-1]
+	}
+	s.mu.Unlock()
+
+	var matched []string
+	for _, key := range keys {
+		if ok, _ := path.Match(pattern, key); ok {
+			matched = append(matched, key)
+		}
+	}
+
+	fmt.Fprintf(w, "*2\r\n")
+	writeBulk(w, []byte(strconv.Itoa(next)))
+	fmt.Fprintf(w, "*%d\r\n", len(matched))
+	for _, key := range matched {
+		writeBulk(w, []byte(key))
+	}
+}
+
+// =============================================================================
+
+// toArgs converts a command sent as an array of bulk strings.
+func toArgs(reply any) ([]string, error) {
+	items, ok := reply.([]any)
+	if !ok || len(items) == 0 {
+		return nil, errors.New("ERR protocol error: expected array of bulk strings")
+	}
+
+	args := make([]string, len(items))
+	for i, it := range items {
+		b, ok := it.([]byte)
+		if !ok {
+			return nil, errors.New("ERR protocol error: expected bulk string")
+		}
+		args[i] = string(b)
+	}
+
+	return args, nil
+}
+
+func writeSimple(w *bufio.Writer, s string) {
+	fmt.Fprintf(w, "+%s\r\n", s)
+}
+
+func writeError(w *bufio.Writer, s string) {
+	fmt.Fprintf(w, "-%s\r\n", s)
+}
+
+func writeInt(w *bufio.Writer, n int) {
+	fmt.Fprintf(w, ":%d\r\n", n)
+}
+
+func writeNil(w *bufio.Writer) {
+	fmt.Fprintf(w, "$-1\r\n")
+}
+
+func writeBulk(w *bufio.Writer, b []byte) {
+	fmt.Fprintf(w, "$%d\r\n", len(b))
+	w.Write(b)
+	w.WriteString("\r\n")
+}
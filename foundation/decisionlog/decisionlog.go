@@ -0,0 +1,55 @@
+// Package decisionlog provides support for recording the decisions made by
+// policies, to the service log, a file or a remote collector.
+package decisionlog
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Decision records the outcome of evaluating a policy rule.
+type Decision struct {
+	ID       string         `json:"decision_id"`
+	Time     time.Time      `json:"timestamp"`
+	TraceID  string         `json:"trace_id"`
+	Rule     string         `json:"rule"`
+	Revision string         `json:"revision"`
+	Input    map[string]any `json:"input"`
+	Allowed  bool           `json:"allowed"`
+	Error    string         `json:"error,omitempty"`
+	Duration time.Duration  `json:"duration_ns"`
+}
+
+// Logger is the behavior a decision log sink has to provide. It's called
+// while requests are handled so it must not block on slow outputs.
+type Logger interface {
+	Log(ctx context.Context, d Decision)
+}
+
+// =============================================================================
+
+// Sampler passes denied decisions and a fraction of the allowed ones on to
+// another logger. Denials are the ones audits ask about, allowed decisions
+// are the bulk of the volume.
+type Sampler struct {
+	next Logger
+	rate float64
+}
+
+// NewSampler constructs a sampler logging the rate, between 0 and 1, of the
+// allowed decisions.
+func NewSampler(next Logger, rate float64) *Sampler {
+	return &Sampler{
+		next: next,
+		rate: rate,
+	}
+}
+
+// Log passes the decision on when it's denied or selected by the rate.
+func (s *Sampler) Log(ctx context.Context, d Decision) {
+	if d.Allowed && s.rate < 1 && rand.Float64() >= s.rate {
+		return
+	}
+	s.next.Log(ctx, d)
+}
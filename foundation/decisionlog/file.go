@@ -0,0 +1,49 @@
+package decisionlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// File appends decisions to a file as JSON lines, for an agent to ship
+// them from there.
+type File struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewFile opens the file for appending, creating it when needed.
+func NewFile(path string) (*File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening decision log: %w", err)
+	}
+
+	f := File{
+		file: file,
+		enc:  json.NewEncoder(file),
+	}
+
+	return &f, nil
+}
+
+// Log appends the decision to the file. A decision that can't be written is
+// lost since there is nowhere to report it without failing the request.
+func (f *File) Log(ctx context.Context, d Decision) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.enc.Encode(d)
+}
+
+// Close closes the file.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
@@ -0,0 +1,173 @@
+package decisionlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Defaults used when HTTPConfig doesn't specify them.
+const (
+	defaultBatchSize     = 100
+	defaultFlushInterval = 5 * time.Second
+	defaultBufferSize    = 10000
+)
+
+// dropped counts the decisions the HTTP loggers couldn't keep up with.
+var dropped = expvar.NewInt("decisionlog_dropped")
+
+// HTTPConfig represents the settings for sending decisions to a collector.
+// The decisions are posted as a JSON array once BatchSize of them are
+// waiting or FlushInterval passed. Up to BufferSize decisions are held while
+// the collector is slow, more are dropped.
+type HTTPConfig struct {
+	URL           string
+	BatchSize     int
+	FlushInterval time.Duration
+	BufferSize    int
+	Client        *http.Client
+}
+
+// HTTP sends decisions to a collector in batches.
+type HTTP struct {
+	log    *zap.SugaredLogger
+	cfg    HTTPConfig
+	queue  chan Decision
+	wg     sync.WaitGroup
+	closed chan struct{}
+	once   sync.Once
+}
+
+// NewHTTP constructs a logger posting to the collector and starts sending.
+// Close has to be called to send what's left.
+func NewHTTP(log *zap.SugaredLogger, cfg HTTPConfig) *HTTP {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = defaultBufferSize
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	h := HTTP{
+		log:    log,
+		cfg:    cfg,
+		queue:  make(chan Decision, cfg.BufferSize),
+		closed: make(chan struct{}),
+	}
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		h.run()
+	}()
+
+	return &h
+}
+
+// Log queues the decision. When the queue is full the decision is dropped
+// instead of holding up the request.
+func (h *HTTP) Log(ctx context.Context, d Decision) {
+	select {
+	case h.queue <- d:
+	default:
+		dropped.Add(1)
+	}
+}
+
+// Close sends the queued decisions and stops. Decisions logged afterwards
+// are dropped.
+func (h *HTTP) Close() {
+	h.once.Do(func() {
+		close(h.closed)
+	})
+	h.wg.Wait()
+}
+
+// run collects decisions into batches until Close is called.
+func (h *HTTP) run() {
+	ticker := time.NewTicker(h.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Decision, 0, h.cfg.BatchSize)
+
+	for {
+		select {
+		case d := <-h.queue:
+			batch = append(batch, d)
+			if len(batch) < h.cfg.BatchSize {
+				continue
+			}
+
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+
+		case <-h.closed:
+			for {
+				select {
+				case d := <-h.queue:
+					batch = append(batch, d)
+					if len(batch) == h.cfg.BatchSize {
+						h.send(batch)
+						batch = batch[:0]
+					}
+				default:
+					if len(batch) > 0 {
+						h.send(batch)
+					}
+					return
+				}
+			}
+		}
+
+		h.send(batch)
+		batch = batch[:0]
+	}
+}
+
+// send posts the batch to the collector. A batch that can't be sent is
+// logged and dropped.
+func (h *HTTP) send(batch []Decision) {
+	if err := h.post(batch); err != nil {
+		dropped.Add(int64(len(batch)))
+		h.log.Errorw("decisionlog", "status", "sending batch", "decisions", len(batch), "ERROR", err)
+	}
+}
+
+func (h *HTTP) post(batch []Decision) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h.cfg.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.cfg.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d from %s", resp.StatusCode, h.cfg.URL)
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package decisionlog
+
+import (
+	"context"
+	"go.uber.org/zap"
+)
+
+// Zap writes decisions to the service log.
+type Zap struct {
+	log *zap.SugaredLogger
+}
+
+// NewZap constructs a logger writing to the service log.
+func NewZap(log *zap.SugaredLogger) *Zap {
+	return &Zap{
+		log: log,
+	}
+}
+
+// Log writes the decision as a structured log entry.
+func (z *Zap) Log(ctx context.Context, d Decision) {
+	z.log.Infow("decision",
+		"decision_id", d.ID,
+		"trace_id", d.TraceID,
+		"rule", d.Rule,
+		"revision", d.Revision,
+		"allowed", d.Allowed,
+		"error", d.Error,
+		"duration", d.Duration,
+		"input", d.Input,
+	)
+}
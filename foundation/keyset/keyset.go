@@ -0,0 +1,134 @@
+// Package keyset provides support for rotating the keys used to sign
+// tokens. A set has one active key that signs new tokens and any number of
+// keys that are only kept to verify the tokens they signed before they were
+// replaced. A key rotated in is published for verification ahead of taking
+// over signing, so clients caching the published keys know it in time.
+package keyset
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Set of statuses a key can have.
+const (
+	StatusActive = "active"
+	StatusVerify = "verify"
+)
+
+// ErrNoActiveKey is returned when the set doesn't have a key for signing.
+var ErrNoActiveKey = errors.New("no active signing key")
+
+// Key represents a key in the set. An active key signs from DateActive on
+// until a key activated later takes over, a zero DateActive means it always
+// could. A zero DateRetire means the key doesn't have a retirement
+// scheduled.
+type Key struct {
+	KID         string    `json:"kid"`
+	Status      string    `json:"status"`
+	DateCreated time.Time `json:"date_created"`
+	DateActive  time.Time `json:"date_active"`
+	DateRetire  time.Time `json:"date_retire"`
+}
+
+// Retired reports whether the key can no longer be used at the given time.
+func (k Key) Retired(now time.Time) bool {
+	return !k.DateRetire.IsZero() && !now.Before(k.DateRetire)
+}
+
+// Set represents the keys in use.
+type Set struct {
+	Keys []Key `json:"keys"`
+}
+
+// Signing returns the kid of the active key activated last.
+func (s Set) Signing(now time.Time) (string, error) {
+	var signing *Key
+	for i, k := range s.Keys {
+		if k.Status != StatusActive || k.Retired(now) || now.Before(k.DateActive) {
+			continue
+		}
+		if signing == nil || k.DateActive.After(signing.DateActive) {
+			signing = &s.Keys[i]
+		}
+	}
+
+	if signing == nil {
+		return "", ErrNoActiveKey
+	}
+
+	return signing.KID, nil
+}
+
+// Verification returns the kids of the keys tokens may be signed with.
+func (s Set) Verification(now time.Time) []string {
+	kids := make([]string, 0, len(s.Keys))
+	for _, k := range s.Keys {
+		if !k.Retired(now) {
+			kids = append(kids, k.KID)
+		}
+	}
+	return kids
+}
+
+// Rotate returns a set where the kid becomes the active key once
+// activateAfter has passed. Until then it's only published for verification
+// and the key it replaces keeps signing. The replaced key keeps verifying
+// tokens until retireAfter has passed from the activation, which should be
+// longer than the lifetime of the tokens it signed. Keys already retired
+// are dropped.
+func (s Set) Rotate(kid string, now time.Time, activateAfter time.Duration, retireAfter time.Duration) (Set, error) {
+	if kid == "" {
+		return Set{}, errors.New("kid is required")
+	}
+
+	activate := now.Add(activateAfter)
+
+	keys := make([]Key, 0, len(s.Keys)+1)
+	keys = append(keys, Key{
+		KID:         kid,
+		Status:      StatusActive,
+		DateCreated: now,
+		DateActive:  activate,
+	})
+
+	for _, k := range s.Keys {
+		if k.KID == kid {
+			return Set{}, fmt.Errorf("kid %q already in the set", kid)
+		}
+		if k.Retired(now) {
+			continue
+		}
+
+		// A replaced key stays active, so it signs until the new key is
+		// activated, but is retired after.
+		if k.Status == StatusActive && k.DateRetire.IsZero() {
+			k.DateRetire = activate.Add(retireAfter)
+		}
+		keys = append(keys, k)
+	}
+
+	return Set{Keys: keys}, nil
+}
+
+// Retire returns a set where the kid is retired at the given time. The key
+// signing at that time can't be retired since tokens couldn't be signed
+// anymore; a new key has to be rotated in first.
+func (s Set) Retire(kid string, at time.Time) (Set, error) {
+	keys := make([]Key, len(s.Keys))
+	copy(keys, s.Keys)
+
+	for i, k := range keys {
+		if k.KID != kid {
+			continue
+		}
+		if signing, err := s.Signing(at); err == nil && signing == kid {
+			return Set{}, fmt.Errorf("kid %q is the active key", kid)
+		}
+		keys[i].DateRetire = at
+		return Set{Keys: keys}, nil
+	}
+
+	return Set{}, fmt.Errorf("kid %q not in the set", kid)
+}
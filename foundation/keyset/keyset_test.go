@@ -0,0 +1,128 @@
+package keyset_test
+
+import (
+	"errors"
+	"github.com/halilylm/micro/foundation/keyset"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRotation walks a rotation through time. The new key is published for
+// verification ahead of signing, and the key it replaces keeps verifying
+// until it's retired.
+func TestRotation(t *testing.T) {
+	const (
+		activateAfter = 10 * time.Minute
+		retireAfter   = 2 * time.Hour
+	)
+
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rotated := created.Add(24 * time.Hour)
+	activated := rotated.Add(activateAfter)
+	retired := activated.Add(retireAfter)
+
+	set, err := keyset.Set{}.Rotate("k1", created, 0, retireAfter)
+	if err != nil {
+		t.Fatalf("creating: %s", err)
+	}
+	set, err = set.Rotate("k2", rotated, activateAfter, retireAfter)
+	if err != nil {
+		t.Fatalf("rotating: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		now     time.Time
+		signing string
+		verify  []string
+	}{
+		{"before the first key", created.Add(-time.Second), "", []string{"k2", "k1"}},
+		{"first key", created, "k1", []string{"k2", "k1"}},
+		{"rotated", rotated, "k1", []string{"k2", "k1"}},
+		{"published before signing", activated.Add(-time.Second), "k1", []string{"k2", "k1"}},
+		{"activated", activated, "k2", []string{"k2", "k1"}},
+		{"before retirement", retired.Add(-time.Second), "k2", []string{"k2", "k1"}},
+		{"retired", retired, "k2", []string{"k2"}},
+	}
+
+	for _, tt := range tests {
+		signing, err := set.Signing(tt.now)
+		switch {
+		case tt.signing == "" && !errors.Is(err, keyset.ErrNoActiveKey):
+			t.Errorf("%s: got signing %q %v, want %v", tt.name, signing, err, keyset.ErrNoActiveKey)
+		case tt.signing != "" && signing != tt.signing:
+			t.Errorf("%s: got signing %q %v, want %q", tt.name, signing, err, tt.signing)
+		}
+
+		if verify := set.Verification(tt.now); strings.Join(verify, ",") != strings.Join(tt.verify, ",") {
+			t.Errorf("%s: got verification %v, want %v", tt.name, verify, tt.verify)
+		}
+	}
+
+	// Rotating again drops the keys that are retired by then.
+	set, err = set.Rotate("k3", retired, activateAfter, retireAfter)
+	if err != nil {
+		t.Fatalf("rotating again: %s", err)
+	}
+	if verify := set.Verification(retired); strings.Join(verify, ",") != "k3,k2" {
+		t.Errorf("after rotating again: got verification %v, want [k3 k2]", verify)
+	}
+	if len(set.Keys) != 2 {
+		t.Errorf("after rotating again: got %d keys, want 2", len(set.Keys))
+	}
+
+	if _, err := set.Rotate("k2", retired, activateAfter, retireAfter); err == nil {
+		t.Error("rotating in a kid already in the set was allowed")
+	}
+}
+
+// TestRetire checks that a key can be retired early unless it's the one
+// signing at that time.
+func TestRetire(t *testing.T) {
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rotated := created.Add(24 * time.Hour)
+	activated := rotated.Add(10 * time.Minute)
+
+	set, err := keyset.Set{}.Rotate("k1", created, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("creating: %s", err)
+	}
+	set, err = set.Rotate("k2", rotated, 10*time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("rotating: %s", err)
+	}
+
+	tests := []struct {
+		name   string
+		kid    string
+		at     time.Time
+		ok     bool
+		verify []string
+	}{
+		{"signing key", "k1", rotated, false, nil},
+		{"replaced key", "k1", activated, true, []string{"k2"}},
+		{"key not signing yet", "k2", rotated, true, []string{"k1"}},
+		{"unknown key", "k9", rotated, false, nil},
+	}
+
+	for _, tt := range tests {
+		got, err := set.Retire(tt.kid, tt.at)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got %v, want ok %v", tt.name, err, tt.ok)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+
+		if verify := got.Verification(tt.at); strings.Join(verify, ",") != strings.Join(tt.verify, ",") {
+			t.Errorf("%s: got verification %v, want %v", tt.name, verify, tt.verify)
+		}
+	}
+
+	// Retire leaves the set it's called on alone.
+	if verify := set.Verification(activated); len(verify) != 2 {
+		t.Errorf("original set: got verification %v, want both keys", verify)
+	}
+}
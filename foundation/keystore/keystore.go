@@ -0,0 +1,235 @@
+// Package keystore implements the auth.KeyLookup interface with private keys
+// read from PEM files, so tokens can be signed without running Vault.
+package keystore
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/halilylm/micro/foundation/keyset"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// keySetFile is the optional file describing which of the keys is active.
+// It holds a keyset.Set in JSON.
+const keySetFile = "keyset.json"
+
+// key represents a private key and its public part in PEM form.
+type key struct {
+	privatePEM string
+	publicPEM  string
+}
+
+// KeyStore represents an in memory store implementation of the
+// KeyLookup interface for use with the auth package.
+type KeyStore struct {
+	store  map[string]key
+	set    keyset.Set
+	hasSet bool
+}
+
+// NewFS constructs a KeyStore based on a set of PEM files rooted inside of
+// a directory. The name of each PEM file will be used as the key id. When
+// a keyset.json file exists it decides the active key, otherwise there
+// has to be a single key.
+//
+// Example: keystore.NewFS(os.DirFS("/zarf/keys/"))
+// Example: /zarf/keys/54bb2165-71e1-41a6-af3e-7da4a0e1e2c1.pem
+func NewFS(fsys fs.FS) (*KeyStore, error) {
+	ks := KeyStore{
+		store: make(map[string]key),
+	}
+
+	fn := func(fileName string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("walkdir failure: %w", err)
+		}
+
+		if dirEntry.IsDir() {
+			return nil
+		}
+
+		switch {
+		case dirEntry.Name() == keySetFile:
+			data, err := readFile(fsys, fileName)
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(data, &ks.set); err != nil {
+				return fmt.Errorf("decoding key set: %w", err)
+			}
+			ks.hasSet = true
+
+		case path.Ext(fileName) == ".pem":
+			privatePEM, err := readFile(fsys, fileName)
+			if err != nil {
+				return err
+			}
+
+			publicPEM, err := toPublicPEM(string(privatePEM))
+			if err != nil {
+				return fmt.Errorf("key %s: %w", fileName, err)
+			}
+
+			kid := strings.TrimSuffix(dirEntry.Name(), ".pem")
+			ks.store[kid] = key{
+				privatePEM: string(privatePEM),
+				publicPEM:  publicPEM,
+			}
+		}
+
+		return nil
+	}
+
+	if err := fs.WalkDir(fsys, ".", fn); err != nil {
+		return nil, fmt.Errorf("walking directory: %w", err)
+	}
+
+	if len(ks.store) == 0 {
+		return nil, errors.New("no private keys found")
+	}
+
+	return &ks, nil
+}
+
+// PrivateKeyPEM searches the key store for a given kid and returns
+// the private key in pem format.
+func (ks *KeyStore) PrivateKeyPEM(kid string) (string, error) {
+	k, ok := ks.store[kid]
+	if !ok {
+		return "", fmt.Errorf("kid %q not found", kid)
+	}
+
+	return k.privatePEM, nil
+}
+
+// PublicKeyPEM searches the key store for a given kid and returns
+// the public key in pem format.
+func (ks *KeyStore) PublicKeyPEM(kid string) (string, error) {
+	k, ok := ks.store[kid]
+	if !ok {
+		return "", fmt.Errorf("kid %q not found", kid)
+	}
+
+	return k.publicPEM, nil
+}
+
+// ActiveKIDs returns the kids of the keys that can verify tokens. Without a
+// key set every key is used.
+func (ks *KeyStore) ActiveKIDs() ([]string, error) {
+	var kids []string
+
+	if ks.hasSet {
+		for _, kid := range ks.set.Verification(time.Now()) {
+			if _, ok := ks.store[kid]; ok {
+				kids = append(kids, kid)
+			}
+		}
+		return kids, nil
+	}
+
+	for kid := range ks.store {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	return kids, nil
+}
+
+// SigningKID returns the kid of the active key. Without a key set the only
+// key is used.
+func (ks *KeyStore) SigningKID() (string, error) {
+	if ks.hasSet {
+		kid, err := ks.set.Signing(time.Now())
+		if err != nil {
+			return "", err
+		}
+		if _, ok := ks.store[kid]; !ok {
+			return "", fmt.Errorf("active kid %q not found", kid)
+		}
+		return kid, nil
+	}
+
+	if len(ks.store) != 1 {
+		return "", fmt.Errorf("%d keys without a %s: %w", len(ks.store), keySetFile, keyset.ErrNoActiveKey)
+	}
+
+	for kid := range ks.store {
+		return kid, nil
+	}
+
+	return "", keyset.ErrNoActiveKey
+}
+
+// =============================================================================
+
+// readFile reads a file from the file system.
+func readFile(fsys fs.FS, fileName string) ([]byte, error) {
+	file, err := fsys.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("opening key file: %w", err)
+	}
+	defer file.Close()
+
+	// limit PEM file size to 1MB. This should be reasonable
+	// for almost any PEM file and prevents shenanigans like linking
+	// the file to /dev/random or something like that.
+	data, err := io.ReadAll(io.LimitReader(file, 1024*1024))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", fileName, err)
+	}
+
+	return data, nil
+}
+
+// toPublicPEM accepts a PEM encoding of an RSA, ECDSA or Ed25519 private
+// key and converts to a PEM encoded public key.
+func toPublicPEM(privateKeyPEM string) (string, error) {
+	var block *pem.Block
+	if block, _ = pem.Decode([]byte(privateKeyPEM)); block == nil {
+		return "", errors.New("invalid key: Key must be a PEM encoded PKCS1, SEC1 or PKCS8 key")
+	}
+
+	var parsedKey any
+	parsedKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		parsedKey, err = x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			parsedKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	privateKey, ok := parsedKey.(crypto.Signer)
+	if !ok {
+		return "", errors.New("key is not a valid private key")
+	}
+
+	asn1Bytes, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	if err != nil {
+		return "", fmt.Errorf("marshaling public key: %w", err)
+	}
+
+	publicBlock := pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: asn1Bytes,
+	}
+
+	var buf bytes.Buffer
+	if err := pem.Encode(&buf, &publicBlock); err != nil {
+		return "", fmt.Errorf("encoding to public PEM: %w", err)
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,196 @@
+// Package oidcstub provides a stand-in for an OpenID Connect issuer. It
+// serves the discovery document and key set and signs tokens with any
+// claims, so federated authentication can be exercised without a real
+// identity provider.
+package oidcstub
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// key is a signing key of the issuer.
+type key struct {
+	kid     string
+	private *rsa.PrivateKey
+}
+
+// Server is an OpenID Connect issuer listening on a TCP address.
+type Server struct {
+	listener net.Listener
+	server   *http.Server
+	issuer   string
+	audience string
+
+	mu   sync.Mutex
+	keys []key
+}
+
+// Start listens on the address, like "127.0.0.1:0", and serves until Close
+// is called. Tokens are issued for the audience.
+//
+// The issuer serves these endpoints:
+//
+//	GET /.well-known/openid-configuration  discovery document
+//	GET /keys                              key set
+//	GET /token?sub=&email=&name=&groups=   token for the user, groups comma separated
+func Start(addr string, audience string) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
+	s := Server{
+		listener: l,
+		issuer:   "http://" + l.Addr().String(),
+		audience: audience,
+	}
+
+	if err := s.Rotate(); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/keys", s.jwks)
+	mux.HandleFunc("/token", s.token)
+
+	s.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go s.server.Serve(l)
+
+	return &s, nil
+}
+
+// Issuer returns the issuer identifier, which is the base URL of the server.
+func (s *Server) Issuer() string {
+	return s.issuer
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+// Rotate adds a new signing key. Tokens are signed with the newest key and
+// the previous one stays in the key set.
+func (s *Server) Rotate() error {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return fmt.Errorf("generating key: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = append(s.keys, key{kid: uuid.NewString(), private: private})
+	if len(s.keys) > 2 {
+		s.keys = s.keys[len(s.keys)-2:]
+	}
+
+	return nil
+}
+
+// Token signs a token with the claims. The issuer, audience and times are
+// filled in unless the claims set them.
+func (s *Server) Token(claims map[string]any) (string, error) {
+	now := time.Now()
+
+	mc := jwt.MapClaims{
+		"iss": s.issuer,
+		"aud": s.audience,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		mc[k] = v
+	}
+
+	s.mu.Lock()
+	k := s.keys[len(s.keys)-1]
+	s.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, mc)
+	token.Header["kid"] = k.kid
+
+	str, err := token.SignedString(k.private)
+	if err != nil {
+		return "", fmt.Errorf("signing token: %w", err)
+	}
+
+	return str, nil
+}
+
+// =============================================================================
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	doc := map[string]any{
+		"issuer":                                s.issuer,
+		"jwks_uri":                              s.issuer + "/keys",
+		"token_endpoint":                        s.issuer + "/token",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	}
+
+	respond(w, doc)
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]map[string]string, len(s.keys))
+	for i, k := range s.keys {
+		keys[i] = map[string]string{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": k.kid,
+			"n":   base64.RawURLEncoding.EncodeToString(k.private.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.private.E)).Bytes()),
+		}
+	}
+
+	respond(w, map[string]any{"keys": keys})
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	claims := map[string]any{
+		"sub":            q.Get("sub"),
+		"email":          q.Get("email"),
+		"email_verified": true,
+		"name":           q.Get("name"),
+	}
+	if groups := q.Get("groups"); groups != "" {
+		claims["groups"] = strings.Split(groups, ",")
+	}
+
+	token, err := s.Token(claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respond(w, map[string]string{"id_token": token, "token_type": "Bearer"})
+}
+
+func respond(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,125 @@
+// Package totp provides support for RFC 6238 time-based one-time passwords
+// as used by authenticator apps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Settings shared with authenticator apps. These are the defaults every
+// app understands, so they are not configurable.
+const (
+	Digits = 6
+	Period = 30 * time.Second
+)
+
+// encoding is the base32 form authenticator apps expect for secrets.
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random 160-bit secret encoded in base32.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating secret: %w", err)
+	}
+
+	return encoding.EncodeToString(b), nil
+}
+
+// URI constructs the otpauth URI that is usually rendered as a QR code for
+// enrollment in an authenticator app.
+func URI(issuer string, account string, secret string) string {
+	q := make(url.Values)
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(Digits))
+	q.Set("period", fmt.Sprint(int(Period.Seconds())))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: q.Encode(),
+	}
+
+	return u.String()
+}
+
+// Code returns the code for the secret at the specified time.
+func Code(secret string, t time.Time) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("decoding secret: %w", err)
+	}
+
+	return hotp(key, counter(t)), nil
+}
+
+// Validate checks the code against the secret at the specified time. The
+// skew value is the number of periods before and after t that are also
+// accepted to tolerate clock drift.
+func Validate(secret string, code string, t time.Time, skew int) bool {
+	_, ok := ValidateStep(secret, code, t, skew)
+	return ok
+}
+
+// ValidateStep checks the code like Validate and also returns the time step
+// the code belongs to, so a code that was accepted once can be refused.
+func ValidateStep(secret string, code string, t time.Time, skew int) (int64, bool) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	c := int64(counter(t))
+	for i := -skew; i <= skew; i++ {
+		expected := hotp(key, uint64(c+int64(i)))
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return c + int64(i), true
+		}
+	}
+
+	return 0, false
+}
+
+// =============================================================================
+
+// counter converts the time into the number of periods since the epoch.
+func counter(t time.Time) uint64 {
+	return uint64(t.Unix() / int64(Period.Seconds()))
+}
+
+// hotp implements the RFC 4226 algorithm for the specified counter.
+func hotp(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation as described in section 5.3 of RFC 4226.
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", Digits, value%mod)
+}
@@ -0,0 +1,73 @@
+package totp_test
+
+import (
+	"github.com/halilylm/micro/foundation/totp"
+	"testing"
+	"time"
+)
+
+// secret is the SHA1 seed of RFC 6238 appendix B, "12345678901234567890",
+// encoded in base32.
+const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestCode checks the codes against the SHA1 vectors of RFC 6238 appendix B.
+// The RFC lists 8 digit codes, a 6 digit code is their last 6 digits.
+func TestCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		code, err := totp.Code(secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("unix[%d]: %s", tt.unix, err)
+		}
+		if code != tt.code {
+			t.Errorf("unix[%d]: got %s, want %s", tt.unix, code, tt.code)
+		}
+	}
+}
+
+// TestValidateStep checks the skew tolerated around the time a code is
+// validated at and the step reported for it.
+func TestValidateStep(t *testing.T) {
+	// 1111111111 is in step 37037037 and 1111111109 in the step before.
+	at := time.Unix(1111111111, 0)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		skew   int
+		step   int64
+		ok     bool
+	}{
+		{"current step", secret, "050471", 0, 37037037, true},
+		{"previous step without skew", secret, "081804", 0, 0, false},
+		{"previous step with skew", secret, "081804", 1, 37037036, true},
+		{"far step with skew", secret, "005924", 1, 0, false},
+		{"lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "050471", 0, 37037037, true},
+		{"short code", secret, "50471", 1, 0, false},
+		{"wrong code", secret, "123456", 1, 0, false},
+		{"invalid secret", "not base32!", "050471", 1, 0, false},
+	}
+
+	for _, tt := range tests {
+		step, ok := totp.ValidateStep(tt.secret, tt.code, at, tt.skew)
+		if ok != tt.ok || step != tt.step {
+			t.Errorf("%s: got step %d ok %v, want step %d ok %v", tt.name, step, ok, tt.step, tt.ok)
+		}
+
+		if got := totp.Validate(tt.secret, tt.code, at, tt.skew); got != tt.ok {
+			t.Errorf("%s: Validate got %v, want %v", tt.name, got, tt.ok)
+		}
+	}
+}
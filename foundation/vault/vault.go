@@ -5,12 +5,13 @@ package vault
 import (
 	"bytes"
 	"context"
-	"crypto/rsa"
+	"crypto"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"github.com/halilylm/micro/foundation/keyset"
 	"net"
 	"net/http"
 	"strings"
@@ -75,41 +76,38 @@ func (v *Vault) SetToken(token string) {
 
 // AddPrivateKey adds a new private key into vault as PEM encoded
 func (v *Vault) AddPrivateKey(ctx context.Context, kid string, pem []byte) error {
-	url := fmt.Sprintf("%s/v1/%s/data/%s", v.address, v.mountPath, kid)
+	return v.write(ctx, kid, map[string]string{"pem": string(pem)}, nil)
+}
 
-	data := struct {
-		M map[string]string `json:"data"`
-	}{
-		M: map[string]string{
-			"pem": string(pem),
-		},
-	}
-	var b bytes.Buffer
-	if err := json.NewEncoder(&b).Encode(data); err != nil {
-		return fmt.Errorf("encode data: %w", err)
+// KeySet returns the key set along with its version, which must be passed
+// back to StoreKeySet. When no key set is stored yet an empty one is
+// returned with version 0.
+func (v *Vault) KeySet(ctx context.Context) (keyset.Set, int, error) {
+	data, version, err := v.read(ctx, keySetPath)
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			return keyset.Set{}, 0, nil
+		}
+		return keyset.Set{}, 0, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, &b)
-	if err != nil {
-		return fmt.Errorf("create request: %w", err)
+	var set keyset.Set
+	if err := json.Unmarshal([]byte(data["keyset"]), &set); err != nil {
+		return keyset.Set{}, 0, fmt.Errorf("decoding key set: %w", err)
 	}
 
-	req.Header.Set("X-Vault-Token", v.token)
-	req.Header.Set("Cache-Control", "no-cache")
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
+	return set, version, nil
+}
 
-	resp, err := v.client.Do(req)
+// StoreKeySet replaces the key set if it's still at the specified version,
+// so one of two concurrent rotations fails instead of losing a key.
+func (v *Vault) StoreKeySet(ctx context.Context, set keyset.Set, version int) error {
+	b, err := json.Marshal(set)
 	if err != nil {
-		return fmt.Errorf("do: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("status code: %s", resp.Status)
+		return fmt.Errorf("encoding key set: %w", err)
 	}
 
-	return nil
+	return v.write(ctx, keySetPath, map[string]string{"keyset": string(b)}, &version)
 }
 
 // PrivateKeyPEM searches the key store for a given kid and returns
@@ -129,7 +127,7 @@ func (v *Vault) PrivateKeyPEM(kid string) (string, error) {
 // PublicKeyPEM searches the key store for a given kid and returns
 // the public key in pem format
 func (v *Vault) PublicKeyPEM(kid string) (string, error) {
-	if pem, err := v.keyLookup(kid); err != nil {
+	if pem, err := v.keyLookup(kid); err == nil {
 		return pem, nil
 	}
 
@@ -150,6 +148,64 @@ func (v *Vault) PublicKeyPEM(kid string) (string, error) {
 	return publicPEM, nil
 }
 
+// ActiveKIDs returns the kids of the keys in the key set that can verify
+// tokens. Without a key set every private key stored under the mount path
+// is used.
+func (v *Vault) ActiveKIDs() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	set, version, err := v.KeySet(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("key set lookup failed: %w", err)
+	}
+
+	if version > 0 {
+		return set.Verification(time.Now()), nil
+	}
+
+	kids, err := v.listKIDs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("kid list failed: %w", err)
+	}
+
+	return kids, nil
+}
+
+// SigningKID returns the kid of the active key in the key set. Without a key
+// set the only private key stored under the mount path is used.
+func (v *Vault) SigningKID() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	set, version, err := v.KeySet(ctx)
+	if err != nil {
+		return "", fmt.Errorf("key set lookup failed: %w", err)
+	}
+
+	if version > 0 {
+		return set.Signing(time.Now())
+	}
+
+	kids, err := v.listKIDs(ctx)
+	if err != nil {
+		return "", fmt.Errorf("kid list failed: %w", err)
+	}
+
+	if len(kids) != 1 {
+		return "", fmt.Errorf("%d keys stored without a key set: %w", len(kids), keyset.ErrNoActiveKey)
+	}
+
+	return kids[0], nil
+}
+
+// keySetPath is where the key set is stored. It's nested so it isn't
+// listed as a kid.
+const keySetPath = "config/keyset"
+
+// errNotFound is returned when nothing is stored at a path.
+var errNotFound = errors.New("not found")
+
 var (
 	ErrAlreadyInitialized = errors.New("already initialized")
 	ErrBadRequest         = errors.New("bad request")
@@ -440,11 +496,27 @@ func (v *Vault) keyLookup(kid string) (string, error) {
 // retrieveKID performs the HTTP call against the Vault service for the
 // specified kid and returns the pem value
 func (v *Vault) retrieveKID(ctx context.Context, kid string) (string, error) {
-	url := fmt.Sprintf("%s/v1/%s/data/%s", v.address, v.mountPath, kid)
+	data, _, err := v.read(ctx, kid)
+	if err != nil {
+		return "", err
+	}
+
+	pem, ok := data["pem"]
+	if !ok {
+		return "", fmt.Errorf("kid %q does not exists", kid)
+	}
+
+	return pem, nil
+}
+
+// read performs the HTTP call against the Vault service for the secret at
+// the path and returns its data and version.
+func (v *Vault) read(ctx context.Context, path string) (map[string]string, int, error) {
+	url := fmt.Sprintf("%s/v1/%s/data/%s", v.address, v.mountPath, path)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return "", fmt.Errorf("create request: %w", err)
+		return nil, 0, fmt.Errorf("create request: %w", err)
 	}
 
 	req.Header.Set("X-Vault-Token", v.token)
@@ -454,12 +526,16 @@ func (v *Vault) retrieveKID(ctx context.Context, kid string) (string, error) {
 
 	resp, err := v.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("do: %w", err)
+		return nil, 0, fmt.Errorf("do: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, 0, errNotFound
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("status code: %s", resp.Status)
+		return nil, 0, fmt.Errorf("status code: %s", resp.Status)
 	}
 
 	var data struct {
@@ -468,20 +544,118 @@ func (v *Vault) retrieveKID(ctx context.Context, kid string) (string, error) {
 		Renewable     bool   `json:"renewable"`
 		LeaseDuration int    `json:"lease_duration"`
 		Data          struct {
-			Data map[string]string `json:"data"`
+			Data     map[string]string `json:"data"`
+			Metadata struct {
+				Version int `json:"version"`
+			} `json:"metadata"`
 		} `json:"data"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return "", fmt.Errorf("decoding: %w", err)
+		return nil, 0, fmt.Errorf("decoding: %w", err)
 	}
 
-	pem, ok := data.Data.Data["pem"]
-	if !ok {
-		return "", fmt.Errorf("kid %q does not exists", kid)
+	// A deleted secret is still listed but has no data.
+	if data.Data.Data == nil {
+		return nil, 0, errNotFound
 	}
 
-	return pem, nil
+	return data.Data.Data, data.Data.Metadata.Version, nil
+}
+
+// write performs the HTTP call against the Vault service that stores the
+// data at the path. With cas set the write only succeeds if the secret is
+// still at that version, where 0 means it must not exist yet.
+func (v *Vault) write(ctx context.Context, path string, data map[string]string, cas *int) error {
+	url := fmt.Sprintf("%s/v1/%s/data/%s", v.address, v.mountPath, path)
+
+	body := struct {
+		Options map[string]int    `json:"options,omitempty"`
+		M       map[string]string `json:"data"`
+	}{
+		M: data,
+	}
+	if cas != nil {
+		body.Options = map[string]int{"cas": *cas}
+	}
+
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(body); err != nil {
+		return fmt.Errorf("encode data: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, &b)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("X-Vault-Token", v.token)
+	req.Header.Set("Cache-Control", "no-cache")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// listKIDs performs the HTTP call against the Vault service that lists the
+// keys under the mount path. Nested paths aren't kids so they're skipped.
+func (v *Vault) listKIDs(ctx context.Context) ([]string, error) {
+	url := fmt.Sprintf("%s/v1/%s/metadata", v.address, v.mountPath)
+
+	req, err := http.NewRequestWithContext(ctx, "LIST", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("X-Vault-Token", v.token)
+	req.Header.Set("Cache-Control", "no-cache")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Vault responds with not found when nothing is stored yet.
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code: %s", resp.Status)
+	}
+
+	var data struct {
+		Data struct {
+			Keys []string `json:"keys"`
+		} `json:"data"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("decoding: %w", err)
+	}
+
+	kids := make([]string, 0, len(data.Data.Keys))
+	for _, key := range data.Data.Keys {
+		if strings.HasSuffix(key, "/") {
+			continue
+		}
+		kids = append(kids, key)
+	}
+
+	return kids, nil
 }
 
 // listMounts returns the set of mount points that exists.
@@ -517,29 +691,32 @@ func (v *Vault) listMounts(ctx context.Context) (map[string]any, error) {
 }
 
 // toPublicPEM was taken from the JWT package to reduce the dependency. It
-// accepts a PEM encoding of an RSA private key and converts to a PEM encoded
-// public key.
+// accepts a PEM encoding of an RSA, ECDSA or Ed25519 private key and
+// converts to a PEM encoded public key.
 func toPublicPEM(privateKeyPEM string) (string, error) {
 	var block *pem.Block
 	if block, _ = pem.Decode([]byte(privateKeyPEM)); block == nil {
-		return "", errors.New("invalid key: Key must be a PEM encoded PKCS1 or PKCS8 key")
+		return "", errors.New("invalid key: Key must be a PEM encoded PKCS1, SEC1 or PKCS8 key")
 	}
 
 	var parsedKey any
 	parsedKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
 	if err != nil {
-		parsedKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		parsedKey, err = x509.ParseECPrivateKey(block.Bytes)
 		if err != nil {
-			return "", err
+			parsedKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return "", err
+			}
 		}
 	}
 
-	privateKey, ok := parsedKey.(*rsa.PrivateKey)
+	privateKey, ok := parsedKey.(crypto.Signer)
 	if !ok {
-		return "", errors.New("key is not a valid RSA private key")
+		return "", errors.New("key is not a valid private key")
 	}
 
-	asn1Bytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	asn1Bytes, err := x509.MarshalPKIXPublicKey(privateKey.Public())
 	if err != nil {
 		return "", fmt.Errorf("marshaling public key: %w", err)
 	}
@@ -13,12 +13,14 @@ const key ctxKey = 1
 
 const emptyTraceID = "00000000-0000-0000-0000-000000000000"
 
-// Values represents a state for each request
+// Values represents a state for each request. MediaType is the one the
+// response is encoded in, it's only set for routes using Negotiate.
 type Values struct {
 	TraceID    string
 	Tracer     trace.Tracer
 	Now        time.Time
 	StatusCode int
+	MediaType  string
 }
 
 // GetValues returns the values from the context
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Values are turned into their JSON form before they are encoded in another
+// media type. That way every media type has the same field names and
+// representations, like for ids and times, and the json tags of the types
+// are all that decides them.
+
+// object is a JSON object that keeps its keys in the order they were
+// encoded.
+type object struct {
+	keys   []string
+	values map[string]any
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+
+		value, err := json.Marshal(o.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// toDocument returns the JSON form of the value made of objects, []any,
+// strings, json.Numbers, bools and nils.
+func toDocument(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	return readDocument(dec)
+}
+
+// readDocument reads the next value from the decoder.
+func readDocument(dec *json.Decoder) (any, error) {
+	tkn, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tkn.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			obj := object{values: make(map[string]any)}
+			for dec.More() {
+				tkn, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, ok := tkn.(string)
+				if !ok {
+					return nil, fmt.Errorf("unexpected object key %v", tkn)
+				}
+
+				value, err := readDocument(dec)
+				if err != nil {
+					return nil, err
+				}
+
+				if _, exists := obj.values[key]; !exists {
+					obj.keys = append(obj.keys, key)
+				}
+				obj.values[key] = value
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return obj, nil
+
+		case '[':
+			list := []any{}
+			for dec.More() {
+				value, err := readDocument(dec)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, value)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return list, nil
+		}
+
+		return nil, errors.New("unexpected delimiter")
+
+	default:
+		return t, nil
+	}
+}
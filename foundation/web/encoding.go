@@ -0,0 +1,223 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Set of media types with built in support.
+const (
+	MediaTypeJSON    = "application/json"
+	MediaTypeXML     = "application/xml"
+	MediaTypeCSV     = "text/csv"
+	MediaTypeMsgPack = "application/msgpack"
+	MediaTypeNDJSON  = "application/x-ndjson"
+)
+
+// Set of error variables for content negotiation.
+var (
+	ErrNotAcceptable        = errors.New("none of the accepted media types can be produced")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+)
+
+// Encoder writes a value to the response body in a media type.
+type Encoder func(w io.Writer, v any) error
+
+// Decoder reads a request body in a media type into a value.
+type Decoder func(r io.Reader, v any) error
+
+// registry holds the encoders and decoders by media type. The order of the
+// encoders decides which one a wildcard in the Accept header picks.
+var registry = struct {
+	mu       sync.RWMutex
+	encoders map[string]Encoder
+	order    []string
+	decoders map[string]Decoder
+}{
+	encoders: make(map[string]Encoder),
+	decoders: make(map[string]Decoder),
+}
+
+func init() {
+	RegisterEncoder(MediaTypeJSON, encodeJSON)
+	RegisterEncoder(MediaTypeXML, encodeXML)
+	RegisterEncoder(MediaTypeCSV, encodeCSV)
+	RegisterEncoder(MediaTypeMsgPack, encodeMsgPack)
+	RegisterEncoder(MediaTypeNDJSON, encodeNDJSON)
+
+	RegisterDecoder(MediaTypeJSON, decodeJSON)
+	RegisterDecoder(MediaTypeMsgPack, decodeMsgPack)
+}
+
+// RegisterEncoder makes responses available in the media type. Registering
+// a media type again replaces its encoder.
+func RegisterEncoder(mediaType string, enc Encoder) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, exists := registry.encoders[mediaType]; !exists {
+		registry.order = append(registry.order, mediaType)
+	}
+	registry.encoders[mediaType] = enc
+}
+
+// RegisterDecoder makes request bodies in the media type readable by
+// Decode. Registering a media type again replaces its decoder.
+func RegisterDecoder(mediaType string, dec Decoder) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	registry.decoders[mediaType] = dec
+}
+
+// =============================================================================
+
+// Negotiate returns middleware that picks the media type of the response
+// from the Accept header, out of the given media types or every registered
+// one when none are given. Routes without it always respond in JSON, so it's
+// meant for the ones whose responses read well in other media types, like
+// lists of records as CSV. When nothing the client accepts can be produced
+// the handler isn't run and ErrNotAcceptable is returned.
+func Negotiate(mediaTypes ...string) Middleware {
+	m := func(handler Handler) Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			mediaType, ok := negotiate(r.Header.Get("Accept"), mediaTypes)
+			if !ok {
+				return ErrNotAcceptable
+			}
+			GetValues(ctx).MediaType = mediaType
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// negotiate picks the media type of the response from the Accept header
+// out of the offered media types, or all registered ones when none are
+// offered. A missing header or a wildcard means JSON when it's offered and
+// the first offered media type otherwise. It reports false when none of the
+// accepted media types is offered and has an encoder.
+func negotiate(accept string, offered []string) (string, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	if len(offered) == 0 {
+		offered = registry.order
+	}
+
+	var candidates []string
+	for _, mediaType := range offered {
+		if _, exists := registry.encoders[mediaType]; exists {
+			candidates = append(candidates, mediaType)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	fallback := candidates[0]
+	for _, mediaType := range candidates {
+		if mediaType == MediaTypeJSON {
+			fallback = MediaTypeJSON
+		}
+	}
+
+	if strings.TrimSpace(accept) == "" {
+		return fallback, true
+	}
+
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	for _, mr := range ranges {
+		switch {
+		case mr.mediaType == "*/*":
+			return fallback, true
+
+		case strings.HasSuffix(mr.mediaType, "/*"):
+			prefix := strings.TrimSuffix(mr.mediaType, "*")
+			for _, mediaType := range candidates {
+				if strings.HasPrefix(mediaType, prefix) {
+					return mediaType, true
+				}
+			}
+
+		default:
+			for _, mediaType := range candidates {
+				if mediaType == mr.mediaType {
+					return mediaType, true
+				}
+			}
+		}
+	}
+
+	return "", false
+}
+
+// encoder returns the encoder for the media type.
+func encoder(mediaType string) (Encoder, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	enc, exists := registry.encoders[mediaType]
+	return enc, exists
+}
+
+// decoder returns the decoder for the Content-Type of a request. A missing
+// Content-Type is read as JSON.
+func decoder(contentType string) (Decoder, error) {
+	mediaType := MediaTypeJSON
+	if contentType != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(contentType); err != nil {
+			return nil, ErrUnsupportedMediaType
+		}
+	}
+
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	dec, exists := registry.decoders[mediaType]
+	if !exists {
+		return nil, ErrUnsupportedMediaType
+	}
+
+	return dec, nil
+}
@@ -0,0 +1,75 @@
+package web
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name      string
+		accept    string
+		offered   []string
+		mediaType string
+		ok        bool
+	}{
+		{"no header", "", nil, MediaTypeJSON, true},
+		{"any", "*/*", nil, MediaTypeJSON, true},
+		{"exact", "text/csv", nil, MediaTypeCSV, true},
+		{"quality", "text/csv;q=0.5, application/xml", nil, MediaTypeXML, true},
+		{"type wildcard", "text/*", nil, MediaTypeCSV, true},
+		{"refused", "text/csv;q=0", nil, "", false},
+		{"unknown", "image/png", nil, "", false},
+		{"not offered", "text/csv", []string{MediaTypeJSON}, "", false},
+		{"no header without json", "", []string{MediaTypeMsgPack}, MediaTypeMsgPack, true},
+		{"any without json", "*/*", []string{MediaTypeCSV, MediaTypeXML}, MediaTypeCSV, true},
+		{"offered without encoder", "image/png", []string{"image/png"}, "", false},
+	}
+
+	for _, tt := range tests {
+		mediaType, ok := negotiate(tt.accept, tt.offered)
+		if mediaType != tt.mediaType || ok != tt.ok {
+			t.Errorf("%s: got %q %v, want %q %v", tt.name, mediaType, ok, tt.mediaType, tt.ok)
+		}
+	}
+}
+
+// TestNegotiateRoute makes sure only the routes using Negotiate respond in
+// the media type the client asks for.
+func TestNegotiateRoute(t *testing.T) {
+	app := NewApp(make(chan os.Signal, 1), trace.NewNoopTracerProvider().Tracer(""))
+
+	records := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return Respond(ctx, w, []map[string]string{{"name": "a"}}, http.StatusOK)
+	}
+
+	app.Handle(http.MethodGet, "", "/negotiated", records, Negotiate())
+	app.Handle(http.MethodGet, "", "/fixed", records)
+
+	tests := []struct {
+		path        string
+		contentType string
+		vary        string
+	}{
+		{"/negotiated", MediaTypeCSV, "Accept"},
+		{"/fixed", MediaTypeJSON, ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		r.Header.Set("Accept", MediaTypeCSV)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		if got := w.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%s: got content type %q, want %q", tt.path, got, tt.contentType)
+		}
+		if got := w.Header().Get("Vary"); got != tt.vary {
+			t.Errorf("%s: got vary %q, want %q", tt.path, got, tt.vary)
+		}
+	}
+}
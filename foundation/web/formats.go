@@ -0,0 +1,341 @@
+package web
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// encodeJSON writes the value as JSON.
+func encodeJSON(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// decodeJSON reads a JSON document into the value. Fields the value doesn't
+// have and anything following the document are rejected. The body is read
+// whole so the path of a field at fault can be found in it.
+func decodeJSON(r io.Reader, v any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return withFieldPath(err, data, v)
+	}
+
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return ErrTrailingData
+	}
+
+	return nil
+}
+
+// encodeNDJSON writes the value as newline delimited JSON. The items of a
+// list are written on a line each, anything else is a single line.
+func encodeNDJSON(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	lines := []json.RawMessage{data}
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &lines); err != nil {
+			return err
+		}
+	}
+
+	for _, line := range lines {
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// encodeXML writes the value as an XML document with a response root.
+// Object keys become elements and list items are item elements. A key that
+// isn't a valid element name is written as an item with a key attribute.
+func encodeXML(w io.Writer, v any) error {
+	doc, err := toDocument(v)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	if err := writeXML(enc, "response", doc); err != nil {
+		return err
+	}
+
+	return enc.Flush()
+}
+
+func writeXML(enc *xml.Encoder, name string, v any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !isXMLName(name) {
+		start = xml.StartElement{
+			Name: xml.Name{Local: "item"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}},
+		}
+	}
+
+	switch doc := v.(type) {
+	case nil:
+		return nil
+
+	case object:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, k := range doc.keys {
+			if err := writeXML(enc, k, doc.values[k]); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+
+	case []any:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, item := range doc {
+			if err := writeXML(enc, "item", item); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+
+	default:
+		return enc.EncodeElement(scalarString(doc), start)
+	}
+}
+
+// isXMLName reports whether the name can be used as an element name.
+func isXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+
+	for i, r := range name {
+		switch {
+		case unicode.IsLetter(r), r == '_':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// =============================================================================
+
+// encodeCSV writes a list of objects, or a single object, as rows with a
+// header. The columns are the keys of the objects in the order they were
+// first seen. Nested objects and lists are written as JSON.
+func encodeCSV(w io.Writer, v any) error {
+	doc, err := toDocument(v)
+	if err != nil {
+		return err
+	}
+
+	var rows []object
+	switch d := doc.(type) {
+	case object:
+		rows = []object{d}
+	case []any:
+		for _, item := range d {
+			obj, ok := item.(object)
+			if !ok {
+				return fmt.Errorf("csv: list items must be objects, got %T", item)
+			}
+			rows = append(rows, obj)
+		}
+	default:
+		return fmt.Errorf("csv: value must be an object or a list, got %T", doc)
+	}
+
+	var header []string
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		for _, k := range row.keys {
+			if !seen[k] {
+				seen[k] = true
+				header = append(header, k)
+			}
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if len(header) > 0 {
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range rows {
+		record := make([]string, len(header))
+		for i, k := range header {
+			cell, err := csvCell(row.values[k])
+			if err != nil {
+				return err
+			}
+			record[i] = cell
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell returns the text of a cell. Strings that a spreadsheet would run
+// as a formula are prefixed with a quote.
+func csvCell(v any) (string, error) {
+	switch d := v.(type) {
+	case nil:
+		return "", nil
+
+	case string:
+		if d != "" && strings.ContainsRune("=+-@\t\r", rune(d[0])) {
+			return "'" + d, nil
+		}
+		return d, nil
+
+	case object, []any:
+		data, err := json.Marshal(d)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+
+	default:
+		return scalarString(d), nil
+	}
+}
+
+// =============================================================================
+
+// encodeMsgPack writes the value as MessagePack.
+func encodeMsgPack(w io.Writer, v any) error {
+	doc, err := toDocument(v)
+	if err != nil {
+		return err
+	}
+
+	return writeMsgPack(msgpack.NewEncoder(w), doc)
+}
+
+func writeMsgPack(enc *msgpack.Encoder, v any) error {
+	switch doc := v.(type) {
+	case object:
+		if err := enc.EncodeMapLen(len(doc.keys)); err != nil {
+			return err
+		}
+		for _, k := range doc.keys {
+			if err := enc.EncodeString(k); err != nil {
+				return err
+			}
+			if err := writeMsgPack(enc, doc.values[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case []any:
+		if err := enc.EncodeArrayLen(len(doc)); err != nil {
+			return err
+		}
+		for _, item := range doc {
+			if err := writeMsgPack(enc, item); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case json.Number:
+		if i, err := doc.Int64(); err == nil {
+			return enc.EncodeInt(i)
+		}
+		f, err := doc.Float64()
+		if err != nil {
+			return err
+		}
+		return enc.EncodeFloat64(f)
+
+	default:
+		return enc.Encode(doc)
+	}
+}
+
+// decodeMsgPack reads a MessagePack document into the value. It goes
+// through JSON so the value is filled in by its json tags, like for a JSON
+// body.
+func decodeMsgPack(r io.Reader, v any) error {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(body) == 0 {
+		return io.EOF
+	}
+
+	br := bytes.NewReader(body)
+
+	var doc any
+	if err := msgpack.NewDecoder(br).Decode(&doc); err != nil {
+		return err
+	}
+	if br.Len() > 0 {
+		return ErrTrailingData
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	return decodeJSON(bytes.NewReader(data), v)
+}
+
+// =============================================================================
+
+// scalarString returns the text of a string, number or bool of a document.
+func scalarString(v any) string {
+	switch d := v.(type) {
+	case string:
+		return d
+	case json.Number:
+		return d.String()
+	case bool:
+		return strconv.FormatBool(d)
+	default:
+		return fmt.Sprint(d)
+	}
+}
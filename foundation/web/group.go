@@ -0,0 +1,60 @@
+package web
+
+import "strings"
+
+// Group is a set of routes under a common path that share middleware.
+// Groups can be nested, a nested group runs the middleware of its parents
+// first.
+type Group struct {
+	app  *App
+	path string
+	mw   []Middleware
+}
+
+// Group creates a group of routes under the path, like a version of the
+// api. The middleware runs after the application middleware and before the
+// middleware of each route.
+func (a *App) Group(path string, mw ...Middleware) *Group {
+	return &Group{
+		app:  a,
+		path: joinPath("", path),
+		mw:   mw,
+	}
+}
+
+// Group creates a group nested in this one. The path is added to the path
+// of this group and may be empty to only add middleware.
+func (g *Group) Group(path string, mw ...Middleware) *Group {
+	return &Group{
+		app:  g.app,
+		path: joinPath(g.path, path),
+		mw:   chain(g.mw, mw),
+	}
+}
+
+// Handle sets a handler function for a given HTTP method and path in the
+// group. The middleware of the group runs before the middleware given here.
+func (g *Group) Handle(method string, path string, handler Handler, mw ...Middleware) {
+	path = joinPath(g.path, path)
+	if path == "" {
+		path = "/"
+	}
+	g.app.Handle(method, "", path, handler, chain(g.mw, mw)...)
+}
+
+// chain returns the middleware of a parent followed by more, without
+// sharing the backing array of the parent with its other children.
+func chain(parent []Middleware, mw []Middleware) []Middleware {
+	all := make([]Middleware, 0, len(parent)+len(mw))
+	all = append(all, parent...)
+	return append(all, mw...)
+}
+
+// joinPath adds the path to the prefix with a single slash between them.
+func joinPath(prefix string, path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return prefix
+	}
+	return prefix + "/" + path
+}
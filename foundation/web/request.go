@@ -1,9 +1,22 @@
 package web
 
 import (
+	"bytes"
+	"encoding"
 	"encoding/json"
+	"errors"
 	"github.com/dimfeld/httptreemux/v5"
+	"io"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Set of error variables for reading request bodies.
+var (
+	ErrBodyTooLarge = errors.New("request body too large")
+	ErrTrailingData = errors.New("request body must only contain a single value")
 )
 
 // Param returns the web call parameters from the request.
@@ -12,15 +25,346 @@ func Param(r *http.Request, key string) string {
 	return m[key]
 }
 
-// Decode reads the body of an HTTP requests looking for a JSON document.
-// The body is decoded into the provided value
+// validator is implemented by values that check themselves once decoded.
+type validator interface {
+	Validate() error
+}
+
+// Decode reads the body of an HTTP request in the media type of its
+// Content-Type, JSON when it's missing. The body is decoded into the
+// provided value. ErrUnsupportedMediaType is returned when there is no
+// decoder for the Content-Type.
+//
+// A body that can't be read into the value is reported as a DecodeError,
+// naming the field when the problem is with one. A body cut off by
+// http.MaxBytesReader is reported as ErrBodyTooLarge.
 //
-// If the provided value is a struct then it is checked for validation tags.
+// If the provided value has a Validate method it's called once the value
+// is decoded and its error is returned as is.
 func Decode(r *http.Request, val any) error {
-	decoder := json.NewDecoder(r.Body)
-	decoder.DisallowUnknownFields()
-	if err := decoder.Decode(val); err != nil {
+	dec, err := decoder(r.Header.Get("Content-Type"))
+	if err != nil {
 		return err
 	}
+
+	if err := dec(r.Body, val); err != nil {
+		return decodeError(err)
+	}
+
+	if v, ok := val.(validator); ok {
+		if err := v.Validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// =============================================================================
+
+// DecodeError is returned by Decode when the body can't be read into the
+// value. Field is the JSON path of the field at fault, like items[0].cost,
+// and is empty when the problem is with the body as a whole.
+type DecodeError struct {
+	Field string
+	Err   error
+}
+
+// Error is the implementation of the error interface.
+func (de *DecodeError) Error() string {
+	if de.Field == "" {
+		return de.Err.Error()
+	}
+	return de.Field + ": " + de.Err.Error()
+}
+
+// Unwrap returns the error the body was rejected with.
+func (de *DecodeError) Unwrap() error {
+	return de.Err
+}
+
+// IsDecodeError checks if an error of type DecodeError exists.
+func IsDecodeError(err error) bool {
+	var de *DecodeError
+	return errors.As(err, &de)
+}
+
+// GetDecodeError returns a copy of the DecodeError pointer.
+func GetDecodeError(err error) *DecodeError {
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		return nil
+	}
+	return de
+}
+
+// decodeError translates an error from a decoder into one a client can
+// act on.
+func decodeError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var fieldErr *fieldError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return ErrBodyTooLarge
+
+	case errors.Is(err, ErrTrailingData):
+		return &DecodeError{Err: err}
+
+	case errors.Is(err, io.EOF):
+		return &DecodeError{Err: errors.New("request body is empty")}
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &DecodeError{Err: errors.New("request body is incomplete")}
+
+	case errors.As(err, &syntaxErr):
+		return &DecodeError{Err: errors.New("request body is malformed")}
+
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if errors.As(err, &fieldErr) {
+			field = fieldErr.path
+		}
+		if field == "" {
+			return &DecodeError{Err: errors.New("request body must be " + jsonKind(typeErr.Type))}
+		}
+		return &DecodeError{
+			Field: field,
+			Err:   errors.New("must be " + jsonKind(typeErr.Type)),
+		}
+
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		if errors.As(err, &fieldErr) {
+			field = fieldErr.path
+		}
+		return &DecodeError{Field: field, Err: errors.New("unknown field")}
+	}
+
+	return &DecodeError{Err: err}
+}
+
+// =============================================================================
+
+// fieldError is an error of encoding/json about a field with the full JSON
+// path of the field, which the error itself only has in part.
+type fieldError struct {
+	path string
+	err  error
+}
+
+// Error is the implementation of the error interface.
+func (fe *fieldError) Error() string {
+	return fe.err.Error()
+}
+
+// Unwrap returns the error of encoding/json.
+func (fe *fieldError) Unwrap() error {
+	return fe.err
+}
+
+// withFieldPath adds the path of the field at fault to an unknown field or
+// type error from decoding the JSON document into the value. Other errors
+// are returned as is.
+func withFieldPath(err error, data []byte, v any) error {
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) && !strings.HasPrefix(err.Error(), "json: unknown field ") {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	doc, derr := readDocument(dec)
+	if derr != nil || v == nil {
+		return err
+	}
+
+	path, ok := fieldPath(doc, reflect.TypeOf(v), "")
+	if !ok {
+		return err
+	}
+
+	return &fieldError{path: path, err: err}
+}
+
+// jsonUnmarshaler is the type of values that decode themselves.
+var jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// fieldPath walks the document along the type it's decoded into, in the
+// order encoding/json does, and returns the path of the first key the type
+// has no field for or the first value the type can't hold. It reports false
+// when there is none or the type decodes itself.
+func fieldPath(doc any, t reflect.Type, path string) (string, bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if doc == nil || t.Kind() == reflect.Interface || reflect.PtrTo(t).Implements(jsonUnmarshaler) {
+		return "", false
+	}
+
+	switch d := doc.(type) {
+	case object:
+		switch t.Kind() {
+		case reflect.Struct:
+			for _, k := range d.keys {
+				ft, quoted, ok := structField(t, k)
+				if !ok {
+					return joinField(path, k), true
+				}
+				if _, isString := d.values[k].(string); quoted && isString {
+					continue
+				}
+				if p, ok := fieldPath(d.values[k], ft, joinField(path, k)); ok {
+					return p, true
+				}
+			}
+			return "", false
+
+		case reflect.Map:
+			for _, k := range d.keys {
+				if p, ok := fieldPath(d.values[k], t.Elem(), joinField(path, k)); ok {
+					return p, true
+				}
+			}
+			return "", false
+		}
+
+	case []any:
+		switch t.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i, item := range d {
+				if p, ok := fieldPath(item, t.Elem(), path+"["+strconv.Itoa(i)+"]"); ok {
+					return p, true
+				}
+			}
+			return "", false
+		}
+
+	case string:
+		switch {
+		case t.Kind() == reflect.String, reflect.PtrTo(t).Implements(textUnmarshaler):
+			return "", false
+		case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+			return "", false
+		}
+
+	case json.Number:
+		var err error
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			_, err = strconv.ParseInt(string(d), 10, t.Bits())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			_, err = strconv.ParseUint(string(d), 10, t.Bits())
+		case reflect.Float32, reflect.Float64:
+			_, err = strconv.ParseFloat(string(d), t.Bits())
+		default:
+			return path, true
+		}
+		return path, err != nil
+
+	case bool:
+		if t.Kind() == reflect.Bool {
+			return "", false
+		}
+	}
+
+	return path, true
+}
+
+// structField returns the type of the field of the struct a JSON key is
+// read into and whether its tag has the string option. Like encoding/json
+// it matches the name exactly first and then ignoring case, and looks into
+// embedded structs after the fields of the struct itself.
+func structField(t reflect.Type, key string) (reflect.Type, bool, bool) {
+	type field struct {
+		name   string
+		typ    reflect.Type
+		quoted bool
+	}
+
+	var fields []field
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, field{name: name, typ: f.Type, quoted: strings.Contains(","+opts+",", ",string,")})
+	}
+
+	for _, f := range fields {
+		if f.name == key {
+			return f.typ, f.quoted, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, key) {
+			return f.typ, f.quoted, true
+		}
+	}
+	for _, et := range embedded {
+		if ft, quoted, ok := structField(et, key); ok {
+			return ft, quoted, true
+		}
+	}
+
+	return nil, false, false
+}
+
+// joinField adds the key of an object to the path of the object.
+func joinField(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// textUnmarshaler is the type of values read from a JSON string by their
+// UnmarshalText method, like ids.
+var textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// jsonKind returns the name of the JSON type a Go type is read from.
+func jsonKind(t reflect.Type) string {
+	if reflect.PtrTo(t).Implements(textUnmarshaler) {
+		return "a string"
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "a list"
+	default:
+		return "an object"
+	}
+}
@@ -0,0 +1,109 @@
+package web_test
+
+import (
+	"errors"
+	"github.com/halilylm/micro/foundation/web"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type base struct {
+	ID string `json:"id"`
+}
+
+type item struct {
+	Cost     int    `json:"cost"`
+	Quantity int    `json:"quantity,string"`
+	Notes    []note `json:"notes"`
+}
+
+type note struct {
+	Text string `json:"text"`
+}
+
+type order struct {
+	base
+	Name  string            `json:"name"`
+	Items []item            `json:"items"`
+	Tags  map[string]string `json:"tags"`
+}
+
+// TestDecodeField checks the path reported for the field at fault, however
+// deep it's nested.
+func TestDecodeField(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		field string
+		err   string
+	}{
+		{"unknown field", `{"name":"a","color":"red"}`, "color", "unknown field"},
+		{"nested unknown field", `{"items":[{"cost":1},{"cost":2,"color":"red"}]}`, "items[1].color", "unknown field"},
+		{"deep unknown field", `{"items":[{"notes":[{"text":"a","color":"red"}]}]}`, "items[0].notes[0].color", "unknown field"},
+		{"type error", `{"name":1}`, "name", "must be a string"},
+		{"nested type error", `{"items":[{"cost":1},{"cost":"2"}]}`, "items[1].cost", "must be a number"},
+		{"number out of range", `{"items":[{"cost":1.5}]}`, "items[0].cost", "must be a number"},
+		{"map value type error", `{"tags":{"a":"b","c":1}}`, "tags.c", "must be a string"},
+		{"embedded field type error", `{"id":1}`, "id", "must be a string"},
+		{"first of two errors", `{"items":[{"cost":"1"}],"color":"red"}`, "items[0].cost", "must be a number"},
+		{"body of the wrong type", `[]`, "", "request body must be an object"},
+		{"empty body", ``, "", "request body is empty"},
+		{"malformed body", `{"name":}`, "", "request body is malformed"},
+		{"incomplete body", `{"name":"a"`, "", "request body is incomplete"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+
+		var o order
+		err := web.Decode(r, &o)
+
+		de := web.GetDecodeError(err)
+		if de == nil {
+			t.Errorf("%s: got %v, want a decode error", tt.name, err)
+			continue
+		}
+		if de.Field != tt.field || de.Err.Error() != tt.err {
+			t.Errorf("%s: got field %q error %q, want field %q error %q", tt.name, de.Field, de.Err, tt.field, tt.err)
+		}
+	}
+}
+
+// TestDecodeBody checks the bodies that are read whole or refused whole.
+func TestDecodeBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		limit       int64
+		err         error
+	}{
+		{"valid", `{"id":"1","name":"a","items":[{"cost":1,"quantity":"2"}]}`, "", 0, nil},
+		{"case of keys", `{"ID":"1","Name":"a"}`, "", 0, nil},
+		{"trailing space", "{\"name\":\"a\"}\n", "", 0, nil},
+		{"trailing value", `{"name":"a"}{"name":"b"}`, "", 0, web.ErrTrailingData},
+		{"trailing garbage", `{"name":"a"}}`, "", 0, web.ErrTrailingData},
+		{"within limit", `{"name":"a"}`, "", 12, nil},
+		{"over limit", `{"name":"abc"}`, "", 12, web.ErrBodyTooLarge},
+		{"over limit with trailing data", `{"name":"a"}    {}`, "", 14, web.ErrBodyTooLarge},
+		{"unsupported media type", `name=a`, "application/x-www-form-urlencoded", 0, web.ErrUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			r.Header.Set("Content-Type", tt.contentType)
+		}
+		if tt.limit > 0 {
+			r.Body = http.MaxBytesReader(httptest.NewRecorder(), r.Body, tt.limit)
+		}
+
+		var o order
+		err := web.Decode(r, &o)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
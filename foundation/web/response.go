@@ -1,35 +1,50 @@
 package web
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"go.opentelemetry.io/otel/attribute"
 	"net/http"
 )
 
-// Respond converts a Go value to JSON and sends it to the client.
+// Respond encodes a Go value in the media type negotiated for the request
+// and sends it to the client. JSON is used when the route doesn't negotiate
+// or nothing the client accepts could be produced.
 func Respond(ctx context.Context, w http.ResponseWriter, data any, statusCode int) error {
 	ctx, span := AddSpan(ctx, "foundation.web.response", attribute.Int("status", statusCode))
 	defer span.End()
 
 	SetStatusCode(ctx, statusCode)
 
-	if statusCode == http.StatusNoContent {
+	if statusCode == http.StatusNoContent || statusCode == http.StatusNotModified {
 		w.WriteHeader(statusCode)
 		return nil
 	}
 
-	jsonData, err := json.Marshal(data)
-	if err != nil {
+	mediaType := GetValues(ctx).MediaType
+	negotiated := mediaType != ""
+	enc, ok := encoder(mediaType)
+	if !ok {
+		mediaType = MediaTypeJSON
+		enc = encodeJSON
+	}
+
+	// The value is encoded before anything is written so a failure can
+	// still be responded to.
+	var buf bytes.Buffer
+	if err := enc(&buf, data); err != nil {
 		return err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", mediaType)
+	if negotiated {
+		w.Header().Add("Vary", "Accept")
+	}
 	w.WriteHeader(statusCode)
 
-	if _, err := w.Write(jsonData); err != nil {
+	if _, err := w.Write(buf.Bytes()); err != nil {
 		return err
 	}
-	
+
 	return nil
-}
\ No newline at end of file
+}
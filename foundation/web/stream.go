@@ -0,0 +1,148 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	"net"
+	"net/http"
+	"time"
+)
+
+// A stream is flushed to the client after this many values or when this
+// much time passed since the last flush, whichever comes first.
+const (
+	streamFlushValues   = 100
+	streamFlushInterval = time.Second
+)
+
+// streamWriteTimeout is how long a flush of a stream can take. The server
+// write timeout is meant for whole responses so a stream pushes the write
+// deadline of its connection back this far on every flush instead.
+const streamWriteTimeout = 30 * time.Second
+
+// connKey is how the connection of a request is stored in its context.
+type connKey struct{}
+
+// ConnContext stores the connection in the context of its requests so a
+// stream can extend its write deadline. It's meant to be set as the
+// ConnContext of the http.Server.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, c)
+}
+
+// extendWriteDeadline pushes the write deadline of the connection of the
+// request back. Nothing happens when the server didn't store it.
+func extendWriteDeadline(ctx context.Context) {
+	if c, ok := ctx.Value(connKey{}).(net.Conn); ok {
+		c.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	}
+}
+
+// streamError is returned when a stream fails after the response started.
+// There is nothing left to respond with, it can only be logged.
+type streamError struct {
+	err error
+}
+
+// Error is the implementation of the error interface.
+func (se *streamError) Error() string {
+	return "stream aborted: " + se.err.Error()
+}
+
+// Unwrap returns the error that aborted the stream.
+func (se *streamError) Unwrap() error {
+	return se.err
+}
+
+// IsStreamAborted checks to see if the error happened after a stream
+// response started.
+func IsStreamAborted(err error) bool {
+	var se *streamError
+	return errors.As(err, &se)
+}
+
+// StreamNDJSON sends the values passed to send as newline delimited JSON as
+// they are produced, like rows scanned from a database cursor, so they are
+// never held in memory together. The stream stops when the context is
+// cancelled. The server write timeout doesn't cut it off when the server
+// uses ConnContext.
+//
+// An error returned before the first value is sent is returned as is so it
+// can be responded to. After that the status is sent already, the stream
+// ends with an {"error": ...} line and the error is wrapped so
+// IsStreamAborted reports true.
+func StreamNDJSON(ctx context.Context, w http.ResponseWriter, produce func(send func(v any) error) error) error {
+	ctx, span := AddSpan(ctx, "foundation.web.stream")
+	defer span.End()
+
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	var started bool
+	var sent int
+	lastFlush := time.Now()
+
+	start := func() {
+		extendWriteDeadline(ctx)
+		started = true
+		SetStatusCode(ctx, http.StatusOK)
+		w.Header().Set("Content-Type", MediaTypeNDJSON)
+		w.WriteHeader(http.StatusOK)
+	}
+
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+		lastFlush = time.Now()
+		extendWriteDeadline(ctx)
+	}
+
+	send := func(v any) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !started {
+			start()
+		}
+
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		sent++
+
+		if sent%streamFlushValues == 0 || time.Since(lastFlush) >= streamFlushInterval {
+			flush()
+		}
+
+		return nil
+	}
+
+	err := produce(send)
+	span.SetAttributes(attribute.Int("values", sent))
+
+	switch {
+	case err == nil:
+		if !started {
+			start()
+		}
+		flush()
+		return nil
+
+	case !started:
+		return err
+
+	default:
+		span.RecordError(err)
+		enc.Encode(struct {
+			Error string `json:"error"`
+		}{
+			Error: "stream aborted",
+		})
+		flush()
+		return &streamError{err: err}
+	}
+}
@@ -10,6 +10,9 @@ import (
 	"time"
 )
 
+// ErrBusy is returned by TryStart when every worker is running a job.
+var ErrBusy = errors.New("all workers are busy")
+
 // JobFunc defines a function that can execute work for a specific job.
 type JobFunc func(ctx context.Context)
 
@@ -93,6 +96,29 @@ func (w *Worker) Start(ctx context.Context, fn JobFunc) (string, error) {
 	case <-w.sem:
 	}
 
+	return w.launch(ctx, fn), nil
+}
+
+// TryStart launches a goroutine to perform the work like Start, but returns
+// ErrBusy right away instead of waiting when every worker is running a job.
+func (w *Worker) TryStart(ctx context.Context, fn JobFunc) (string, error) {
+	select {
+	case <-w.isShutdown:
+		return "", errors.New("shutting down")
+	default:
+	}
+
+	select {
+	case <-w.sem:
+	default:
+		return "", ErrBusy
+	}
+
+	return w.launch(ctx, fn), nil
+}
+
+// launch performs the work in a goroutine once a semaphore is captured.
+func (w *Worker) launch(ctx context.Context, fn JobFunc) string {
 	// need a unique key for this work.
 	workKey := uuid.NewString()
 
@@ -102,8 +128,10 @@ func (w *Worker) Start(ctx context.Context, fn JobFunc) (string, error) {
 		deadline = time.Now().Add(time.Second)
 	}
 
-	// create a cancel function and keep it for stop/shutdown purposes.
-	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	// create a cancel function and keep it for stop/shutdown purposes. The
+	// job outlives the caller but keeps the values of its context, like the
+	// trace id.
+	ctx, cancel := context.WithDeadline(detached{ctx}, deadline)
 
 	// Register this new G as running
 	w.mu.Lock()
@@ -130,7 +158,7 @@ func (w *Worker) Start(ctx context.Context, fn JobFunc) (string, error) {
 		fn(ctx)
 	}()
 
-	return workKey, nil
+	return workKey
 }
 
 // Stop is used to cancel an existing job that is running.
@@ -155,3 +183,26 @@ func (w *Worker) removeWork(workKey string) {
 	delete(w.running, workKey)
 	w.mu.Unlock()
 }
+
+// =============================================================================
+
+// detached carries the values of a context without its deadline and
+// cancellation.
+type detached struct {
+	context.Context
+}
+
+// Deadline reports there is no deadline.
+func (detached) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// Done returns nil since the context is never canceled.
+func (detached) Done() <-chan struct{} {
+	return nil
+}
+
+// Err returns nil since the context is never canceled.
+func (detached) Err() error {
+	return nil
+}